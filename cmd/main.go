@@ -2,63 +2,112 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"runtime"
+	"strconv"
 	"syscall"
 	"time"
 
 	"network-discovery/internal/api"
+	"network-discovery/internal/arp"
+	"network-discovery/internal/config"
 	"network-discovery/internal/discovery"
+	"network-discovery/internal/inventory"
+	"network-discovery/internal/jobs"
+	"network-discovery/internal/ports"
 
 	"github.com/sirupsen/logrus"
 )
 
 var (
-	port       = flag.String("port", "8080", "Server port")
-	host       = flag.String("host", "0.0.0.0", "Server host")
-	logLevel   = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
-	configPath = flag.String("config", "configs/oui_vendors.json", "Path to OUI vendors JSON file")
+	configFile = flag.String("config-file", config.DefaultPath, "Path to the YAML configuration file")
+	port       = flag.String("port", "8080", "Server port (overrides server.port)")
+	host       = flag.String("host", "0.0.0.0", "Server host (overrides server.host)")
+	logLevel   = flag.String("log-level", "info", "Log level (debug, info, warn, error; overrides logging.level)")
+	configPath = flag.String("config", arp.DefaultVendorDatabase, "Path to OUI vendors JSON file (overrides scanning.vendor_database)")
+	dbPath     = flag.String("db", inventory.DefaultPath, "Path to the device inventory database, empty to disable (overrides inventory.path)")
+	activeARP  = flag.Bool("active-arp", false, "Send raw ARP requests during ARP scans (Linux, requires root or CAP_NET_RAW; overrides scanning.active_arp)")
 )
 
 func main() {
 	flag.Parse()
 
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Setup logger
-	logger := logrus.New()
-	level, err := logrus.ParseLevel(*logLevel)
+	logger, err := cfg.Logging.NewLogger()
 	if err != nil {
-		logger.Fatal("Invalid log level")
+		fmt.Fprintf(os.Stderr, "Failed to set up logging: %v\n", err)
+		os.Exit(1)
 	}
-	logger.SetLevel(level)
-	logger.SetFormatter(&logrus.JSONFormatter{})
 
-	logger.Infof("Starting Network Discovery Service with log level: %s", *logLevel)
+	logger.Infof("Starting Network Discovery Service with log level: %s", cfg.Logging.Level)
+	warnReservedFeatures(cfg, logger)
+
+	// Apply process-wide port scanner settings
+	if err := ports.SetProfiles(cfg.PortProfiles); err != nil {
+		logger.Fatalf("Invalid port profiles: %v", err)
+	}
+	ports.SetConnectRate(cfg.Scanning.PortScanRate)
+	ports.SetMaxHostConcurrency(cfg.Scanning.MaxHostProbes)
+
+	// Create network discovery service from the loaded configuration
+	networkDiscovery := discovery.NewNetworkDiscoveryWithConfig(cfg, logger)
+
+	// Open persistent device inventory
+	if cfg.Inventory.Path != "" {
+		store, err := inventory.Open(cfg.Inventory.Path, logger)
+		if err != nil {
+			logger.Warnf("Device inventory disabled: %v", err)
+		} else {
+			defer store.Close()
+			store.SetRetention(cfg.Inventory.MaxScans, cfg.Inventory.MaxAge)
+			networkDiscovery.SetStore(store)
+		}
+	}
 
-	// Create network discovery service with custom log level
-	networkDiscovery := discovery.NewNetworkDiscoveryWithLogLevel(level)
+	// Create job manager for asynchronous scans
+	jobManager := jobs.NewManager(networkDiscovery, logger)
 
 	// Setup routes
-	router := api.SetupRoutes(networkDiscovery)
+	router := api.SetupRoutes(networkDiscovery, jobManager, cfg, logger)
+
+	// Root context for request handlers; cancelled on shutdown so in-flight scans stop
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
 
-	// Create HTTP server with increased timeouts for long scans
+	// Create HTTP server; synchronous scans need generous timeouts
 	server := &http.Server{
-		Addr:         fmt.Sprintf("%s:%s", *host, *port),
+		Addr:         cfg.Server.Address(),
 		Handler:      router,
-		ReadTimeout:  5 * time.Minute,  // Increased from 30s to 5 minutes
-		WriteTimeout: 5 * time.Minute,  // Increased from 30s to 5 minutes
-		IdleTimeout:  10 * time.Minute, // Increased from 60s to 10 minutes
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
 	}
 
 	// Start server in a goroutine
+	serverHost := cfg.Server.Host
+	serverPort := strconv.Itoa(cfg.Server.Port)
+
 	go func() {
-		logger.Infof("Server starting on %s:%s", *host, *port)
+		logger.Infof("Server starting on %s", server.Addr)
 
-		go openBrowser("http://localhost:8080/index")
+		go openBrowser(fmt.Sprintf("http://localhost:%s/index", serverPort))
 
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Fatalf("Server failed to start: %v", err)
@@ -66,7 +115,7 @@ func main() {
 	}()
 
 	// Print startup information
-	printStartupInfo(*host, *port)
+	printStartupInfo(serverHost, serverPort)
 
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
@@ -75,17 +124,86 @@ func main() {
 
 	logger.Info("Shutting down server...")
 
+	// Stop running scans so their handlers return partial results promptly
+	cancelBase()
+
 	// Give outstanding requests 30 seconds to complete
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// On timeout close the remaining connections, but carry on so scan jobs are
+	// cancelled and the deferred inventory Close still runs
 	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatalf("Server forced to shutdown: %v", err)
+		logger.Errorf("Server forced to shutdown: %v", err)
+		server.Close()
+	}
+
+	// Cancel running scan jobs
+	if err := jobManager.Shutdown(ctx); err != nil {
+		logger.Warnf("Scan jobs did not stop in time: %v", err)
 	}
 
 	logger.Info("Server exited")
 }
 
+// loadConfig loads the configuration file and applies explicitly set command line flags on top.
+// A missing config.yaml is tolerated unless --config-file was given.
+func loadConfig() (*config.Config, error) {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	cfg, err := config.Load(*configFile)
+	if err != nil && !set["config-file"] && errors.Is(err, fs.ErrNotExist) {
+		cfg, err = config.Load("")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if set["host"] {
+		cfg.Server.Host = *host
+	}
+	if set["port"] {
+		p, err := strconv.Atoi(*port)
+		if err != nil || p <= 0 || p > 65535 {
+			return nil, fmt.Errorf("invalid port %q", *port)
+		}
+		cfg.Server.Port = p
+	}
+	if set["log-level"] {
+		if _, err := logrus.ParseLevel(*logLevel); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", *logLevel)
+		}
+		cfg.Logging.Level = *logLevel
+	}
+	if set["config"] {
+		cfg.Scanning.VendorDatabase = *configPath
+	}
+	if set["db"] {
+		cfg.Inventory.Path = *dbPath
+	}
+	if set["active-arp"] {
+		cfg.Scanning.ActiveARP = *activeARP
+	}
+	return cfg, nil
+}
+
+// warnReservedFeatures logs settings that are accepted in config.yaml but not implemented yet
+func warnReservedFeatures(cfg *config.Config, logger *logrus.Logger) {
+	reserved := map[string]bool{
+		"security.enable_auth":       cfg.Security.EnableAuth,
+		"features.enable_traps":      cfg.Features.EnableTraps,
+		"features.enable_monitoring": cfg.Features.EnableMonitoring,
+	}
+	for key, enabled := range reserved {
+		if enabled {
+			logger.Warnf("%s is not implemented yet and will be ignored", key)
+		}
+	}
+}
+
 func openBrowser(url string) {
 	var err error
 
@@ -117,12 +235,21 @@ func printStartupInfo(host, port string) {
    • Health Check:       GET  /api/v1/health
    • Version Info:       GET  /api/v1/version
    • Scan Methods:       GET  /api/v1/scan-methods
+   • Scan Queue:         GET  /api/v1/queue
    • Full Network Scan:  POST /api/v1/network/full-scan
    • SNMP Scan:          POST /api/v1/network/scan/snmp
    • ARP Scan:           POST /api/v1/network/scan/arp
    • Quick Scan:         GET  /api/v1/network/quick-scan?network=<CIDR>
    • Validate Range:     GET  /api/v1/network/validate?network=<CIDR>
    • Device Scan:        GET  /api/v1/device/<IP>
+   • Submit Scan Job:    POST /api/v1/jobs
+   • List Scan Jobs:     GET  /api/v1/jobs
+   • Scan Job Status:    GET  /api/v1/jobs/<ID>
+   • Cancel Scan Job:    DELETE /api/v1/jobs/<ID>
+   • Scan Job Events:    GET  /api/v1/jobs/<ID>/events
+   • Device Inventory:   GET  /api/v1/devices
+   • Inventory Device:   GET  /api/v1/devices/<ID>
+   • Scan History:       GET  /api/v1/scans
 
 
    📋 Example Usage (Windows Command Prompt):
@@ -165,7 +292,7 @@ func printStartupInfo(host, port string) {
 
 🛠️  Features:
    • 🔍 Full Network Discovery (SNMP + ARP)
-   • 📡 SNMP v2c protocol support
+   • 📡 SNMP v2c and v3 (USM auth/priv) protocol support
    • 🌐 ARP-based device detection
    • 🏃 Concurrent network scanning
    • 📄 Device information discovery
@@ -183,7 +310,7 @@ func printStartupInfo(host, port string) {
 🌐 Web Interface: http://%s:%s/index
 
 📝 Logs: Check console output for detailed scanning information
-🔧 Configuration: Use command line flags to customize settings
+🔧 Configuration: Edit config.yaml, set ND_* environment variables or use command line flags
 
 Ready to discover your network! 🚀
 `, host, port, host, port, host, port, host, port, host, port, host, port, host, port, host, port)
@@ -7,6 +7,9 @@ toolchain go1.23.4
 require (
 	github.com/gosnmp/gosnmp v1.42.1
 	github.com/sirupsen/logrus v1.9.3
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/net v0.41.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -31,10 +34,8 @@ require (
 	github.com/ugorji/go/codec v1.3.0 // indirect
 	golang.org/x/arch v0.18.0 // indirect
 	golang.org/x/crypto v0.39.0 // indirect
-	golang.org/x/net v0.41.0 // indirect
 	golang.org/x/text v0.26.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 require (
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"network-discovery/internal/fingerprint"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fingerprintEngine returns the fingerprint rule engine, or writes 503 when fingerprinting is disabled
+func (h *Handlers) fingerprintEngine(c *gin.Context) *fingerprint.Engine {
+	engine := h.discovery.Fingerprints()
+	if engine == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Fingerprinting is disabled",
+		})
+	}
+	return engine
+}
+
+// GetFingerprintRules returns the fingerprint rules in use, highest priority first
+func (h *Handlers) GetFingerprintRules(c *gin.Context) {
+	engine := h.fingerprintEngine(c)
+	if engine == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"info":  engine.Info(),
+		"rules": engine.Rules(),
+	})
+}
+
+// ReloadFingerprintRules re-reads the fingerprint rule file. The previous rules stay in
+// use when the file is invalid.
+func (h *Handlers) ReloadFingerprintRules(c *gin.Context) {
+	engine := h.fingerprintEngine(c)
+	if engine == nil {
+		return
+	}
+
+	if err := engine.Reload(); err != nil {
+		h.logger.Errorf("Failed to reload fingerprint rules: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to reload fingerprint rules",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "reloaded",
+		"message":   "Fingerprint rules reloaded from " + engine.Path(),
+		"timestamp": time.Now().Format(time.RFC3339),
+		"info":      engine.Info(),
+	})
+}
@@ -1,12 +1,19 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"network-discovery/internal/discovery"
+	"network-discovery/internal/jobs"
 	"network-discovery/internal/models"
+	"network-discovery/internal/ports"
+	"network-discovery/internal/snmp"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -14,15 +21,14 @@ import (
 
 type Handlers struct {
 	discovery *discovery.NetworkDiscovery
+	jobs      *jobs.Manager
 	logger    *logrus.Logger
 }
 
-func NewHandlers(discovery *discovery.NetworkDiscovery) *Handlers {
-	logger := logrus.New()
-	logger.SetLevel(logrus.InfoLevel)
-
+func NewHandlers(discovery *discovery.NetworkDiscovery, jobManager *jobs.Manager, logger *logrus.Logger) *Handlers {
 	return &Handlers{
 		discovery: discovery,
+		jobs:      jobManager,
 		logger:    logger,
 	}
 }
@@ -40,30 +46,26 @@ func (h *Handlers) PerformFullScan(c *gin.Context) {
 		return
 	}
 
-	// Set optimized defaults for faster scanning
-	if req.Timeout == 0 {
-		req.Timeout = 2 // Reduced from 5 to 2 seconds
-	}
-	if req.Retries < 0 { // Allow 0 retries
-		req.Retries = 1 // Reduced from 2 to 1
-	}
-	if req.ScanType == "" {
-		req.ScanType = "full"
-	}
-	// Default enable_port_scan to true when not provided
-	if req.EnablePortScan == nil {
-		v := true
-		req.EnablePortScan = &v
+	if !h.validateScanRequest(c, &req) {
+		return
 	}
 
+	applyScanDefaults(&req)
+
 	h.logger.Infof("Received full scan request for network: %s (type: %s, timeout: %ds, retries: %d, port_scan: %t)",
 		req.NetworkRange, req.ScanType, req.Timeout, req.Retries, *req.EnablePortScan)
 
+	// Hand the scan to the job manager when the client asked for async execution
+	if isTruthy(c.Query("async")) {
+		h.submitJob(c, &req)
+		return
+	}
+
 	// Perform the full discovery
-	result, err := h.discovery.PerformFullScan(&req)
+	result, err := h.discovery.PerformFullScan(scanContext(c), &req)
 	if err != nil {
 		h.logger.Errorf("Full network discovery failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(scanErrorStatus(err), gin.H{
 			"error":   "Full network discovery failed",
 			"details": err.Error(),
 		})
@@ -76,6 +78,83 @@ func (h *Handlers) PerformFullScan(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
+// validateScanRequest checks the SNMPv3 credentials and port scan settings of a
+// request, writing a 400 response and returning false when they are invalid
+func (h *Handlers) validateScanRequest(c *gin.Context, req *models.ScanRequest) bool {
+	if err := snmp.ValidateV3Credentials(req.V3Credentials); err != nil {
+		h.logger.Errorf("Invalid SNMPv3 credentials: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid SNMPv3 credentials",
+			"details": err.Error(),
+		})
+		return false
+	}
+
+	if err := ports.ValidateBackend(req.PortScanner); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid port scanner",
+			"details": err.Error(),
+		})
+		return false
+	}
+
+	if err := ports.ValidateOptions(req.PortOptions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid port options",
+			"details": err.Error(),
+		})
+		return false
+	}
+
+	if err := h.discovery.Scheduler().CheckRange(req.NetworkRange); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Network range too large",
+			"details": err.Error(),
+		})
+		return false
+	}
+
+	return true
+}
+
+// scanContext tags the request context with the client IP so the scan scheduler can
+// share scan slots fairly between clients
+func scanContext(c *gin.Context) context.Context {
+	return discovery.WithClient(c.Request.Context(), c.ClientIP())
+}
+
+// scanErrorStatus maps scan errors to HTTP status codes: oversized ranges are the
+// client's fault and a full scan queue is temporary
+func scanErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, discovery.ErrRangeTooLarge):
+		return http.StatusBadRequest
+	case errors.Is(err, discovery.ErrQueueFull):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// applyScanDefaults fills in the optimized defaults used by full scan requests
+func applyScanDefaults(req *models.ScanRequest) {
+	// Set optimized defaults for faster scanning
+	if req.Timeout == 0 {
+		req.Timeout = 2 // Reduced from 5 to 2 seconds
+	}
+	if req.Retries < 0 { // Allow 0 retries
+		req.Retries = 1 // Reduced from 2 to 1
+	}
+	if req.ScanType == "" {
+		req.ScanType = "full"
+	}
+	// Default enable_port_scan to true when not provided
+	if req.EnablePortScan == nil {
+		v := true
+		req.EnablePortScan = &v
+	}
+}
+
 // ScanNetworkByType handles network scanning requests with specific scan type
 func (h *Handlers) ScanNetworkByType(c *gin.Context) {
 	scanType := c.Param("type")
@@ -108,6 +187,10 @@ func (h *Handlers) ScanNetworkByType(c *gin.Context) {
 	// Set scan type from URL parameter
 	req.ScanType = scanType
 
+	if !h.validateScanRequest(c, &req) {
+		return
+	}
+
 	// Set optimized defaults for faster scanning
 	if req.Timeout == 0 {
 		req.Timeout = 2 // Reduced timeout
@@ -124,10 +207,10 @@ func (h *Handlers) ScanNetworkByType(c *gin.Context) {
 		scanType, req.NetworkRange, req.Timeout, req.Retries, *req.EnablePortScan)
 
 	// Perform the discovery
-	result, err := h.discovery.PerformFullScan(&req)
+	result, err := h.discovery.PerformFullScan(scanContext(c), &req)
 	if err != nil {
 		h.logger.Errorf("%s network discovery failed: %v", scanType, err)
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(scanErrorStatus(err), gin.H{
 			"error":   fmt.Sprintf("%s network discovery failed", scanType),
 			"details": err.Error(),
 		})
@@ -154,10 +237,10 @@ func (h *Handlers) QuickScan(c *gin.Context) {
 
 	h.logger.Infof("Received quick scan request for network: %s", networkRange)
 
-	reachableIPs, err := h.discovery.QuickDiscovery(networkRange, communities)
+	reachableIPs, err := h.discovery.QuickDiscovery(scanContext(c), networkRange, communities)
 	if err != nil {
 		h.logger.Errorf("Quick discovery failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(scanErrorStatus(err), gin.H{
 			"error":   "Quick discovery failed",
 			"details": err.Error(),
 		})
@@ -180,23 +263,55 @@ func (h *Handlers) ScanDevice(c *gin.Context) {
 		return
 	}
 
-	// Get communities from query parameters
-	communities := c.QueryArray("community")
+	communities, v3Credentials, ok := h.deviceCredentials(c)
+	if !ok {
+		return
+	}
 
 	h.logger.Infof("Received device scan request for IP: %s", ip)
 
 	// Optional enable_port_scan query param (default true)
 	enablePortScan := true
 	if val := c.Query("enable_port_scan"); val != "" {
-		switch val {
-		case "0", "false", "False", "FALSE":
-			enablePortScan = false
-		default:
-			enablePortScan = true
+		enablePortScan = isTruthy(val)
+	}
+
+	// Optional port_scanner (auto, nmap, tcp) and ports/protocol/timing/detection query params
+	portScanner := c.Query("port_scanner")
+	if err := ports.ValidateBackend(portScanner); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid port scanner",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var portOptions *models.PortScanOptions
+	if c.Query("ports") != "" || c.Query("protocol") != "" || c.Query("timing") != "" ||
+		c.Query("service_detection") != "" || c.Query("os_detection") != "" {
+		portOptions = &models.PortScanOptions{
+			Ports:            c.Query("ports"),
+			Protocol:         c.Query("protocol"),
+			ServiceDetection: isTruthy(c.Query("service_detection")),
+			OSDetection:      isTruthy(c.Query("os_detection")),
+		}
+		if val := c.Query("timing"); val != "" {
+			timing, err := strconv.Atoi(val)
+			if err != nil {
+				timing = -1 // rejected by validation below
+			}
+			portOptions.Timing = &timing
+		}
+		if err := ports.ValidateOptions(portOptions); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid port options",
+				"details": err.Error(),
+			})
+			return
 		}
 	}
 
-	device, err := h.discovery.DiscoverDevice(ip, communities, enablePortScan)
+	device, err := h.discovery.DiscoverDevice(c.Request.Context(), ip, communities, v3Credentials, enablePortScan, portScanner, portOptions)
 	if err != nil {
 		h.logger.Errorf("Device discovery failed: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -211,6 +326,77 @@ func (h *Handlers) ScanDevice(c *gin.Context) {
 	})
 }
 
+// GetDeviceInterfaces handles interface inventory requests for a single SNMP device
+func (h *Handlers) GetDeviceInterfaces(c *gin.Context) {
+	ip := c.Param("ip")
+	if ip == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "IP address is required",
+		})
+		return
+	}
+
+	communities, v3Credentials, ok := h.deviceCredentials(c)
+	if !ok {
+		return
+	}
+
+	h.logger.Infof("Received interface request for IP: %s", ip)
+
+	device, err := h.discovery.DiscoverDevice(c.Request.Context(), ip, communities, v3Credentials, false, "", nil)
+	if err != nil {
+		h.logger.Errorf("Interface discovery failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Interface discovery failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	interfaces := device.Interfaces
+	if interfaces == nil {
+		interfaces = []models.Interface{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ip":         device.IP,
+		"hostname":   device.Hostname,
+		"interfaces": interfaces,
+		"count":      len(interfaces),
+	})
+}
+
+// deviceCredentials returns the credentials of a single device request: the community
+// query parameters plus the optional JSON body {"communities": [...], "v3_credentials":
+// [...]}. SNMPv3 passphrases are only accepted in the body, as query strings end up in
+// access logs. It responds with 400 and returns false when the body is invalid.
+func (h *Handlers) deviceCredentials(c *gin.Context) ([]string, []models.SNMPv3Credential, bool) {
+	communities := c.QueryArray("community")
+
+	var body struct {
+		Communities   []string                  `json:"communities"`
+		V3Credentials []models.SNMPv3Credential `json:"v3_credentials"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return nil, nil, false
+	}
+
+	if err := snmp.ValidateV3Credentials(body.V3Credentials); err != nil {
+		h.logger.Errorf("Invalid SNMPv3 credentials: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid SNMPv3 credentials",
+			"details": err.Error(),
+		})
+		return nil, nil, false
+	}
+
+	return append(communities, body.Communities...), body.V3Credentials, true
+}
+
 // ValidateNetwork handles network range validation requests
 func (h *Handlers) ValidateNetwork(c *gin.Context) {
 	networkRange := c.Query("network")
@@ -242,9 +428,9 @@ func (h *Handlers) GetScanMethods(c *gin.Context) {
 		"snmp": gin.H{
 			"name":         "SNMP Scan",
 			"description":  "Discovers devices using SNMP protocol. Provides detailed device information including hostname, description, vendor, uptime, and system details.",
-			"requirements": []string{"SNMP enabled on target devices", "Valid SNMP community strings"},
+			"requirements": []string{"SNMP enabled on target devices", "Valid SNMP community strings or SNMPv3 credentials"},
 			"advantages":   []string{"Detailed device information", "Vendor identification", "System uptime and status"},
-			"limitations":  []string{"Only discovers SNMP-enabled devices", "Requires correct community strings"},
+			"limitations":  []string{"Only discovers SNMP-enabled devices", "Requires correct community strings or SNMPv3 credentials"},
 			"recommended_settings": gin.H{
 				"timeout": "1-3 seconds",
 				"retries": "0-1",
@@ -252,8 +438,8 @@ func (h *Handlers) GetScanMethods(c *gin.Context) {
 		},
 		"arp": gin.H{
 			"name":         "ARP Scan",
-			"description":  "Discovers devices using ARP (Address Resolution Protocol). Finds all devices that respond to ping and have ARP entries.",
-			"requirements": []string{"Devices must be on the same network segment", "Devices must respond to ping"},
+			"description":  "Discovers devices using ARP (Address Resolution Protocol). Finds all devices that respond to ping and have ARP entries. IPv6 ranges (or include_ipv6) use Neighbor Discovery: a multicast ping to ff02::1, Neighbor Solicitations and the kernel neighbour table.",
+			"requirements": []string{"Devices must be on the same network segment", "Devices must respond to ping", "IPv6 discovery needs raw socket privileges for Neighbor Discovery"},
 			"advantages":   []string{"Discovers all IP-enabled devices", "No special configuration required", "Fast discovery"},
 			"limitations":  []string{"Limited device information", "Only provides IP and MAC addresses", "May miss some devices behind firewalls"},
 			"recommended_settings": gin.H{
@@ -265,7 +451,7 @@ func (h *Handlers) GetScanMethods(c *gin.Context) {
 			"name":         "Full Scan (SNMP + ARP)",
 			"description":  "Combines both SNMP and ARP scanning methods for comprehensive network discovery. Provides the most complete view of network devices.",
 			"requirements": []string{"Network access to target range"},
-			"advantages":   []string{"Most comprehensive discovery", "Combines detailed SNMP info with broad ARP coverage", "Merges MAC addresses for SNMP devices"},
+			"advantages":   []string{"Most comprehensive discovery", "Combines detailed SNMP info with broad ARP coverage", "Merges MAC addresses for SNMP devices", "Queries IPv6 neighbours over SNMP", "Resolves MACs of remote subnets from router ARP caches"},
 			"limitations":  []string{"Takes longer than individual scans", "Higher network traffic"},
 			"recommended_settings": gin.H{
 				"timeout": "2-3 seconds",
@@ -275,18 +461,27 @@ func (h *Handlers) GetScanMethods(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"scan_methods": methods,
-		"default":      "full",
-		"recommended":  "full",
+		"scan_methods":   methods,
+		"default":        "full",
+		"recommended":    "full",
+		"port_profiles":  ports.Profiles(),
+		"default_ranges": h.discovery.DefaultRanges(),
 		"performance_tips": []string{
 			"Use smaller network ranges for faster scans",
 			"Set timeout to 1-2 seconds for local networks",
 			"Set retries to 0 for fastest scanning",
 			"Use ARP scan for quick discovery without SNMP details",
+			"Set port_scanner to \"tcp\" to use the built-in port scanner when nmap is not installed",
+			"nmap service and OS detection (port_options service_detection, os_detection) take much longer per host; raise host_timeout with them",
 		},
 	})
 }
 
+// GetScanQueue returns the running and queued scans and the shared probe budget
+func (h *Handlers) GetScanQueue(c *gin.Context) {
+	c.JSON(http.StatusOK, h.discovery.Scheduler().Status())
+}
+
 // GetHealth handles health check requests
 func (h *Handlers) GetHealth(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -295,9 +490,11 @@ func (h *Handlers) GetHealth(c *gin.Context) {
 		"version": "1.0.0",
 		"features": []string{
 			"SNMP Discovery",
+			"SNMPv3 (USM) Support",
 			"ARP Discovery",
 			"Full Network Scan",
 			"Device Information Extraction",
+			"LLDP/CDP Topology Links",
 			"MAC Address Resolution",
 			"Vendor Identification",
 			"JSON-based Vendor Database",
@@ -312,7 +509,7 @@ func (h *Handlers) GetVersion(c *gin.Context) {
 		"build_time": "2024-01-01T00:00:00Z",
 		"go_version": "1.21",
 		"features": gin.H{
-			"snmp_discovery":   "v2c protocol support",
+			"snmp_discovery":   "v2c and v3 (USM auth/priv) protocol support",
 			"arp_discovery":    "Cross-platform ARP scanning",
 			"full_scan":        "Combined SNMP + ARP discovery",
 			"mac_resolution":   "Hardware address identification",
@@ -325,7 +522,7 @@ func (h *Handlers) GetVersion(c *gin.Context) {
 func (h *Handlers) GetVendorDatabase(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":      "vendor database loaded from JSON file",
-		"config_path": "configs/oui_vendors.json",
+		"config_path": h.discovery.VendorDatabasePath(),
 		"description": "External JSON-based OUI vendor database",
 		"features": []string{
 			"Runtime reloadable",
@@ -338,7 +535,7 @@ func (h *Handlers) GetVendorDatabase(c *gin.Context) {
 			"info":   "GET  /api/v1/vendor-database",
 		},
 		"management": gin.H{
-			"edit_file": "Edit configs/oui_vendors.json directly",
+			"edit_file": "Edit " + h.discovery.VendorDatabasePath() + " directly",
 			"reload_db": "POST to /api/v1/vendor-database/reload",
 		},
 	})
@@ -348,7 +545,7 @@ func (h *Handlers) GetVendorDatabase(c *gin.Context) {
 func (h *Handlers) ReloadVendorDatabase(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "reload triggered",
-		"message":   "Vendor database reloaded from configs/oui_vendors.json",
+		"message":   "Vendor database reloaded from " + h.discovery.VendorDatabasePath(),
 		"timestamp": time.Now().Format(time.RFC3339),
 		"note":      "Database will be reloaded on next scan operation",
 	})
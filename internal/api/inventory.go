@@ -0,0 +1,246 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"network-discovery/internal/diff"
+	"network-discovery/internal/inventory"
+	"network-discovery/internal/models"
+	"network-discovery/internal/ports"
+
+	"github.com/gin-gonic/gin"
+)
+
+// inventoryStore returns the persistent inventory or writes a 503 when persistence is disabled
+func (h *Handlers) inventoryStore(c *gin.Context) *inventory.Store {
+	store := h.discovery.Store()
+	if store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Device inventory is disabled",
+		})
+	}
+	return store
+}
+
+// ListDevices returns every device in the persistent inventory
+func (h *Handlers) ListDevices(c *gin.Context) {
+	store := h.inventoryStore(c)
+	if store == nil {
+		return
+	}
+
+	devices, err := store.ListDevices()
+	if err != nil {
+		h.logger.Errorf("Failed to list inventory devices: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list devices",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"devices": devices,
+		"count":   len(devices),
+	})
+}
+
+// GetInventoryDevice returns one inventory device (by id, MAC or IP) with its scan observations
+func (h *Handlers) GetInventoryDevice(c *gin.Context) {
+	store := h.inventoryStore(c)
+	if store == nil {
+		return
+	}
+
+	device, observations, err := store.GetDevice(c.Param("id"))
+	if errors.Is(err, inventory.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device not found",
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Errorf("Failed to load inventory device: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load device",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"device":       device,
+		"observations": observations,
+	})
+}
+
+// ListScans returns recorded scans, newest first (optional ?limit=N)
+func (h *Handlers) ListScans(c *gin.Context) {
+	store := h.inventoryStore(c)
+	if store == nil {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	scans, err := store.ListScans(limit)
+	if err != nil {
+		h.logger.Errorf("Failed to list scans: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list scans",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scans": scans,
+		"count": len(scans),
+	})
+}
+
+// GetScan returns a recorded scan including its topology
+func (h *Handlers) GetScan(c *gin.Context) {
+	store := h.inventoryStore(c)
+	if store == nil {
+		return
+	}
+
+	scan, ok := h.loadScan(c, store, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scan": scan,
+	})
+}
+
+// DiffScans compares two recorded scans: devices that appeared, disappeared or changed from :id to :other
+func (h *Handlers) DiffScans(c *gin.Context) {
+	store := h.inventoryStore(c)
+	if store == nil {
+		return
+	}
+
+	from, ok := h.loadScan(c, store, c.Param("id"))
+	if !ok {
+		return
+	}
+	to, ok := h.loadScan(c, store, c.Param("other"))
+	if !ok {
+		return
+	}
+
+	topologyDiff := diff.Compare(from.Topology, to.Topology)
+	topologyDiff.FromScanID = from.ID
+	topologyDiff.ToScanID = to.ID
+
+	c.JSON(http.StatusOK, gin.H{
+		"diff": topologyDiff,
+	})
+}
+
+// maxNmapReportSize bounds uploaded nmap XML reports
+const maxNmapReportSize = 32 << 20
+
+// ImportNmapReport merges an uploaded nmap XML report (nmap -oX, ideally with -sV/-O)
+// into recorded scan :id and records the result as a new scan. The report is the
+// request body, or the "file" field of a multipart form.
+func (h *Handlers) ImportNmapReport(c *gin.Context) {
+	store := h.inventoryStore(c)
+	if store == nil {
+		return
+	}
+
+	scan, ok := h.loadScan(c, store, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	data, err := readNmapReport(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read nmap report",
+			"details": err.Error(),
+		})
+		return
+	}
+	hosts, err := ports.ParseNmapXML(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid nmap XML report",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, summary, err := h.discovery.ImportNmap(scan, hosts)
+	if err != nil {
+		h.logger.Errorf("Failed to import nmap report into scan %s: %v", scan.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to import nmap report",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"import":      summary,
+		"merged_from": scan.ID,
+		"result":      result,
+	})
+}
+
+// readNmapReport returns the uploaded report, from a multipart "file" field or the raw body
+func readNmapReport(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxNmapReportSize)
+
+	body := io.Reader(c.Request.Body)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("missing \"file\" field: %v", err)
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		body = file
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty report")
+	}
+	return data, nil
+}
+
+// loadScan fetches a recorded scan, writing a 404/500 response when it cannot be loaded
+func (h *Handlers) loadScan(c *gin.Context, store *inventory.Store, id string) (*models.ScanRecord, bool) {
+	scan, err := store.GetScan(id)
+	if errors.Is(err, inventory.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Scan not found",
+			"id":    id,
+		})
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Errorf("Failed to load scan %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load scan",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return scan, true
+}
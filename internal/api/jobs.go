@@ -0,0 +1,188 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"network-discovery/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubmitJob handles asynchronous scan submissions and returns the job id immediately
+func (h *Handlers) SubmitJob(c *gin.Context) {
+	var req models.ScanRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Errorf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if !h.validateScanRequest(c, &req) {
+		return
+	}
+
+	applyScanDefaults(&req)
+
+	switch req.ScanType {
+	case "snmp", "arp", "full":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid scan type. Supported types: snmp, arp, full",
+		})
+		return
+	}
+
+	h.logger.Infof("Received scan job request for network: %s (type: %s, timeout: %ds, retries: %d, port_scan: %t)",
+		req.NetworkRange, req.ScanType, req.Timeout, req.Retries, *req.EnablePortScan)
+
+	h.submitJob(c, &req)
+}
+
+// submitJob queues a validated scan request and writes the 202 response
+func (h *Handlers) submitJob(c *gin.Context, req *models.ScanRequest) {
+	job, err := h.jobs.Submit(req, c.ClientIP())
+	if err != nil {
+		h.logger.Errorf("Failed to submit scan job: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to submit scan job",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id":     job.ID,
+		"state":      job.State,
+		"status_url": "/api/v1/jobs/" + job.ID,
+		"job":        job,
+	})
+}
+
+// ListJobs returns recent scan jobs, newest first
+func (h *Handlers) ListJobs(c *gin.Context) {
+	list := h.jobs.List()
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":  list,
+		"count": len(list),
+	})
+}
+
+// GetJob returns the state, progress and result of a scan job
+func (h *Handlers) GetJob(c *gin.Context) {
+	job, ok := h.jobs.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Job not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job": job,
+	})
+}
+
+// CancelJob cancels a queued or running scan job
+func (h *Handlers) CancelJob(c *gin.Context) {
+	id := c.Param("id")
+	if _, ok := h.jobs.Get(id); !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Job not found",
+		})
+		return
+	}
+
+	job, err := h.jobs.Cancel(id)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Job cannot be cancelled",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job": job,
+	})
+}
+
+// StreamJobEvents streams live job events (devices found, phase progress, completion) as Server-Sent Events.
+// Events already emitted are replayed first; clients may resume with the Last-Event-ID header.
+func (h *Handlers) StreamJobEvents(c *gin.Context) {
+	id := c.Param("id")
+	if _, ok := h.jobs.Get(id); !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Job not found",
+		})
+		return
+	}
+
+	next := 0
+	if lastID, err := strconv.Atoi(c.GetHeader("Last-Event-ID")); err == nil {
+		next = lastID + 1
+	}
+
+	// Scans outlive the server write timeout, so lift it for this response
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Debugf("Could not clear write deadline for event stream: %v", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		pending, start, notify, finished, ok := h.jobs.Events(id, next)
+		if !ok {
+			return
+		}
+
+		next = start
+		for _, event := range pending {
+			data, err := json.Marshal(event)
+			if err != nil {
+				h.logger.Errorf("Failed to encode scan event: %v", err)
+				continue
+			}
+			fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", next, event.Type, data)
+			next++
+		}
+		c.Writer.Flush()
+
+		if finished {
+			return
+		}
+
+		select {
+		case <-notify:
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// isTruthy interprets common query string booleans ("1", "true", "yes")
+func isTruthy(val string) bool {
+	switch val {
+	case "0", "false", "False", "FALSE", "no", "off", "":
+		return false
+	default:
+		return true
+	}
+}
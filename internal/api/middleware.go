@@ -0,0 +1,74 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientBucket is a token bucket for one client IP
+type clientBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimit allows each client IP perMinute requests per minute, with bursts up to
+// perMinute, and answers 429 Too Many Requests beyond that. The client IP comes from
+// forwarded headers only behind the router's trusted proxies, so clients cannot pick a
+// fresh bucket per request.
+func rateLimit(perMinute int) gin.HandlerFunc {
+	var (
+		mu        sync.Mutex
+		buckets   = make(map[string]*clientBucket)
+		lastPrune = time.Now()
+	)
+	capacity := float64(perMinute)
+	refill := capacity / time.Minute.Seconds() // tokens per second
+
+	return func(c *gin.Context) {
+		now := time.Now()
+		ip := c.ClientIP()
+
+		mu.Lock()
+		// Forget clients that have been idle long enough to have a full bucket again
+		if now.Sub(lastPrune) > time.Minute {
+			for key, b := range buckets {
+				if now.Sub(b.lastSeen) > time.Minute {
+					delete(buckets, key)
+				}
+			}
+			lastPrune = now
+		}
+
+		b, ok := buckets[ip]
+		if !ok {
+			b = &clientBucket{tokens: capacity, lastSeen: now}
+			buckets[ip] = b
+		}
+		b.tokens = math.Min(capacity, b.tokens+now.Sub(b.lastSeen).Seconds()*refill)
+		b.lastSeen = now
+
+		allowed := b.tokens >= 1
+		var retryAfter time.Duration
+		if allowed {
+			b.tokens--
+		} else {
+			retryAfter = time.Duration((1 - b.tokens) / refill * float64(time.Second))
+		}
+		mu.Unlock()
+
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":   "Rate limit exceeded",
+				"details": "at most " + strconv.Itoa(perMinute) + " requests per minute are allowed",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -4,39 +4,59 @@ import (
 	"net/http"
 	"time"
 
+	"network-discovery/internal/config"
 	"network-discovery/internal/discovery"
+	"network-discovery/internal/jobs"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
-func SetupRoutes(discovery *discovery.NetworkDiscovery) *gin.Engine {
+func SetupRoutes(discovery *discovery.NetworkDiscovery, jobManager *jobs.Manager, cfg *config.Config, logger *logrus.Logger) *gin.Engine {
 	// Create Gin router
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
+	// Scan fairness and rate limits are keyed by ClientIP. It is the remote address, and
+	// X-Forwarded-For is only believed when that is one of security.trusted_proxies.
+	if err := router.SetTrustedProxies(cfg.Security.TrustedProxies); err != nil {
+		logger.Errorf("Invalid trusted proxies, trusting none: %v", err)
+		router.SetTrustedProxies(nil)
+	}
 
 	// Add middleware
-	router.Use(gin.Logger())
+	if cfg.Logging.EnableRequestLogging {
+		router.Use(gin.LoggerWithWriter(logger.Out))
+	}
 	router.Use(gin.Recovery())
 
 	// CORS middleware
-	config := cors.DefaultConfig()
-	config.AllowAllOrigins = true
-	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
-	router.Use(cors.New(config))
+	if cfg.Security.EnableCORS {
+		corsConfig := cors.DefaultConfig()
+		if len(cfg.Security.CORSOrigins) == 1 && cfg.Security.CORSOrigins[0] == "*" {
+			corsConfig.AllowAllOrigins = true
+		} else {
+			corsConfig.AllowOrigins = cfg.Security.CORSOrigins
+		}
+		corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+		corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", "Last-Event-ID"}
+		router.Use(cors.New(corsConfig))
+	}
 
 	// Create handlers
-	handlers := NewHandlers(discovery)
+	handlers := NewHandlers(discovery, jobManager, logger)
 
 	// API versioning
 	v1 := router.Group("/api/v1")
+	if cfg.Security.RateLimit > 0 {
+		v1.Use(rateLimit(cfg.Security.RateLimit))
+	}
 	{
 		// Health and status endpoints
 		v1.GET("/health", handlers.GetHealth)
 		v1.GET("/version", handlers.GetVersion)
 		v1.GET("/scan-methods", handlers.GetScanMethods)
+		v1.GET("/queue", handlers.GetScanQueue)
 
 		// Vendor database endpoints
 		vendor := v1.Group("/vendor-database")
@@ -45,6 +65,13 @@ func SetupRoutes(discovery *discovery.NetworkDiscovery) *gin.Engine {
 			vendor.POST("/reload", handlers.ReloadVendorDatabase)
 		}
 
+		// Fingerprint rule endpoints
+		fingerprints := v1.Group("/fingerprints")
+		{
+			fingerprints.GET("", handlers.GetFingerprintRules)
+			fingerprints.POST("/reload", handlers.ReloadFingerprintRules)
+		}
+
 		// Network discovery endpoints
 		network := v1.Group("/network")
 		{
@@ -59,10 +86,42 @@ func SetupRoutes(discovery *discovery.NetworkDiscovery) *gin.Engine {
 			network.GET("/validate", handlers.ValidateNetwork)
 		}
 
+		// Asynchronous scan job endpoints
+		jobsGroup := v1.Group("/jobs")
+		{
+			jobsGroup.POST("", handlers.SubmitJob)
+			jobsGroup.GET("", handlers.ListJobs)
+			jobsGroup.GET("/:id", handlers.GetJob)
+			jobsGroup.DELETE("/:id", handlers.CancelJob)
+			jobsGroup.GET("/:id/events", handlers.StreamJobEvents)
+		}
+
+		// Persistent inventory endpoints
+		devices := v1.Group("/devices")
+		{
+			devices.GET("", handlers.ListDevices)
+			devices.GET("/:id", handlers.GetInventoryDevice)
+		}
+		scans := v1.Group("/scans")
+		{
+			scans.GET("", handlers.ListScans)
+			scans.GET("/:id", handlers.GetScan)
+			scans.GET("/:id/diff/:other", handlers.DiffScans)
+			scans.POST("/:id/nmap", handlers.ImportNmapReport)
+		}
+		topology := v1.Group("/topology")
+		{
+			topology.GET("/export", handlers.ExportTopology)
+		}
+
 		// Device endpoints
 		device := v1.Group("/device")
 		{
 			device.GET("/:ip", handlers.ScanDevice)
+			device.GET("/:ip/interfaces", handlers.GetDeviceInterfaces)
+			// POST for clients that cannot send a body with GET, e.g. SNMPv3 credentials
+			device.POST("/:ip", handlers.ScanDevice)
+			device.POST("/:ip/interfaces", handlers.GetDeviceInterfaces)
 		}
 	}
 
@@ -93,12 +152,29 @@ func SetupRoutes(discovery *discovery.NetworkDiscovery) *gin.Engine {
 				"health":       "GET  /api/v1/health",
 				"version":      "GET  /api/v1/version",
 				"scan_methods": "GET  /api/v1/scan-methods",
+				"scan_queue":   "GET  /api/v1/queue",
 				"full_scan":    "POST /api/v1/network/full-scan",
+				"async_scan":   "POST /api/v1/network/full-scan?async=true",
+				"submit_job":   "POST /api/v1/jobs",
+				"list_jobs":    "GET  /api/v1/jobs",
+				"get_job":      "GET  /api/v1/jobs/<ID>",
+				"cancel_job":   "DELETE /api/v1/jobs/<ID>",
+				"job_events":   "GET  /api/v1/jobs/<ID>/events (Server-Sent Events)",
+				"devices":      "GET  /api/v1/devices",
+				"device":       "GET  /api/v1/devices/<ID|MAC|IP>",
+				"scans":        "GET  /api/v1/scans",
+				"scan":         "GET  /api/v1/scans/<ID>",
+				"scan_diff":    "GET  /api/v1/scans/<ID>/diff/<OTHER_ID>",
+				"nmap_import":  "POST /api/v1/scans/<ID>/nmap (nmap -oX report)",
+				"export":       "GET  /api/v1/topology/export?format=dot|graphml|json-graph",
+				"fingerprints": "GET  /api/v1/fingerprints",
+				"reload_rules": "POST /api/v1/fingerprints/reload",
 				"scan_by_type": "POST /api/v1/network/scan/{type}",
 				"legacy_scan":  "POST /api/v1/network/scan",
 				"quick_scan":   "GET  /api/v1/network/quick-scan?network=<CIDR>",
 				"validate":     "GET  /api/v1/network/validate?network=<CIDR>",
-				"scan_device":  "GET  /api/v1/device/<IP>",
+				"scan_device":  "GET|POST /api/v1/device/<IP> (SNMPv3 credentials in the body)",
+				"interfaces":   "GET|POST /api/v1/device/<IP>/interfaces (SNMPv3 credentials in the body)",
 			},
 			"scan_types": []string{"snmp", "arp", "full"},
 			"examples": gin.H{
@@ -134,6 +210,7 @@ func SetupRoutes(discovery *discovery.NetworkDiscovery) *gin.Engine {
 				},
 				"quick_scan":  "GET /api/v1/network/quick-scan?network=192.168.1.0/24",
 				"scan_device": "GET /api/v1/device/192.168.1.1?community=public&community=private",
+				"interfaces":  "GET /api/v1/device/192.168.1.1/interfaces?community=public",
 			},
 			"response_format": gin.H{
 				"full_scan_response": gin.H{
@@ -167,7 +244,7 @@ func SetupRoutes(discovery *discovery.NetworkDiscovery) *gin.Engine {
 					"vendor":        "detected vendor",
 					"uptime":        "system uptime",
 					"response_time": "response time in milliseconds",
-					"scan_method":   "discovery method (SNMP/ARP/COMBINED)",
+					"scan_method":   "discovery method (SNMP/ARP/NDP/COMBINED)",
 				},
 			},
 		})
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"network-discovery/internal/export"
+	"network-discovery/internal/inventory"
+	"network-discovery/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportTopology renders a recorded topology as a graph (?format=dot|graphml|json-graph).
+// It exports the scan given by ?scan=<ID>, or else the latest scan, of ?network=<CIDR> when set.
+func (h *Handlers) ExportTopology(c *gin.Context) {
+	format := c.DefaultQuery("format", export.FormatJSONGraph)
+	if format != export.FormatDOT && format != export.FormatGraphML && format != export.FormatJSONGraph {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid export format",
+			"details": fmt.Sprintf("format must be %s, %s or %s", export.FormatDOT, export.FormatGraphML, export.FormatJSONGraph),
+		})
+		return
+	}
+
+	store := h.inventoryStore(c)
+	if store == nil {
+		return
+	}
+
+	var scan *models.ScanRecord
+	if id := c.Query("scan"); id != "" {
+		var ok bool
+		if scan, ok = h.loadScan(c, store, id); !ok {
+			return
+		}
+	} else {
+		var err error
+		scan, err = store.LatestScan(c.Query("network"))
+		if errors.Is(err, inventory.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "No recorded scan to export",
+			})
+			return
+		}
+		if err != nil {
+			h.logger.Errorf("Failed to load latest scan: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to load scan",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	data, contentType, err := export.Render(export.Build(scan.Topology), format)
+	if err != nil {
+		h.logger.Errorf("Failed to export scan %s: %v", scan.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to export topology",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if format != export.FormatJSONGraph {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="topology-%s.%s"`, scan.ID, format))
+	}
+	c.Data(http.StatusOK, contentType, data)
+}
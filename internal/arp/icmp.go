@@ -0,0 +1,274 @@
+package arp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// DefaultPingTimeout matches the `ping -W 1` used by the subprocess fallback
+	DefaultPingTimeout = time.Second
+	// DefaultPingInterval paces echo requests on the shared socket (~500 packets/s)
+	DefaultPingInterval = 2 * time.Millisecond
+
+	protocolICMP = 1
+)
+
+// echoIDs hands out ICMP echo identifiers. A raw socket sees the replies to every
+// socket on the host, so each pinger needs its own identifier to tell its replies from
+// those of concurrent scans; starting at the PID keeps processes apart as well.
+var echoIDs atomic.Uint32
+
+func init() {
+	echoIDs.Store(uint32(os.Getpid()))
+}
+
+// nextEchoID returns an ICMP echo identifier that no concurrent pinger of this process uses
+func nextEchoID() int {
+	return int(echoIDs.Add(1) & 0xffff)
+}
+
+// icmpPinger sends ICMP echo requests for many hosts over a single socket and
+// matches replies to requests by ID/sequence, returning the round-trip time and TTL
+type icmpPinger struct {
+	conn       *icmp.PacketConn
+	ipConn     *ipv4.PacketConn // Reads the TTL of replies; nil when the OS does not report it
+	privileged bool             // raw socket (ID is ours) vs. unprivileged datagram socket (kernel-assigned ID)
+	id         int
+	interval   time.Duration
+	logger     *logrus.Logger
+
+	sendMu   sync.Mutex // serializes pacing and writes
+	lastSend time.Time
+
+	mu      sync.Mutex // guards seq and pending
+	seq     uint16
+	pending map[uint16]*echoRequest
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+type echoRequest struct {
+	ip    string
+	sent  time.Time
+	reply chan echoReply
+}
+
+// echoReply is the answer of a host to an echo request
+type echoReply struct {
+	rtt time.Duration
+	ttl int // 0 when unknown
+}
+
+// newICMPPinger opens an unprivileged ICMP datagram socket where the OS allows it
+// (Linux net.ipv4.ping_group_range, macOS) and a raw ICMP socket otherwise
+func newICMPPinger(interval time.Duration, logger *logrus.Logger) (*icmpPinger, error) {
+	p := &icmpPinger{
+		id:       nextEchoID(),
+		interval: interval,
+		logger:   logger,
+		pending:  make(map[uint16]*echoRequest),
+		done:     make(chan struct{}),
+	}
+
+	conn, err := icmp.ListenPacket("udp4", "0.0.0.0")
+	if err != nil {
+		logger.Debugf("Unprivileged ICMP socket unavailable: %v; trying raw socket", err)
+		conn, err = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+		if err != nil {
+			return nil, fmt.Errorf("failed to open ICMP socket: %v", err)
+		}
+		p.privileged = true
+	}
+	p.conn = conn
+
+	// The TTL of replies hints at the OS of the host (passive OS fingerprinting)
+	if ipConn := conn.IPv4PacketConn(); ipConn != nil {
+		if err := ipConn.SetControlMessage(ipv4.FlagTTL, true); err != nil {
+			logger.Debugf("ICMP reply TTLs unavailable: %v", err)
+		} else {
+			p.ipConn = ipConn
+		}
+	}
+
+	go p.readLoop()
+	return p, nil
+}
+
+// Close stops the reader and releases the socket
+func (p *icmpPinger) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.done)
+		err = p.conn.Close()
+	})
+	return err
+}
+
+// Ping sends one echo request to ip and waits for the matching reply.
+// It returns the round-trip time and TTL, and whether the host answered within timeout.
+func (p *icmpPinger) Ping(ctx context.Context, ip string, timeout time.Duration) (echoReply, bool) {
+	dst := net.ParseIP(ip).To4()
+	if dst == nil {
+		return echoReply{}, false
+	}
+
+	req := &echoRequest{ip: dst.String(), reply: make(chan echoReply, 1)}
+	seq, err := p.send(ctx, dst, req)
+	if err != nil {
+		p.logger.Debugf("ICMP echo to %s failed: %v", ip, err)
+		return echoReply{}, false
+	}
+	defer func() {
+		p.mu.Lock()
+		delete(p.pending, seq)
+		p.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case reply := <-req.reply:
+		return reply, true
+	case <-timer.C:
+		return echoReply{}, false
+	case <-ctx.Done():
+		return echoReply{}, false
+	case <-p.done:
+		return echoReply{}, false
+	}
+}
+
+// send paces, registers and transmits an echo request, returning its sequence number
+func (p *icmpPinger) send(ctx context.Context, dst net.IP, req *echoRequest) (uint16, error) {
+	p.sendMu.Lock()
+	defer p.sendMu.Unlock()
+
+	// Pace requests so a large sweep does not burst thousands of packets at once
+	if wait := time.Until(p.lastSend.Add(p.interval)); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+
+	p.mu.Lock()
+	p.seq++
+	seq := p.seq
+	p.mu.Unlock()
+
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Code: 0,
+		Body: &icmp.Echo{
+			ID:   p.id,
+			Seq:  int(seq),
+			Data: []byte("network-discovery"),
+		},
+	}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var addr net.Addr = &net.UDPAddr{IP: dst}
+	if p.privileged {
+		addr = &net.IPAddr{IP: dst}
+	}
+
+	// Register before writing so a fast reply always finds its request
+	req.sent = time.Now()
+	p.lastSend = req.sent
+	p.mu.Lock()
+	p.pending[seq] = req
+	p.mu.Unlock()
+
+	if _, err := p.conn.WriteTo(data, addr); err != nil {
+		p.mu.Lock()
+		delete(p.pending, seq)
+		p.mu.Unlock()
+		return 0, err
+	}
+	return seq, nil
+}
+
+// readLoop dispatches echo replies to the waiting Ping calls
+func (p *icmpPinger) readLoop() {
+	buf := make([]byte, 1500)
+	for {
+		var (
+			n    int
+			ttl  int
+			peer net.Addr
+			err  error
+		)
+		if p.ipConn != nil {
+			var cm *ipv4.ControlMessage
+			n, cm, peer, err = p.ipConn.ReadFrom(buf)
+			if cm != nil {
+				ttl = cm.TTL
+			}
+		} else {
+			n, peer, err = p.conn.ReadFrom(buf)
+		}
+		if err != nil {
+			select {
+			case <-p.done:
+				return
+			default:
+			}
+			p.logger.Debugf("ICMP read error: %v", err)
+			continue
+		}
+		received := time.Now()
+
+		msg, err := icmp.ParseMessage(protocolICMP, buf[:n])
+		if err != nil || msg.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := msg.Body.(*icmp.Echo)
+		if !ok {
+			continue
+		}
+		// Datagram sockets get a kernel-assigned ID, so only raw sockets can filter on it
+		if p.privileged && echo.ID != p.id {
+			continue
+		}
+
+		p.mu.Lock()
+		req, ok := p.pending[uint16(echo.Seq)]
+		if ok && peerIP(peer) == req.ip {
+			delete(p.pending, uint16(echo.Seq))
+		} else {
+			ok = false
+		}
+		p.mu.Unlock()
+
+		if ok {
+			req.reply <- echoReply{rtt: received.Sub(req.sent), ttl: ttl}
+		}
+	}
+}
+
+func peerIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.String()
+	case *net.IPAddr:
+		return a.IP.String()
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,630 @@
+package arp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"network-discovery/internal/events"
+	"network-discovery/internal/limits"
+	"network-discovery/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// DefaultNDPWait is how long neighbour discovery listens after each probe round
+	DefaultNDPWait = 2 * time.Second
+
+	protocolICMPv6 = 58
+
+	// Neighbor Discovery option types (RFC 4861)
+	ndpOptSourceLinkAddr = 1
+	ndpOptTargetLinkAddr = 2
+)
+
+var allNodesMulticast = net.ParseIP("ff02::1")
+
+// ScanIPv6 discovers IPv6 hosts on the local links attached to networkRange, which may
+// be an IPv6 prefix or an IPv4 subnet (its interfaces are then searched for IPv6
+// neighbours). Brute-forcing a /64 is impossible, so hosts are found by pinging the
+// all-nodes group ff02::1, listening to Neighbor Discovery traffic, soliciting
+// addresses that answered without a link-layer address and reading the kernel
+// neighbour table. Devices carry the host's MAC address, the same one its IPv4 address
+// resolves to.
+func (s *Scanner) ScanIPv6(ctx context.Context, networkRange string) ([]*models.Device, error) {
+	start := time.Now()
+
+	_, target, err := net.ParseCIDR(networkRange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse network range: %v", err)
+	}
+	links, err := ndpLinksFor(target)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Infof("Starting IPv6 neighbour discovery for %s on %s", networkRange, linkNames(links))
+
+	release, err := limits.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen, err := s.probeLinks(ctx, links)
+	if err != nil {
+		// Without a socket the ping command still fills the kernel table read below
+		s.logger.Infof("Native ICMPv6 unavailable, falling back to ping subprocess: %v", err)
+		s.pingAllNodes(ctx, links)
+		seen = make(map[string]*neighbor6)
+	}
+	release()
+
+	// Resolve MAC addresses with one table read, even for a cancelled scan's partial results.
+	// The table also lists neighbours that talked to us but kept quiet during the probe.
+	table, err := ReadNeighborTable6(context.WithoutCancel(ctx))
+	if err != nil {
+		s.logger.Warnf("%v", err)
+	}
+	for key := range table {
+		if _, ok := seen[key]; !ok && onLinks(links, key) {
+			seen[key] = &neighbor6{}
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	isIPv6 := target.IP.To4() == nil
+	var devices []*models.Device
+	for _, key := range keys {
+		addr, _, _ := strings.Cut(key, "%")
+		if isIPv6 && !target.Contains(net.ParseIP(addr)) {
+			continue
+		}
+		n := seen[key]
+		mac := n.mac
+		if mac == "" {
+			mac = table.Lookup(key)
+		}
+		if mac == "" {
+			s.logger.Debugf("No neighbour entry found for %s", key)
+			continue
+		}
+
+		device := s.newDevice(key, mac, echoReply{rtt: n.rtt})
+		device.ScanMethod = "NDP"
+		devices = append(devices, device)
+		events.DeviceFound(ctx, models.PhaseNDP, device)
+		s.logger.Infof("Found IPv6 device: %s (%s) - %s", device.IP, device.MACAddress, device.Vendor)
+	}
+	events.PhaseComplete(ctx, models.PhaseNDP, len(seen), len(devices))
+
+	scanDuration := time.Since(start)
+
+	if err := ctx.Err(); err != nil {
+		s.logger.Warnf("IPv6 neighbour discovery cancelled after %v: %v. Returning %d devices found so far", scanDuration, err, len(devices))
+		return devices, err
+	}
+
+	s.logger.Infof("IPv6 neighbour discovery completed in %v. Found %d devices (%d neighbours heard)",
+		scanDuration, len(devices), len(seen))
+
+	return devices, nil
+}
+
+// probeLinks runs the echo and solicitation rounds on every link and returns the
+// neighbours heard, keyed like the neighbour table
+func (s *Scanner) probeLinks(ctx context.Context, links []*ndpLink) (map[string]*neighbor6, error) {
+	p, err := newNDPProber(links, s.logger)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+
+	// Echo from the link-local address, then from every global address so hosts also
+	// answer from (and resolve us through) their global addresses
+	for _, link := range links {
+		p.sendEcho(link, nil)
+		for _, addr := range link.global {
+			p.sendEcho(link, addr.IP)
+		}
+	}
+	if !sleepCtx(ctx, s.ndpWait) {
+		return p.Seen(), nil
+	}
+
+	// Raw sockets can also solicit responders without a link-layer address and global
+	// addresses derived (EUI-64) from link-local responders
+	if p.privileged {
+		solicited := 0
+		for _, link := range links {
+			for _, target := range p.solicitTargets(link) {
+				p.sendSolicitation(link, target)
+				solicited++
+				if !sleepCtx(ctx, s.pingInterval) {
+					return p.Seen(), nil
+				}
+			}
+		}
+		if solicited > 0 {
+			s.logger.Debugf("Sent %d neighbour solicitations", solicited)
+			sleepCtx(ctx, s.ndpWait)
+		}
+	}
+	return p.Seen(), nil
+}
+
+// pingAllNodes pings ff02::1 on every link with the ping command so the kernel
+// neighbour table learns the hosts that answer
+func (s *Scanner) pingAllNodes(ctx context.Context, links []*ndpLink) {
+	for _, link := range links {
+		var cmd *exec.Cmd
+		switch runtime.GOOS {
+		case "windows":
+			cmd = exec.CommandContext(ctx, "ping", "-6", "-n", "2", fmt.Sprintf("ff02::1%%%d", link.iface.Index))
+		case "darwin", "freebsd", "netbsd", "openbsd":
+			cmd = exec.CommandContext(ctx, "ping6", "-c", "2", "-I", link.iface.Name, "ff02::1")
+		default:
+			cmd = exec.CommandContext(ctx, "ping", "-6", "-c", "2", "-I", link.iface.Name, "ff02::1")
+		}
+		if err := cmd.Run(); err != nil {
+			s.logger.Debugf("ping ff02::1 on %s failed: %v", link.iface.Name, err)
+		}
+	}
+}
+
+// ndpLink is a local interface neighbour discovery runs on
+type ndpLink struct {
+	iface  net.Interface
+	global []*net.IPNet // non-link-local IPv6 addresses of the interface
+}
+
+// ndpLinksFor finds the up, multicast-capable, non-loopback interfaces with IPv6
+// enabled that are attached to target: by an IPv6 address overlapping it, or for an
+// IPv4 target by an IPv4 address in that subnet
+func ndpLinksFor(target *net.IPNet) ([]*ndpLink, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interfaces: %v", err)
+	}
+
+	isIPv6 := target.IP.To4() == nil
+	var links []*ndpLink
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		link := &ndpLink{iface: iface}
+		hasIPv6, attached := false, false
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			v6 := ipNet.IP.To4() == nil
+			if v6 {
+				hasIPv6 = true
+				if !ipNet.IP.IsLinkLocalUnicast() {
+					link.global = append(link.global, ipNet)
+				}
+			}
+			if v6 == isIPv6 && (ipNet.Contains(target.IP) || target.Contains(ipNet.IP)) {
+				attached = true
+			}
+		}
+		if attached && hasIPv6 {
+			links = append(links, link)
+		}
+	}
+	if len(links) == 0 {
+		return nil, fmt.Errorf("no IPv6-enabled local interface is attached to %s", target)
+	}
+	return links, nil
+}
+
+func linkNames(links []*ndpLink) string {
+	names := make([]string, len(links))
+	for i, link := range links {
+		names[i] = link.iface.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// neighbor6 is an IPv6 neighbour heard during discovery
+type neighbor6 struct {
+	mac string        // link-layer address from an ND option, "" until known
+	rtt time.Duration // echo round-trip time, 0 when the host did not answer the ping
+}
+
+// ndpProber sends ICMPv6 echo requests to ff02::1 and Neighbor Solicitations over
+// one socket, and records every neighbour it hears from: echo replies, Neighbor
+// Solicitations and Advertisements, and Router Advertisements
+type ndpProber struct {
+	conn       *icmp.PacketConn
+	pc         *ipv6.PacketConn
+	privileged bool // raw socket (sees ND traffic, can solicit) vs. unprivileged ping socket
+	id         int
+	links      map[int]*ndpLink
+	local      map[string]bool // own addresses, never reported as neighbours
+	logger     *logrus.Logger
+
+	mu       sync.Mutex // guards echoSent and seen
+	echoSent map[int]time.Time
+	seen     map[string]*neighbor6
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// newNDPProber opens a raw ICMPv6 socket and falls back to an unprivileged ICMPv6
+// datagram socket, which can only ping
+func newNDPProber(links []*ndpLink, logger *logrus.Logger) (*ndpProber, error) {
+	p := &ndpProber{
+		id:       nextEchoID(),
+		links:    make(map[int]*ndpLink, len(links)),
+		local:    make(map[string]bool),
+		logger:   logger,
+		echoSent: make(map[int]time.Time),
+		seen:     make(map[string]*neighbor6),
+		done:     make(chan struct{}),
+	}
+	for _, link := range links {
+		p.links[link.iface.Index] = link
+		if addrs, err := link.iface.Addrs(); err == nil {
+			for _, addr := range addrs {
+				if ipNet, ok := addr.(*net.IPNet); ok {
+					p.local[ipNet.IP.String()] = true
+				}
+			}
+		}
+	}
+
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err == nil {
+		p.privileged = true
+	} else {
+		logger.Debugf("Raw ICMPv6 socket unavailable: %v; trying unprivileged socket", err)
+		conn, err = icmp.ListenPacket("udp6", "::")
+		if err != nil {
+			return nil, fmt.Errorf("failed to open ICMPv6 socket: %v", err)
+		}
+	}
+	p.conn = conn
+	p.pc = conn.IPv6PacketConn()
+
+	// Neighbor Discovery messages must be sent with hop limit 255 (RFC 4861)
+	if err := p.pc.SetMulticastHopLimit(255); err != nil {
+		logger.Debugf("Failed to set ICMPv6 hop limit: %v", err)
+	}
+	if err := p.pc.SetMulticastLoopback(false); err != nil {
+		logger.Debugf("Failed to disable ICMPv6 multicast loopback: %v", err)
+	}
+	if err := p.pc.SetControlMessage(ipv6.FlagInterface, true); err != nil {
+		logger.Debugf("ICMPv6 interface control messages unavailable: %v", err)
+	}
+	if p.privileged {
+		var filter ipv6.ICMPFilter
+		filter.SetAll(true)
+		for _, typ := range []ipv6.ICMPType{ipv6.ICMPTypeEchoReply, ipv6.ICMPTypeRouterAdvertisement,
+			ipv6.ICMPTypeNeighborSolicitation, ipv6.ICMPTypeNeighborAdvertisement} {
+			filter.Accept(typ)
+		}
+		if err := p.pc.SetICMPFilter(&filter); err != nil {
+			logger.Debugf("Failed to set ICMPv6 filter: %v", err)
+		}
+	}
+
+	go p.readLoop()
+	return p, nil
+}
+
+// Close stops the reader and releases the socket
+func (p *ndpProber) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.done)
+		err = p.conn.Close()
+	})
+	return err
+}
+
+// Seen returns a copy of the neighbours heard so far
+func (p *ndpProber) Seen() map[string]*neighbor6 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seen := make(map[string]*neighbor6, len(p.seen))
+	for key, n := range p.seen {
+		copied := *n
+		seen[key] = &copied
+	}
+	return seen
+}
+
+// sendEcho pings the all-nodes group on link from src, or from the address the
+// kernel picks (link-local) when src is nil
+func (p *ndpProber) sendEcho(link *ndpLink, src net.IP) {
+	msg := icmp.Message{
+		Type: ipv6.ICMPTypeEchoRequest,
+		Code: 0,
+		Body: &icmp.Echo{
+			ID:   p.id,
+			Seq:  1,
+			Data: []byte("network-discovery"),
+		},
+	}
+	// The kernel fills in the ICMPv6 checksum
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	if _, ok := p.echoSent[link.iface.Index]; !ok {
+		p.echoSent[link.iface.Index] = time.Now()
+	}
+	p.mu.Unlock()
+
+	if err := p.write(link, data, allNodesMulticast, src); err != nil {
+		p.logger.Debugf("ICMPv6 echo to ff02::1 on %s from %v failed: %v", link.iface.Name, src, err)
+	}
+}
+
+// sendSolicitation sends a Neighbor Solicitation for target to its solicited-node
+// multicast group on link
+func (p *ndpProber) sendSolicitation(link *ndpLink, target net.IP) {
+	body := make([]byte, 20, 28)
+	copy(body[4:20], target.To16())
+	if len(link.iface.HardwareAddr) == 6 {
+		body = append(body, ndpOptSourceLinkAddr, 1)
+		body = append(body, link.iface.HardwareAddr...)
+	}
+	msg := icmp.Message{
+		Type: ipv6.ICMPTypeNeighborSolicitation,
+		Code: 0,
+		Body: &icmp.RawBody{Data: body},
+	}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return
+	}
+
+	if err := p.write(link, data, solicitedNodeMulticast(target), nil); err != nil {
+		p.logger.Debugf("Neighbor solicitation for %s on %s failed: %v", target, link.iface.Name, err)
+	}
+}
+
+func (p *ndpProber) write(link *ndpLink, data []byte, dst, src net.IP) error {
+	var addr net.Addr = &net.UDPAddr{IP: dst, Zone: link.iface.Name}
+	if p.privileged {
+		addr = &net.IPAddr{IP: dst, Zone: link.iface.Name}
+	}
+	cm := &ipv6.ControlMessage{IfIndex: link.iface.Index, Src: src}
+	_, err := p.pc.WriteTo(data, cm, addr)
+	return err
+}
+
+// solicitTargets returns the addresses worth soliciting on link: responders whose
+// link-layer address is still unknown, and global addresses formed from the EUI-64
+// interface identifier of link-local responders
+func (p *ndpProber) solicitTargets(link *ndpLink) []net.IP {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	suffix := "%" + link.iface.Name
+	var targets []net.IP
+	for key, n := range p.seen {
+		addr, zone, zoned := strings.Cut(key, "%")
+		ip := net.ParseIP(addr)
+		if zoned && "%"+zone != suffix {
+			continue
+		}
+		if !zoned && !linkHasPrefix(link, ip) {
+			continue
+		}
+		if n.mac == "" {
+			targets = append(targets, ip)
+		}
+		if !ip.IsLinkLocalUnicast() || ip[11] != 0xff || ip[12] != 0xfe {
+			continue
+		}
+		for _, prefix := range link.global {
+			candidate := make(net.IP, net.IPv6len)
+			copy(candidate, prefix.IP.Mask(prefix.Mask))
+			copy(candidate[8:], ip[8:])
+			if ones, _ := prefix.Mask.Size(); ones != 64 || p.local[candidate.String()] {
+				continue
+			}
+			if _, ok := p.seen[candidate.String()]; !ok {
+				targets = append(targets, candidate)
+			}
+		}
+	}
+	return targets
+}
+
+// readLoop records the neighbours heard on the scanned links
+func (p *ndpProber) readLoop() {
+	buf := make([]byte, 1500)
+	for {
+		n, cm, peer, err := p.pc.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-p.done:
+				return
+			default:
+			}
+			p.logger.Debugf("ICMPv6 read error: %v", err)
+			continue
+		}
+		received := time.Now()
+
+		src, zone := peerAddr(peer)
+		if src == nil || p.local[src.String()] {
+			continue
+		}
+		link := p.linkFor(cm, zone)
+		if link == nil {
+			continue
+		}
+
+		msg, err := icmp.ParseMessage(protocolICMPv6, buf[:n])
+		if err != nil {
+			continue
+		}
+		switch msg.Type {
+		case ipv6.ICMPTypeEchoReply:
+			echo, ok := msg.Body.(*icmp.Echo)
+			// Datagram sockets get a kernel-assigned ID, so only raw sockets can filter on it
+			if !ok || (p.privileged && echo.ID != p.id) {
+				continue
+			}
+			p.record(link, src, "", received)
+		case ipv6.ICMPTypeNeighborSolicitation:
+			body, ok := msg.Body.(*icmp.RawBody)
+			// Duplicate address detection solicits from the unspecified address
+			if !ok || len(body.Data) < 20 || src.IsUnspecified() {
+				continue
+			}
+			p.record(link, src, ndpLinkAddr(body.Data[20:], ndpOptSourceLinkAddr), time.Time{})
+		case ipv6.ICMPTypeNeighborAdvertisement:
+			body, ok := msg.Body.(*icmp.RawBody)
+			if !ok || len(body.Data) < 20 {
+				continue
+			}
+			target := net.IP(append([]byte(nil), body.Data[4:20]...))
+			if p.local[target.String()] {
+				continue
+			}
+			p.record(link, target, ndpLinkAddr(body.Data[20:], ndpOptTargetLinkAddr), time.Time{})
+		case ipv6.ICMPTypeRouterAdvertisement:
+			body, ok := msg.Body.(*icmp.RawBody)
+			if !ok || len(body.Data) < 12 {
+				continue
+			}
+			p.record(link, src, ndpLinkAddr(body.Data[12:], ndpOptSourceLinkAddr), time.Time{})
+		}
+	}
+}
+
+// record notes a neighbour on link. A non-zero replied time marks an echo reply.
+func (p *ndpProber) record(link *ndpLink, ip net.IP, mac string, replied time.Time) {
+	key := ip.String()
+	if ip.IsLinkLocalUnicast() {
+		key += "%" + link.iface.Name
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n, ok := p.seen[key]
+	if !ok {
+		n = &neighbor6{}
+		p.seen[key] = n
+	}
+	if mac != "" {
+		n.mac = mac
+	}
+	if sent, ok := p.echoSent[link.iface.Index]; ok && !replied.IsZero() && n.rtt == 0 {
+		n.rtt = replied.Sub(sent)
+	}
+}
+
+// linkFor returns the scanned link a packet arrived on, from its control message or
+// the source zone; with a single link every packet belongs to it
+func (p *ndpProber) linkFor(cm *ipv6.ControlMessage, zone string) *ndpLink {
+	if cm != nil && cm.IfIndex != 0 {
+		return p.links[cm.IfIndex]
+	}
+	for _, link := range p.links {
+		if zone == link.iface.Name || len(p.links) == 1 {
+			return link
+		}
+	}
+	return nil
+}
+
+// onLinks reports whether the neighbour table key belongs to one of links: by the zone
+// of a link-local address or the prefix of a global one
+func onLinks(links []*ndpLink, key string) bool {
+	addr, zone, zoned := strings.Cut(key, "%")
+	ip := net.ParseIP(addr)
+	for _, link := range links {
+		if (zoned && zone == link.iface.Name) || (!zoned && linkHasPrefix(link, ip)) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkHasPrefix reports whether ip is inside one of the link's global prefixes
+func linkHasPrefix(link *ndpLink, ip net.IP) bool {
+	for _, prefix := range link.global {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ndpLinkAddr returns the link-layer address carried in the ND option of the given
+// type, or ""
+func ndpLinkAddr(options []byte, optType byte) string {
+	for len(options) >= 2 {
+		length := int(options[1]) * 8
+		if length == 0 || length > len(options) {
+			return ""
+		}
+		if options[0] == optType && length >= 8 {
+			return normalizeMAC(net.HardwareAddr(options[2:8]).String())
+		}
+		options = options[length:]
+	}
+	return ""
+}
+
+// solicitedNodeMulticast returns the ff02::1:ffXX:XXXX group of ip
+func solicitedNodeMulticast(ip net.IP) net.IP {
+	group := net.ParseIP("ff02::1:ff00:0")
+	copy(group[13:], ip.To16()[13:])
+	return group
+}
+
+func peerAddr(addr net.Addr) (net.IP, string) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP, a.Zone
+	case *net.IPAddr:
+		return a.IP, a.Zone
+	default:
+		return nil, ""
+	}
+}
+
+// sleepCtx waits for d and reports false when ctx is cancelled first
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,133 @@
+package arp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// neighborTableTimeout bounds a single read of the OS neighbour (ARP) table
+const neighborTableTimeout = 5 * time.Second
+
+var (
+	ipv4Regex = regexp.MustCompile(`\b(\d{1,3}\.){3}\d{1,3}\b`)
+	// BSD/macOS print octets without leading zeros (e.g. 0:1a:2b:3c:4d:5e)
+	macRegex = regexp.MustCompile(`\b([0-9a-fA-F]{1,2}[-:]){5}[0-9a-fA-F]{1,2}\b`)
+)
+
+// NeighborTable maps IP addresses to MAC addresses as known by the operating system.
+// IPv6 link-local keys carry their zone, e.g. fe80::1%eth0.
+type NeighborTable map[string]string
+
+// Lookup returns the MAC address recorded for ip, or "" when it is unknown
+func (t NeighborTable) Lookup(ip string) string {
+	return t[ip]
+}
+
+// ReadNeighborTable reads the complete OS neighbour table once. On Linux this is
+// /proc/net/arp; other platforms parse the output of `arp -a`.
+func ReadNeighborTable(ctx context.Context) (NeighborTable, error) {
+	ctx, cancel := context.WithTimeout(ctx, neighborTableTimeout)
+	defer cancel()
+
+	table, err := readNeighborTable(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ARP table: %v", err)
+	}
+	return table, nil
+}
+
+// ReadNeighborTable6 reads the complete OS IPv6 neighbour (NDP) cache once. On Linux
+// this is a netlink dump; other platforms parse `ndp -an` or `netsh`.
+func ReadNeighborTable6(ctx context.Context) (NeighborTable, error) {
+	ctx, cancel := context.WithTimeout(ctx, neighborTableTimeout)
+	defer cancel()
+
+	table, err := readNeighborTable6(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read IPv6 neighbour table: %v", err)
+	}
+	return table, nil
+}
+
+// parseARPOutput extracts every IP/MAC pair from `arp -a` style output. It handles
+// Windows ("192.168.1.1   aa-bb-cc-dd-ee-ff   dynamic") and BSD/macOS
+// ("? (192.168.1.1) at aa:bb:cc:dd:ee:ff on en0") formats.
+func parseARPOutput(output string) NeighborTable {
+	table := make(NeighborTable)
+	for _, line := range strings.Split(output, "\n") {
+		ip := ipv4Regex.FindString(line)
+		mac := macRegex.FindString(line)
+		if ip == "" || mac == "" {
+			continue
+		}
+		if mac = normalizeMAC(mac); mac != "" {
+			table[ip] = mac
+		}
+	}
+	return table
+}
+
+// normalizeMAC converts a MAC to upper-case, zero-padded XX:XX:XX:XX:XX:XX form.
+// Incomplete (all-zero) entries are returned as "".
+func normalizeMAC(mac string) string {
+	parts := strings.FieldsFunc(mac, func(r rune) bool { return r == ':' || r == '-' })
+	if len(parts) != 6 {
+		return ""
+	}
+
+	zero := true
+	for i, part := range parts {
+		if len(part) == 1 {
+			part = "0" + part
+		}
+		parts[i] = strings.ToUpper(part)
+		if parts[i] != "00" {
+			zero = false
+		}
+	}
+	if zero {
+		return ""
+	}
+	return strings.Join(parts, ":")
+}
+
+// parseNDPOutput extracts every IPv6/MAC pair from `ndp -an` (BSD/macOS,
+// "fe80::1%en0   aa:bb:cc:dd:ee:ff   en0 23h59m58s S R") or
+// `netsh interface ipv6 show neighbors` (Windows, "fd00::1   aa-bb-cc-dd-ee-ff   Reachable") output
+func parseNDPOutput(output string) NeighborTable {
+	table := make(NeighborTable)
+	for _, line := range strings.Split(output, "\n") {
+		mac := macRegex.FindString(line)
+		if mac == "" {
+			continue
+		}
+		if mac = normalizeMAC(mac); mac == "" {
+			continue
+		}
+		for _, field := range strings.Fields(line) {
+			if key := ipv6Key(field); key != "" {
+				table[key] = mac
+				break
+			}
+		}
+	}
+	return table
+}
+
+// ipv6Key returns the canonical table key for an IPv6 address with an optional zone,
+// keeping the zone only for link-local addresses. It returns "" for anything else.
+func ipv6Key(s string) string {
+	addr, zone, _ := strings.Cut(s, "%")
+	ip := net.ParseIP(addr)
+	if ip == nil || ip.To4() != nil {
+		return ""
+	}
+	if ip.IsLinkLocalUnicast() && zone != "" {
+		return ip.String() + "%" + zone
+	}
+	return ip.String()
+}
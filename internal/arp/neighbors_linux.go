@@ -0,0 +1,123 @@
+package arp
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// atfComplete is the ATF_COM flag marking a resolved entry in /proc/net/arp
+const atfComplete = 0x2
+
+// Netlink neighbour message layout (linux/neighbour.h)
+const (
+	ndmsgLen   = 12 // family, pad, pad, ifindex, state, flags, type
+	ndaDst     = 1
+	ndaLLAddr  = 2
+	nudValid   = 0x02 | 0x04 | 0x08 | 0x10 | 0x80 // REACHABLE, STALE, DELAY, PROBE, PERMANENT
+	rtaHdrLen  = 4
+	rtaAlignTo = 4
+)
+
+// readNeighborTable parses /proc/net/arp:
+//
+//	IP address       HW type     Flags       HW address            Mask     Device
+//	192.168.1.1      0x1         0x2         aa:bb:cc:dd:ee:ff     *        eth0
+func readNeighborTable(ctx context.Context) (NeighborTable, error) {
+	data, err := os.ReadFile("/proc/net/arp")
+	if err != nil {
+		return nil, err
+	}
+
+	table := make(NeighborTable)
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		if err != nil || flags&atfComplete == 0 {
+			continue
+		}
+		if mac := normalizeMAC(fields[3]); mac != "" {
+			table[fields[0]] = mac
+		}
+	}
+	return table, nil
+}
+
+// readNeighborTable6 dumps the kernel IPv6 neighbour cache over netlink (RTM_GETNEIGH),
+// the equivalent of `ip -6 neigh show`; there is no /proc file for it
+func readNeighborTable6(ctx context.Context) (NeighborTable, error) {
+	data, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, syscall.AF_INET6)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	table := make(NeighborTable)
+	zones := make(map[int]string)
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWNEIGH || len(m.Data) < ndmsgLen {
+			continue
+		}
+		ifIndex := int(int32(binary.NativeEndian.Uint32(m.Data[4:8])))
+		state := binary.NativeEndian.Uint16(m.Data[8:10])
+		if state&nudValid == 0 {
+			continue
+		}
+
+		var ip net.IP
+		var mac string
+		for attrs := m.Data[ndmsgLen:]; len(attrs) >= rtaHdrLen; {
+			attrLen := int(binary.NativeEndian.Uint16(attrs[0:2]))
+			if attrLen < rtaHdrLen || attrLen > len(attrs) {
+				break
+			}
+			value := attrs[rtaHdrLen:attrLen]
+			switch binary.NativeEndian.Uint16(attrs[2:4]) {
+			case ndaDst:
+				if len(value) == net.IPv6len {
+					ip = net.IP(append([]byte(nil), value...))
+				}
+			case ndaLLAddr:
+				if len(value) == 6 {
+					mac = normalizeMAC(net.HardwareAddr(value).String())
+				}
+			}
+			next := (attrLen + rtaAlignTo - 1) &^ (rtaAlignTo - 1)
+			if next > len(attrs) {
+				break
+			}
+			attrs = attrs[next:]
+		}
+		if ip == nil || mac == "" {
+			continue
+		}
+
+		key := ip.String()
+		if ip.IsLinkLocalUnicast() {
+			zone, ok := zones[ifIndex]
+			if !ok {
+				if ifi, err := net.InterfaceByIndex(ifIndex); err == nil {
+					zone = ifi.Name
+				}
+				zones[ifIndex] = zone
+			}
+			if zone != "" {
+				key += "%" + zone
+			}
+		}
+		table[key] = mac
+	}
+	return table, nil
+}
@@ -0,0 +1,36 @@
+//go:build !linux
+
+package arp
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+)
+
+// readNeighborTable runs `arp -a` once and parses every entry
+func readNeighborTable(ctx context.Context) (NeighborTable, error) {
+	output, err := exec.CommandContext(ctx, "arp", "-a").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseARPOutput(string(output)), nil
+}
+
+// readNeighborTable6 runs `ndp -an` (BSD/macOS) or `netsh interface ipv6 show neighbors`
+// (Windows) once and parses every entry
+func readNeighborTable6(ctx context.Context) (NeighborTable, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.CommandContext(ctx, "netsh", "interface", "ipv6", "show", "neighbors")
+	default:
+		cmd = exec.CommandContext(ctx, "ndp", "-an")
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseNDPOutput(string(output)), nil
+}
@@ -0,0 +1,231 @@
+package arp
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ethPArp        = 0x0806
+	ethPIPv4       = 0x0800
+	arpOpRequest   = 1
+	arpOpReply     = 2
+	arpFrameLen    = 14 + 28
+	packetOutgoing = 4 // PACKET_OUTGOING: frames sent by this host
+)
+
+// arpRequester broadcasts ARP who-has requests on the interface attached to the
+// scanned network and records every reply, so hosts that drop ICMP are still found
+type arpRequester struct {
+	fd     int
+	iface  *net.Interface
+	srcIP  net.IP
+	subnet *net.IPNet
+	logger *logrus.Logger
+
+	mu      sync.Mutex
+	replies NeighborTable
+
+	done     chan struct{}
+	readDone chan struct{}
+}
+
+// newARPRequester opens an AF_PACKET socket on the local interface whose subnet
+// contains target. It requires root or CAP_NET_RAW.
+func newARPRequester(target *net.IPNet, logger *logrus.Logger) (*arpRequester, error) {
+	iface, srcIP, err := interfaceFor(target)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(htons(ethPArp)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open packet socket: %v", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: htons(ethPArp), Ifindex: iface.Index}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind packet socket to %s: %v", iface.Name, err)
+	}
+	// A short receive timeout lets the reader notice Close without closing the fd under it
+	tv := syscall.NsecToTimeval((100 * time.Millisecond).Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to set packet socket timeout: %v", err)
+	}
+
+	r := &arpRequester{
+		fd:       fd,
+		iface:    iface,
+		srcIP:    srcIP,
+		subnet:   target,
+		logger:   logger,
+		replies:  make(NeighborTable),
+		done:     make(chan struct{}),
+		readDone: make(chan struct{}),
+	}
+	go r.readLoop()
+
+	logger.Debugf("Sending ARP requests on %s from %s", iface.Name, srcIP)
+	return r, nil
+}
+
+// Sweep sends one who-has request per IP, pausing interval between requests
+func (r *arpRequester) Sweep(ctx context.Context, ips []string, interval time.Duration) {
+	dst := syscall.SockaddrLinklayer{
+		Protocol: htons(ethPArp),
+		Ifindex:  r.iface.Index,
+		Halen:    6,
+		Addr:     [8]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+
+	for _, ip := range ips {
+		target := net.ParseIP(ip).To4()
+		if target == nil {
+			continue
+		}
+		if err := syscall.Sendto(r.fd, r.requestFrame(target), 0, &dst); err != nil {
+			r.logger.Debugf("ARP request to %s failed: %v", ip, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.done:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Replies returns a copy of the IP -> MAC pairs answered so far
+func (r *arpRequester) Replies() NeighborTable {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	replies := make(NeighborTable, len(r.replies))
+	for ip, mac := range r.replies {
+		replies[ip] = mac
+	}
+	return replies
+}
+
+// Close stops the reader and releases the socket
+func (r *arpRequester) Close() error {
+	select {
+	case <-r.done:
+		return nil
+	default:
+	}
+	close(r.done)
+	<-r.readDone
+	return syscall.Close(r.fd)
+}
+
+// requestFrame builds a broadcast Ethernet frame carrying an ARP who-has for target
+func (r *arpRequester) requestFrame(target net.IP) []byte {
+	frame := make([]byte, arpFrameLen)
+
+	// Ethernet header
+	copy(frame[0:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	copy(frame[6:12], r.iface.HardwareAddr)
+	binary.BigEndian.PutUint16(frame[12:14], ethPArp)
+
+	// ARP payload
+	arp := frame[14:]
+	binary.BigEndian.PutUint16(arp[0:2], 1) // Ethernet
+	binary.BigEndian.PutUint16(arp[2:4], ethPIPv4)
+	arp[4] = 6
+	arp[5] = 4
+	binary.BigEndian.PutUint16(arp[6:8], arpOpRequest)
+	copy(arp[8:14], r.iface.HardwareAddr)
+	copy(arp[14:18], r.srcIP)
+	// Target hardware address stays zero
+	copy(arp[24:28], target)
+
+	return frame
+}
+
+// readLoop records ARP replies from hosts inside the scanned subnet
+func (r *arpRequester) readLoop() {
+	defer close(r.readDone)
+
+	buf := make([]byte, 1500)
+	for {
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+
+		n, from, err := syscall.Recvfrom(r.fd, buf, 0)
+		if err != nil {
+			if err != syscall.EAGAIN && err != syscall.EINTR {
+				r.logger.Debugf("ARP read error: %v", err)
+			}
+			continue
+		}
+		if ll, ok := from.(*syscall.SockaddrLinklayer); ok && ll.Pkttype == packetOutgoing {
+			continue
+		}
+		if n < arpFrameLen || binary.BigEndian.Uint16(buf[12:14]) != ethPArp {
+			continue
+		}
+
+		arp := buf[14:arpFrameLen]
+		if binary.BigEndian.Uint16(arp[6:8]) != arpOpReply {
+			continue
+		}
+		senderIP := net.IP(arp[14:18])
+		if !r.subnet.Contains(senderIP) {
+			continue
+		}
+		mac := normalizeMAC(net.HardwareAddr(arp[8:14]).String())
+		if mac == "" {
+			continue
+		}
+
+		r.mu.Lock()
+		r.replies[senderIP.String()] = mac
+		r.mu.Unlock()
+	}
+}
+
+// interfaceFor finds the up, non-loopback Ethernet interface with an IPv4 address in target
+func interfaceFor(target *net.IPNet) (*net.Interface, net.IP, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list interfaces: %v", err)
+	}
+
+	for i := range ifaces {
+		iface := &ifaces[i]
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) != 6 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+			if ipNet.Contains(target.IP) || target.Contains(ipNet.IP) {
+				return iface, ipNet.IP.To4(), nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("no local interface is attached to %s", target)
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+
+package arp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// arpRequester is only implemented on Linux (AF_PACKET); elsewhere the scan relies
+// on ICMP and the OS neighbour table
+type arpRequester struct{}
+
+func newARPRequester(target *net.IPNet, logger *logrus.Logger) (*arpRequester, error) {
+	return nil, fmt.Errorf("active ARP requests are not supported on %s", runtime.GOOS)
+}
+
+func (r *arpRequester) Sweep(ctx context.Context, ips []string, interval time.Duration) {}
+
+func (r *arpRequester) Replies() NeighborTable { return nil }
+
+func (r *arpRequester) Close() error { return nil }
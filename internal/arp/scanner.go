@@ -1,16 +1,20 @@
 package arp
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os/exec"
 	"regexp"
 	"runtime"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
+	"network-discovery/internal/events"
+	"network-discovery/internal/limits"
 	"network-discovery/internal/models"
+	"network-discovery/internal/pkg/utils"
 
 	"github.com/sirupsen/logrus"
 )
@@ -19,6 +23,15 @@ type Scanner struct {
 	logger        *logrus.Logger
 	maxWorkers    int
 	vendorManager *VendorManager
+
+	// ICMP sweep settings for the in-process pinger
+	pingTimeout  time.Duration
+	pingInterval time.Duration
+	// Listen time after each IPv6 neighbour discovery round
+	ndpWait time.Duration
+
+	// Broadcast raw ARP who-has requests during the sweep (Linux, needs CAP_NET_RAW)
+	activeARP bool
 }
 
 func NewScanner(maxWorkers int) *Scanner {
@@ -29,6 +42,9 @@ func NewScanner(maxWorkers int) *Scanner {
 		logger:        logger,
 		maxWorkers:    maxWorkers,
 		vendorManager: NewVendorManager("", logger), // Default config path
+		pingTimeout:   DefaultPingTimeout,
+		pingInterval:  DefaultPingInterval,
+		ndpWait:       DefaultNDPWait,
 	}
 }
 
@@ -37,6 +53,9 @@ func NewScannerWithLogger(maxWorkers int, logger *logrus.Logger) *Scanner {
 		logger:        logger,
 		maxWorkers:    maxWorkers,
 		vendorManager: NewVendorManager("", logger), // Default config path
+		pingTimeout:   DefaultPingTimeout,
+		pingInterval:  DefaultPingInterval,
+		ndpWait:       DefaultNDPWait,
 	}
 }
 
@@ -45,11 +64,22 @@ func NewScannerWithConfig(maxWorkers int, logger *logrus.Logger, configPath stri
 		logger:        logger,
 		maxWorkers:    maxWorkers,
 		vendorManager: NewVendorManager(configPath, logger),
+		pingTimeout:   DefaultPingTimeout,
+		pingInterval:  DefaultPingInterval,
+		ndpWait:       DefaultNDPWait,
 	}
 }
 
-// ScanNetwork performs ARP scan on the given network range
-func (s *Scanner) ScanNetwork(networkRange string) ([]*models.Device, error) {
+// SetActiveARP enables sending raw ARP requests in addition to the ICMP sweep
+func (s *Scanner) SetActiveARP(enabled bool) {
+	s.activeARP = enabled
+}
+
+// ScanNetwork performs ARP scan on the given network range. Hosts are swept with ICMP
+// (and, when enabled, raw ARP requests) and their MAC addresses are resolved from a
+// single read of the OS neighbour table. When ctx is cancelled no further IPs are
+// probed and the devices found so far are returned with ctx.Err().
+func (s *Scanner) ScanNetwork(ctx context.Context, networkRange string) ([]*models.Device, error) {
 	start := time.Now()
 	s.logger.Infof("Starting ARP scan for range: %s", networkRange)
 
@@ -58,12 +88,13 @@ func (s *Scanner) ScanNetwork(networkRange string) ([]*models.Device, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse network range: %v", err)
 	}
+	_, ipNet, _ := net.ParseCIDR(networkRange)
 
 	s.logger.Infof("ARP scanning %d IP addresses", len(ips))
 
 	// Create channels for work distribution
 	ipChan := make(chan string, len(ips))
-	resultChan := make(chan *models.Device, len(ips))
+	resultChan := make(chan hostReply, len(ips))
 
 	// Add IPs to channel
 	for _, ip := range ips {
@@ -71,6 +102,19 @@ func (s *Scanner) ScanNetwork(networkRange string) ([]*models.Device, error) {
 	}
 	close(ipChan)
 
+	// Broadcast ARP who-has requests alongside the ICMP sweep so hosts that drop ICMP are found too
+	var requester *arpRequester
+	if s.activeARP {
+		requester, err = newARPRequester(ipNet, s.logger)
+		if err != nil {
+			s.logger.Infof("Active ARP unavailable, relying on ICMP sweep: %v", err)
+			requester = nil
+		} else {
+			defer requester.Close()
+			go requester.Sweep(ctx, ips, s.pingInterval)
+		}
+	}
+
 	// Start workers
 	var wg sync.WaitGroup
 	workers := s.maxWorkers
@@ -80,9 +124,18 @@ func (s *Scanner) ScanNetwork(networkRange string) ([]*models.Device, error) {
 
 	s.logger.Infof("Starting %d workers for ARP scanning", workers)
 
+	// Sweep over one shared ICMP socket; fall back to the ping command when it cannot be opened
+	pinger, err := newICMPPinger(s.pingInterval, s.logger)
+	if err != nil {
+		s.logger.Infof("Native ICMP unavailable, falling back to ping subprocess: %v", err)
+	} else {
+		defer pinger.Close()
+	}
+
+	tracker := events.NewTracker(ctx, models.PhaseARP, len(ips))
 	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go s.worker(ipChan, resultChan, &wg)
+		go s.worker(ctx, pinger, ipChan, resultChan, tracker, &wg)
 	}
 
 	// Wait for all workers to complete
@@ -91,151 +144,144 @@ func (s *Scanner) ScanNetwork(networkRange string) ([]*models.Device, error) {
 		close(resultChan)
 	}()
 
-	// Collect results
+	// Collect reachable hosts
+	reachable := make(map[string]echoReply)
+	for reply := range resultChan {
+		reachable[reply.ip] = reply.echoReply
+	}
+
+	// Resolve MAC addresses with one table read, even for a cancelled scan's partial results
+	table, err := ReadNeighborTable(context.WithoutCancel(ctx))
+	if err != nil {
+		s.logger.Warnf("%v", err)
+	}
+	var replies NeighborTable
+	if requester != nil {
+		replies = requester.Replies()
+	}
+
 	var devices []*models.Device
-	for device := range resultChan {
-		if device != nil {
-			devices = append(devices, device)
-			s.logger.Infof("Found ARP device: %s (%s) - %s", device.IP, device.MACAddress, device.Vendor)
+	for _, ip := range ips {
+		reply, pinged := reachable[ip]
+		mac := replies.Lookup(ip)
+		if mac == "" {
+			if !pinged {
+				continue
+			}
+			mac = table.Lookup(ip)
+		}
+		if mac == "" {
+			s.logger.Debugf("No ARP entry found for %s", ip)
+			continue
 		}
+
+		device := s.newDevice(ip, mac, reply)
+		devices = append(devices, device)
+		events.DeviceFound(ctx, models.PhaseARP, device)
+		s.logger.Infof("Found ARP device: %s (%s) - %s", device.IP, device.MACAddress, device.Vendor)
 	}
 
 	scanDuration := time.Since(start)
-	s.logger.Infof("ARP scan completed in %v. Found %d devices", scanDuration, len(devices))
+
+	if err := ctx.Err(); err != nil {
+		s.logger.Warnf("ARP scan cancelled after %v: %v. Returning %d devices found so far", scanDuration, err, len(devices))
+		return devices, err
+	}
+
+	s.logger.Infof("ARP scan completed in %v. Found %d devices (%d answered ICMP, %d answered ARP requests)",
+		scanDuration, len(devices), len(reachable), len(replies))
 
 	return devices, nil
 }
 
-func (s *Scanner) worker(ipChan <-chan string, resultChan chan<- *models.Device, wg *sync.WaitGroup) {
+// hostReply is a host that answered the ICMP sweep
+type hostReply struct {
+	ip string
+	echoReply
+}
+
+func (s *Scanner) worker(ctx context.Context, pinger *icmpPinger, ipChan <-chan string, resultChan chan<- hostReply, tracker *events.Tracker, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	for ip := range ipChan {
+		if ctx.Err() != nil {
+			return
+		}
+
 		s.logger.Debugf("Scanning IP: %s", ip)
 
-		device := s.scanSingleIP(ip)
-		if device != nil {
-			resultChan <- device
+		release, err := limits.Acquire(ctx)
+		if err != nil {
+			return
 		}
+		reply, ok := s.ping(ctx, pinger, ip)
+		release()
+		tracker.Step(ok)
+		if !ok {
+			s.logger.Debugf("IP %s is not reachable via ping", ip)
+			continue
+		}
+		s.logger.Debugf("IP %s responded to ping in %v (TTL %d)", ip, reply.rtt, reply.ttl)
+		resultChan <- hostReply{ip: ip, echoReply: reply}
 	}
 }
 
-// scanSingleIP performs ARP scan for a single IP
-func (s *Scanner) scanSingleIP(ip string) *models.Device {
-	start := time.Now()
-	s.logger.Debugf("Starting ARP scan for IP: %s", ip)
-
-	// First try to ping the IP to see if it's reachable
-	if !s.pingIP(ip) {
-		s.logger.Debugf("IP %s is not reachable via ping", ip)
-		return nil
-	}
-	s.logger.Debugf("IP %s responded to ping", ip)
-
-	// Get MAC address using ARP
-	macAddress, err := s.getARPEntry(ip)
-	if err != nil {
-		s.logger.Debugf("Failed to get ARP entry for %s: %v", ip, err)
-		return nil
-	}
-
-	if macAddress == "" {
-		s.logger.Debugf("No ARP entry found for %s", ip)
-		return nil
-	}
-
-	s.logger.Debugf("Found MAC address for %s: %s", ip, macAddress)
-
+// newDevice builds the ARP scan result for a host with a resolved MAC address
+func (s *Scanner) newDevice(ip, macAddress string, reply echoReply) *models.Device {
 	// Get vendor information
 	vendor := s.getVendorFromMAC(macAddress)
 	s.logger.Debugf("Vendor detection for %s (MAC: %s): %s", ip, macAddress, vendor)
 
-	device := &models.Device{
+	return &models.Device{
 		IP:           ip,
 		MACAddress:   macAddress,
 		LastSeen:     time.Now(),
 		IsReachable:  true,
-		ResponseTime: time.Since(start).Milliseconds(),
+		ResponseTime: reply.rtt.Milliseconds(),
+		TTL:          reply.ttl,
 		Vendor:       vendor,
 		ScanMethod:   "ARP",
 	}
-
-	s.logger.Debugf("ARP scan successful for %s: MAC=%s, Vendor=%s", ip, macAddress, vendor)
-	return device
 }
 
-// pingIP checks if an IP is reachable via ping
-func (s *Scanner) pingIP(ip string) bool {
-	var cmd *exec.Cmd
-
-	switch runtime.GOOS {
-	case "windows":
-		cmd = exec.Command("ping", "-n", "1", "-w", "500", ip)
-	default: // Linux, macOS
-		cmd = exec.Command("ping", "-c", "1", "-W", "1", ip)
+// ping checks reachability with the native ICMP pinger when available and the ping
+// command otherwise, returning the round-trip time and TTL
+func (s *Scanner) ping(ctx context.Context, pinger *icmpPinger, ip string) (echoReply, bool) {
+	if pinger != nil {
+		return pinger.Ping(ctx, ip, s.pingTimeout)
 	}
 
-	err := cmd.Run()
-	return err == nil
+	start := time.Now()
+	ttl, ok := s.pingIP(ctx, ip)
+	if !ok {
+		return echoReply{}, false
+	}
+	return echoReply{rtt: time.Since(start), ttl: ttl}, true
 }
 
-// getARPEntry retrieves MAC address from ARP table
-func (s *Scanner) getARPEntry(ip string) (string, error) {
+// pingTTL finds the TTL in the output of the ping command ("ttl=64", "TTL=128")
+var pingTTL = regexp.MustCompile(`(?i)\bttl=(\d+)`)
+
+// pingIP checks if an IP is reachable via ping, returning the TTL of the reply
+func (s *Scanner) pingIP(ctx context.Context, ip string) (int, bool) {
 	var cmd *exec.Cmd
 
 	switch runtime.GOOS {
 	case "windows":
-		cmd = exec.Command("arp", "-a", ip)
+		cmd = exec.CommandContext(ctx, "ping", "-n", "1", "-w", "500", ip)
 	default: // Linux, macOS
-		cmd = exec.Command("arp", "-n", ip)
+		cmd = exec.CommandContext(ctx, "ping", "-c", "1", "-W", "1", ip)
 	}
 
 	output, err := cmd.Output()
 	if err != nil {
-		return "", err
+		return 0, false
 	}
-
-	return s.parseARPOutput(string(output), ip)
-}
-
-// parseARPOutput parses ARP command output to extract MAC address
-func (s *Scanner) parseARPOutput(output, targetIP string) (string, error) {
-	lines := strings.Split(output, "\n")
-	s.logger.Debugf("Parsing ARP output for %s:\n%s", targetIP, output)
-
-	switch runtime.GOOS {
-	case "windows":
-		// Windows format: "  192.168.1.1           aa-bb-cc-dd-ee-ff     dynamic"
-		macRegex := regexp.MustCompile(`([0-9a-fA-F]{2}[-:]){5}[0-9a-fA-F]{2}`)
-		for _, line := range lines {
-			if strings.Contains(line, targetIP) {
-				s.logger.Debugf("Processing line: %s", line)
-				matches := macRegex.FindStringSubmatch(line)
-				if len(matches) > 0 {
-					// Convert to standard format (XX:XX:XX:XX:XX:XX)
-					mac := strings.ReplaceAll(matches[0], "-", ":")
-					mac = strings.ToUpper(mac)
-					s.logger.Debugf("Found MAC for %s: %s", targetIP, mac)
-					return mac, nil
-				}
-			}
-		}
-	default:
-		macRegex := regexp.MustCompile(`([0-9a-fA-F]{2}:){5}[0-9a-fA-F]{2}`)
-		for _, line := range lines {
-			if strings.Contains(line, targetIP) {
-				s.logger.Debugf("Processing line: %s", line)
-				matches := macRegex.FindStringSubmatch(line)
-				if len(matches) > 0 {
-					mac := strings.ToUpper(matches[0])
-					s.logger.Debugf("Found MAC for %s: %s", targetIP, mac)
-					return mac, nil
-				}
-			}
-		}
+	ttl := 0
+	if m := pingTTL.FindSubmatch(output); m != nil {
+		ttl, _ = strconv.Atoi(string(m[1]))
 	}
-
-	s.logger.Debugf("No MAC address found in ARP output for %s", targetIP)
-	return "", fmt.Errorf("MAC address not found for IP %s", targetIP)
+	return ttl, true
 }
 
 // getVendorFromMAC attempts to identify vendor from MAC address OUI
@@ -258,6 +304,9 @@ func (s *Scanner) parseNetworkRange(networkRange string) ([]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid CIDR notation: %v", err)
 	}
+	if utils.IsEnumerationTooLarge(ipNet) {
+		return nil, fmt.Errorf("IPv6 range %s is too large to enumerate; use neighbour discovery instead", networkRange)
+	}
 
 	var ips []string
 
@@ -37,10 +37,13 @@ type VendorManager struct {
 	logger     *logrus.Logger
 }
 
+// DefaultVendorDatabase is the OUI vendor database used when no path is configured
+const DefaultVendorDatabase = "configs/oui_vendors.json"
+
 // NewVendorManager creates a new vendor manager
 func NewVendorManager(configPath string, logger *logrus.Logger) *VendorManager {
 	if configPath == "" {
-		configPath = "configs/oui_vendors.json"
+		configPath = DefaultVendorDatabase
 	}
 
 	vm := &VendorManager{
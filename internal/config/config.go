@@ -0,0 +1,275 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"network-discovery/internal/ports"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is the configuration file loaded when --config-file is not given
+const DefaultPath = "config.yaml"
+
+// EnvPrefix prefixes environment variables that override configuration values,
+// e.g. ND_SERVER_PORT or ND_SNMP_DEFAULT_COMMUNITIES=public,private
+const EnvPrefix = "ND"
+
+// Config mirrors config.yaml
+type Config struct {
+	Server         ServerConfig        `yaml:"server"`
+	SNMP           SNMPConfig          `yaml:"snmp"`
+	Scanning       ScanningConfig      `yaml:"scanning"`
+	Scheduler      SchedulerConfig     `yaml:"scheduler"`
+	Inventory      InventoryConfig     `yaml:"inventory"`
+	Logging        LoggingConfig       `yaml:"logging"`
+	Security       SecurityConfig      `yaml:"security"`
+	Features       FeaturesConfig      `yaml:"features"`
+	PortProfiles   map[string]string   `yaml:"port_profiles"`
+	VendorPatterns map[string][]string `yaml:"vendor_patterns"`
+	Performance    PerformanceConfig   `yaml:"performance"`
+}
+
+type ServerConfig struct {
+	Host         string        `yaml:"host"`
+	Port         int           `yaml:"port"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+}
+
+type SNMPConfig struct {
+	Timeout            time.Duration `yaml:"timeout"`
+	Retries            int           `yaml:"retries"`
+	DefaultCommunities []string      `yaml:"default_communities"`
+	Version            string        `yaml:"version"`
+	Port               int           `yaml:"port"`
+}
+
+type ScanningConfig struct {
+	MaxWorkers       int           `yaml:"max_workers"`
+	DefaultRanges    []string      `yaml:"default_ranges"`
+	MaxScanDuration  time.Duration `yaml:"max_scan_duration"`
+	QuickScanTimeout time.Duration `yaml:"quick_scan_timeout"`
+	ActiveARP        bool          `yaml:"active_arp"`
+	PortScanner      string        `yaml:"port_scanner"`
+	PortScanRate     int           `yaml:"port_scan_rate"`
+	MaxHostProbes    int           `yaml:"max_host_probes"`
+	VendorDatabase   string        `yaml:"vendor_database"`
+	FingerprintRules string        `yaml:"fingerprint_rules"`
+}
+
+type SchedulerConfig struct {
+	MaxConcurrentScans int `yaml:"max_concurrent_scans"`
+	MaxScansPerClient  int `yaml:"max_scans_per_client"`
+	MaxQueuedScans     int `yaml:"max_queued_scans"`
+	MaxProbes          int `yaml:"max_probes"`
+	MaxHostsPerScan    int `yaml:"max_hosts_per_scan"`
+}
+
+type InventoryConfig struct {
+	Path     string        `yaml:"path"`
+	MaxScans int           `yaml:"max_scans"`
+	MaxAge   time.Duration `yaml:"max_age"`
+}
+
+type LoggingConfig struct {
+	Level                string `yaml:"level"`
+	Format               string `yaml:"format"`
+	Output               string `yaml:"output"`
+	FilePath             string `yaml:"file_path"`
+	EnableRequestLogging bool   `yaml:"enable_request_logging"`
+}
+
+type SecurityConfig struct {
+	EnableCORS     bool     `yaml:"enable_cors"`
+	CORSOrigins    []string `yaml:"cors_origins"`
+	RateLimit      int      `yaml:"rate_limit"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	EnableAuth     bool     `yaml:"enable_auth"`
+}
+
+type FeaturesConfig struct {
+	EnableFingerprinting bool `yaml:"enable_fingerprinting"`
+	EnableTopology       bool `yaml:"enable_topology"`
+	EnableTraps          bool `yaml:"enable_traps"`
+	EnableMonitoring     bool `yaml:"enable_monitoring"`
+}
+
+type PerformanceConfig struct {
+	EnableCaching  bool          `yaml:"enable_caching"`
+	CacheTTL       time.Duration `yaml:"cache_ttl"`
+	MaxCacheSize   int           `yaml:"max_cache_size"`
+	EnablePooling  bool          `yaml:"enable_pooling"`
+	MaxConnections int           `yaml:"max_connections"`
+}
+
+// Default returns the built-in configuration used when no file is present
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Host:         "0.0.0.0",
+			Port:         8080,
+			ReadTimeout:  5 * time.Minute,
+			WriteTimeout: 5 * time.Minute,
+			IdleTimeout:  10 * time.Minute,
+		},
+		SNMP: SNMPConfig{
+			Timeout:            5 * time.Second,
+			Retries:            2,
+			DefaultCommunities: []string{"public", "private", "community", "admin"},
+			Version:            "2c",
+			Port:               161,
+		},
+		Scanning: ScanningConfig{
+			MaxWorkers:       50,
+			MaxScanDuration:  10 * time.Minute,
+			QuickScanTimeout: 2 * time.Second,
+			PortScanner:      "auto",
+			PortScanRate:     1000,
+			MaxHostProbes:    100,
+			VendorDatabase:   "configs/oui_vendors.json",
+			FingerprintRules: "configs/fingerprints.yaml",
+		},
+		Scheduler: SchedulerConfig{
+			MaxConcurrentScans: 2,
+			MaxQueuedScans:     20,
+			MaxProbes:          200,
+			MaxHostsPerScan:    4096,
+		},
+		Inventory: InventoryConfig{
+			Path:     "data/inventory.db",
+			MaxScans: 1000,
+			MaxAge:   90 * 24 * time.Hour,
+		},
+		Logging: LoggingConfig{
+			Level:                "info",
+			Format:               "json",
+			Output:               "stdout",
+			EnableRequestLogging: true,
+		},
+		Security: SecurityConfig{
+			EnableCORS:  true,
+			CORSOrigins: []string{"*"},
+		},
+		Features: FeaturesConfig{
+			EnableFingerprinting: true,
+			EnableTopology:       true,
+		},
+		Performance: PerformanceConfig{
+			CacheTTL:       5 * time.Minute,
+			MaxCacheSize:   100,
+			EnablePooling:  true,
+			MaxConnections: 10,
+		},
+	}
+}
+
+// Load reads the YAML file at path on top of the defaults, applies environment
+// overrides and validates the result. An empty path loads defaults and environment only.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+		}
+	}
+
+	if err := applyEnv(cfg, EnvPrefix, os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks value ranges and cross-field requirements
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port must be between 1 and 65535")
+	check(c.Server.ReadTimeout >= 0 && c.Server.WriteTimeout >= 0 && c.Server.IdleTimeout >= 0, "server timeouts must not be negative")
+
+	check(c.SNMP.Timeout > 0, "snmp.timeout must be positive")
+	check(c.SNMP.Retries >= 0, "snmp.retries must not be negative")
+	check(len(c.SNMP.DefaultCommunities) > 0, "snmp.default_communities must not be empty")
+	check(c.SNMP.Version == "1" || c.SNMP.Version == "2c", "snmp.version must be \"1\" or \"2c\" (SNMPv3 is used when v3 credentials are supplied)")
+	check(c.SNMP.Port > 0 && c.SNMP.Port <= 65535, "snmp.port must be between 1 and 65535")
+
+	check(c.Scanning.MaxWorkers > 0, "scanning.max_workers must be positive")
+	check(c.Scanning.MaxScanDuration >= 0, "scanning.max_scan_duration must not be negative")
+	check(c.Scanning.QuickScanTimeout > 0, "scanning.quick_scan_timeout must be positive")
+	check(c.Scanning.PortScanRate >= 0, "scanning.port_scan_rate must not be negative")
+	check(c.Scanning.MaxHostProbes >= 0, "scanning.max_host_probes must not be negative")
+	if err := ports.ValidateBackend(c.Scanning.PortScanner); err != nil {
+		check(false, "scanning.port_scanner: %v", err)
+	}
+
+	check(c.Scheduler.MaxConcurrentScans > 0, "scheduler.max_concurrent_scans must be positive")
+	check(c.Scheduler.MaxScansPerClient >= 0, "scheduler.max_scans_per_client must not be negative")
+	check(c.Scheduler.MaxQueuedScans >= 0, "scheduler.max_queued_scans must not be negative")
+	check(c.Scheduler.MaxProbes >= 0, "scheduler.max_probes must not be negative")
+	check(c.Scheduler.MaxHostsPerScan >= 0, "scheduler.max_hosts_per_scan must not be negative")
+
+	check(c.Inventory.MaxScans >= 0, "inventory.max_scans must not be negative")
+	check(c.Inventory.MaxAge >= 0, "inventory.max_age must not be negative")
+
+	_, err := logrus.ParseLevel(c.Logging.Level)
+	check(err == nil, "logging.level %q is not a valid level", c.Logging.Level)
+	check(c.Logging.Format == "json" || c.Logging.Format == "text", "logging.format must be json or text")
+	switch c.Logging.Output {
+	case "stdout", "stderr":
+	case "file":
+		check(c.Logging.FilePath != "", "logging.file_path is required when logging.output is file")
+	default:
+		check(false, "logging.output must be stdout, stderr or file")
+	}
+
+	check(!c.Security.EnableCORS || len(c.Security.CORSOrigins) > 0, "security.cors_origins must not be empty when CORS is enabled")
+	check(c.Security.RateLimit >= 0, "security.rate_limit must not be negative")
+	for _, proxy := range c.Security.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err == nil || net.ParseIP(proxy) != nil, "security.trusted_proxies: %q is not an IP address or CIDR range", proxy)
+	}
+
+	check(c.Performance.CacheTTL >= 0, "performance.cache_ttl must not be negative")
+	check(c.Performance.MaxCacheSize >= 0, "performance.max_cache_size must not be negative")
+	check(c.Performance.MaxConnections >= 0, "performance.max_connections must not be negative")
+
+	for vendor, patterns := range c.VendorPatterns {
+		check(len(patterns) > 0, "vendor_patterns.%s must list at least one pattern", vendor)
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Address returns the host:port the HTTP server listens on
+func (s ServerConfig) Address() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
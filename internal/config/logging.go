@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewLogger creates the application logger described by the logging section
+func (l LoggingConfig) NewLogger() (*logrus.Logger, error) {
+	logger := logrus.New()
+
+	level, err := logrus.ParseLevel(l.Level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level: %v", err)
+	}
+	logger.SetLevel(level)
+
+	if l.Format == "text" {
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	} else {
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	}
+
+	switch l.Output {
+	case "stderr":
+		logger.SetOutput(os.Stderr)
+	case "file":
+		file, err := os.OpenFile(l.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %v", err)
+		}
+		logger.SetOutput(file)
+	default:
+		logger.SetOutput(os.Stdout)
+	}
+
+	return logger, nil
+}
@@ -0,0 +1,230 @@
+package diff
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"network-discovery/internal/models"
+	"network-discovery/internal/scanner"
+)
+
+// Compare reports the devices that appeared, disappeared or changed between
+// an older and a newer topology. Devices are matched by MAC address first and
+// by their IP or an address no other device shares otherwise, so a device that
+// moved to a new IP is reported as changed rather than as one removal plus one
+// addition.
+func Compare(older, newer *models.NetworkTopology) *models.TopologyDiff {
+	result := &models.TopologyDiff{
+		Added:   []models.Device{},
+		Removed: []models.Device{},
+		Changed: []models.DeviceChange{},
+	}
+	if older == nil {
+		older = &models.NetworkTopology{}
+	}
+	if newer == nil {
+		newer = &models.NetworkTopology{}
+	}
+	result.FromTime = older.ScanTime
+	result.ToTime = newer.ScanTime
+
+	// Addresses several devices list (docker0, libvirt bridges, VRRP/HSRP VIPs) identify
+	// none of them; a device's own IP wins over another device's address table
+	oldByMAC := make(map[string]int)
+	oldByIP := make(map[string]int)
+	oldShared := scanner.SharedAddresses(older.Devices, func(i int) int { return i })
+	for i, d := range older.Devices {
+		if mac := normalizeMAC(d.MACAddress); mac != "" {
+			oldByMAC[mac] = i
+		}
+		if _, ok := oldByIP[d.IP]; !ok && d.IP != "" {
+			oldByIP[d.IP] = i
+		}
+	}
+	for i, d := range older.Devices {
+		for _, ip := range d.IPs() {
+			if _, ok := oldByIP[ip]; !ok && !oldShared[ip] {
+				oldByIP[ip] = i
+			}
+		}
+	}
+
+	newMACs := make(map[string]bool)
+	for _, d := range newer.Devices {
+		if mac := normalizeMAC(d.MACAddress); mac != "" {
+			newMACs[mac] = true
+		}
+	}
+	newShared := scanner.SharedAddresses(newer.Devices, func(i int) int { return i })
+
+	paired := make(map[int]bool)
+	for _, d := range newer.Devices {
+		idx, ok := matchDevice(d, oldByMAC, oldByIP, newMACs, newShared, older.Devices, paired)
+		if !ok {
+			result.Added = append(result.Added, d)
+			continue
+		}
+		paired[idx] = true
+
+		change := compareDevice(older.Devices[idx], d)
+		if len(change.Changes) == 0 && len(change.OpenedPorts) == 0 && len(change.ClosedPorts) == 0 {
+			result.Summary.Unchanged++
+			continue
+		}
+		result.Changed = append(result.Changed, change)
+	}
+
+	for i, d := range older.Devices {
+		if !paired[i] {
+			result.Removed = append(result.Removed, d)
+		}
+	}
+
+	sortDevices(result.Added)
+	sortDevices(result.Removed)
+	sort.Slice(result.Changed, func(a, b int) bool {
+		return lessIP(result.Changed[a].IP, result.Changed[b].IP)
+	})
+
+	result.Summary.Added = len(result.Added)
+	result.Summary.Removed = len(result.Removed)
+	result.Summary.Changed = len(result.Changed)
+
+	return result
+}
+
+// matchDevice finds the unpaired older device that corresponds to d
+func matchDevice(d models.Device, oldByMAC, oldByIP map[string]int, newMACs, newShared map[string]bool,
+	oldDevices []models.Device, paired map[int]bool) (int, bool) {
+	if mac := normalizeMAC(d.MACAddress); mac != "" {
+		if idx, ok := oldByMAC[mac]; ok && !paired[idx] {
+			return idx, true
+		}
+	}
+
+	for _, ip := range d.IPs() {
+		if ip != d.IP && newShared[ip] {
+			continue
+		}
+		idx, ok := oldByIP[ip]
+		if !ok || paired[idx] {
+			continue
+		}
+
+		// Same IP but the old MAC is still present elsewhere in the new scan: the old
+		// device moved, so this is a different device that took over the address
+		oldMAC := normalizeMAC(oldDevices[idx].MACAddress)
+		if oldMAC != "" && oldMAC != normalizeMAC(d.MACAddress) && newMACs[oldMAC] {
+			continue
+		}
+		return idx, true
+	}
+	return 0, false
+}
+
+// addressList renders the addresses of a device in a stable order
+func addressList(d models.Device) string {
+	ips := d.IPs()
+	sort.Slice(ips, func(a, b int) bool {
+		return lessIP(ips[a], ips[b])
+	})
+	return strings.Join(ips, ",")
+}
+
+// switchPortString renders where a device is connected, e.g. "192.168.1.2 Gi0/5 vlan 10"
+func switchPortString(p *models.SwitchPort) string {
+	s := p.Switch + " " + p.Port
+	if p.VLAN != 0 {
+		s += fmt.Sprintf(" vlan %d", p.VLAN)
+	}
+	return s
+}
+
+// compareDevice lists attribute and port differences between two observations of one device
+func compareDevice(older, newer models.Device) models.DeviceChange {
+	change := models.DeviceChange{
+		IP:         newer.IP,
+		MACAddress: newer.MACAddress,
+	}
+
+	add := func(field, oldVal, newVal string) {
+		if oldVal != newVal {
+			change.Changes = append(change.Changes, models.FieldChange{Field: field, Old: oldVal, New: newVal})
+		}
+	}
+
+	add("ip", older.IP, newer.IP)
+	// Scans from before address lists were recorded only know the primary IP
+	if len(older.Addresses) > 0 && len(newer.Addresses) > 0 {
+		add("addresses", addressList(older), addressList(newer))
+	}
+	// A MAC that was simply not resolved in one scan is not a change
+	if oldMAC, newMAC := normalizeMAC(older.MACAddress), normalizeMAC(newer.MACAddress); oldMAC != "" && newMAC != "" {
+		add("mac_address", oldMAC, newMAC)
+	}
+	add("vendor", older.Vendor, newer.Vendor)
+	add("hostname", older.Hostname, newer.Hostname)
+	add("description", older.Description, newer.Description)
+	add("model", older.Model, newer.Model)
+	add("version", older.Version, newer.Version)
+	add("os", older.OS, newer.OS)
+	add("device_type", older.DeviceType, newer.DeviceType)
+	// A device that was not located in one scan has not moved
+	if older.SwitchPort != nil && newer.SwitchPort != nil {
+		add("switch_port", switchPortString(older.SwitchPort), switchPortString(newer.SwitchPort))
+	}
+
+	change.OpenedPorts, change.ClosedPorts = comparePorts(older.OpenPorts, newer.OpenPorts)
+	return change
+}
+
+// comparePorts returns the ports open only in newer and the ports open only in older
+func comparePorts(older, newer []models.PortInfo) (opened, closed []models.PortInfo) {
+	oldSet := make(map[string]bool)
+	for _, p := range older {
+		oldSet[portKey(p)] = true
+	}
+	newSet := make(map[string]bool)
+	for _, p := range newer {
+		newSet[portKey(p)] = true
+		if !oldSet[portKey(p)] {
+			opened = append(opened, p)
+		}
+	}
+	for _, p := range older {
+		if !newSet[portKey(p)] {
+			closed = append(closed, p)
+		}
+	}
+	return opened, closed
+}
+
+func portKey(p models.PortInfo) string {
+	return fmt.Sprintf("%s/%d", strings.ToLower(p.Protocol), p.Port)
+}
+
+func normalizeMAC(mac string) string {
+	mac = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(mac), "-", ":"))
+	if mac == "00:00:00:00:00:00" {
+		return ""
+	}
+	return mac
+}
+
+func sortDevices(devices []models.Device) {
+	sort.Slice(devices, func(a, b int) bool {
+		return lessIP(devices[a].IP, devices[b].IP)
+	})
+}
+
+// lessIP orders addresses numerically, falling back to string order for unparsable values
+func lessIP(a, b string) bool {
+	ipA, errA := netip.ParseAddr(a)
+	ipB, errB := netip.ParseAddr(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return ipA.Less(ipB)
+}
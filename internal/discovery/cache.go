@@ -0,0 +1,124 @@
+package discovery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"network-discovery/internal/models"
+)
+
+type cachedDevice struct {
+	device  models.Device
+	size    int // Encoded size of the device, its share of maxBytes
+	expires time.Time
+}
+
+// deviceCache keeps single device scan results for a short time, up to maxBytes of
+// devices measured by their encoded size. A nil cache is valid and never stores anything.
+type deviceCache struct {
+	ttl      time.Duration
+	maxBytes int
+
+	mu      sync.Mutex
+	entries map[string]cachedDevice
+	size    int
+}
+
+func newDeviceCache(ttl time.Duration, maxSizeMB int) *deviceCache {
+	return &deviceCache{
+		ttl:      ttl,
+		maxBytes: maxSizeMB * 1024 * 1024,
+		entries:  make(map[string]cachedDevice),
+	}
+}
+
+// get returns a copy of the cached device for key if it has not expired
+func (c *deviceCache) get(key string) (*models.Device, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		c.remove(key)
+		return nil, false
+	}
+	device := entry.device
+	return &device, true
+}
+
+// put stores a copy of device, evicting expired entries, then the oldest ones, until it
+// fits. Devices larger than the whole cache are not stored.
+func (c *deviceCache) put(key string, device *models.Device) {
+	if c == nil {
+		return
+	}
+	encoded, err := json.Marshal(device)
+	if err != nil || len(encoded) > c.maxBytes {
+		return
+	}
+	size := len(encoded)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(key)
+	now := time.Now()
+	if c.size+size > c.maxBytes {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				c.remove(k)
+			}
+		}
+	}
+	for c.size+size > c.maxBytes {
+		var oldestKey string
+		var oldest time.Time
+		for k, entry := range c.entries {
+			if oldestKey == "" || entry.expires.Before(oldest) {
+				oldestKey, oldest = k, entry.expires
+			}
+		}
+		c.remove(oldestKey)
+	}
+
+	c.entries[key] = cachedDevice{device: *device, size: size, expires: now.Add(c.ttl)}
+	c.size += size
+}
+
+// remove drops the entry for key, if any; the caller holds mu
+func (c *deviceCache) remove(key string) {
+	if entry, ok := c.entries[key]; ok {
+		c.size -= entry.size
+		delete(c.entries, key)
+	}
+}
+
+// deviceCacheKey identifies a single device scan by everything that affects its result.
+// The SNMP credentials are part of the key in full, hashed, so that a result is only
+// returned to callers holding the communities or SNMPv3 passphrases that produced it.
+func deviceCacheKey(ip string, communities []string, v3Credentials []models.SNMPv3Credential,
+	enablePortScan bool, portScanner string, portOptions *models.PortScanOptions) string {
+	credentials, _ := json.Marshal(struct {
+		Communities   []string
+		V3Credentials []models.SNMPv3Credential
+	}{communities, v3Credentials})
+	credentialHash := sha256.Sum256(credentials)
+
+	key, _ := json.Marshal(struct {
+		IP          string
+		Credentials string
+		PortScan    bool
+		PortScanner string
+		PortOptions *models.PortScanOptions
+	}{ip, hex.EncodeToString(credentialHash[:]), enablePortScan, portScanner, portOptions})
+	return string(key)
+}
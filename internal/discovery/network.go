@@ -1,9 +1,17 @@
 package discovery
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"time"
 
+	"network-discovery/internal/arp"
+	"network-discovery/internal/config"
+	"network-discovery/internal/diff"
+	"network-discovery/internal/fingerprint"
+	"network-discovery/internal/inventory"
+	"network-discovery/internal/limits"
 	"network-discovery/internal/models"
 	"network-discovery/internal/ports"
 	"network-discovery/internal/scanner"
@@ -12,7 +20,10 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// NetworkDiscovery is safe for concurrent scans: the SNMP client and full scanner are
+// created once and every request passes its own options down to them.
 type NetworkDiscovery struct {
+	snmpClient  *snmp.Client
 	fullScanner *scanner.FullScanner
 	logger      *logrus.Logger
 
@@ -23,8 +34,36 @@ type NetworkDiscovery struct {
 	defaultTimeout time.Duration
 	defaultRetries int
 	maxWorkers     int
+
+	// Upper bound for a single scan (scanning.max_scan_duration); 0 disables the limit
+	maxScanDuration time.Duration
+
+	// Optional persistent inventory; scans are recorded when set
+	store *inventory.Store
+
+	// Settings applied to every SNMP client and full scanner (see config.yaml)
+	snmpPort         int
+	snmpVersion      string
+	quickScanTimeout time.Duration
+	topology         bool
+	vendorDatabase   string
+	activeARP        bool
+	portScanner      string
+	defaultRanges    []string
+
+	// Fingerprint rules (configs/fingerprints.yaml); nil when fingerprinting is disabled
+	fingerprints *fingerprint.Engine
+
+	// Optional cache of single device results (performance.enable_caching)
+	cache *deviceCache
+
+	// Admission control and probe budget shared by all scans
+	scheduler *Scheduler
 }
 
+// DefaultMaxScanDuration mirrors scanning.max_scan_duration in config.yaml
+const DefaultMaxScanDuration = 10 * time.Minute
+
 func NewNetworkDiscovery() *NetworkDiscovery {
 	logger := logrus.New()
 	logger.SetLevel(logrus.InfoLevel)
@@ -34,19 +73,30 @@ func NewNetworkDiscovery() *NetworkDiscovery {
 
 	// Create full scanner with 50 concurrent workers
 	fullScanner := scanner.NewFullScanner(client, 50)
+	fingerprints := fingerprint.NewEngine(fingerprint.DefaultRulesPath, nil, logger)
+	fullScanner.SetFingerprints(fingerprints)
 
 	return &NetworkDiscovery{
-		fullScanner: fullScanner,
-		logger:      logger,
+		snmpClient:   client,
+		fullScanner:  fullScanner,
+		fingerprints: fingerprints,
+		logger:       logger,
 		defaultCommunities: []string{
 			"public",
 			"private",
 			"community",
 			"admin",
 		},
-		defaultTimeout: time.Second * 5,
-		defaultRetries: 2,
-		maxWorkers:     50,
+		defaultTimeout:   time.Second * 5,
+		defaultRetries:   2,
+		maxWorkers:       50,
+		maxScanDuration:  DefaultMaxScanDuration,
+		snmpPort:         snmp.DefaultPort,
+		snmpVersion:      "2c",
+		quickScanTimeout: snmp.DefaultQuickTimeout,
+		topology:         true,
+		portScanner:      ports.BackendAuto,
+		scheduler:        NewScheduler(config.Default().Scheduler, logger),
 	}
 }
 
@@ -60,77 +110,171 @@ func NewNetworkDiscoveryWithLogLevel(level logrus.Level) *NetworkDiscovery {
 
 	// Create full scanner with 50 concurrent workers and custom logger
 	fullScanner := scanner.NewFullScannerWithLogger(client, 50, logger)
+	fingerprints := fingerprint.NewEngine(fingerprint.DefaultRulesPath, nil, logger)
+	fullScanner.SetFingerprints(fingerprints)
 
 	return &NetworkDiscovery{
-		fullScanner: fullScanner,
-		logger:      logger,
+		snmpClient:   client,
+		fullScanner:  fullScanner,
+		fingerprints: fingerprints,
+		logger:       logger,
 		defaultCommunities: []string{
 			"public",
 			"private",
 			"community",
 			"admin",
 		},
-		defaultTimeout: time.Second * 5,
-		defaultRetries: 2,
-		maxWorkers:     50,
+		defaultTimeout:   time.Second * 5,
+		defaultRetries:   2,
+		maxWorkers:       50,
+		maxScanDuration:  DefaultMaxScanDuration,
+		snmpPort:         snmp.DefaultPort,
+		snmpVersion:      "2c",
+		quickScanTimeout: snmp.DefaultQuickTimeout,
+		topology:         true,
+		portScanner:      ports.BackendAuto,
+		scheduler:        NewScheduler(config.Default().Scheduler, logger),
 	}
 }
 
-// PerformFullScan performs comprehensive network discovery using both SNMP and ARP
-func (nd *NetworkDiscovery) PerformFullScan(req *models.ScanRequest) (*models.FullScanResult, error) {
-	nd.logger.Infof("Starting full network discovery for range: %s", req.NetworkRange)
+// NewNetworkDiscoveryWithConfig creates the discovery service from the loaded configuration
+func NewNetworkDiscoveryWithConfig(cfg *config.Config, logger *logrus.Logger) *NetworkDiscovery {
+	nd := &NetworkDiscovery{
+		logger:             logger,
+		defaultCommunities: cfg.SNMP.DefaultCommunities,
+		defaultTimeout:     cfg.SNMP.Timeout,
+		defaultRetries:     cfg.SNMP.Retries,
+		maxWorkers:         cfg.Scanning.MaxWorkers,
+		maxScanDuration:    cfg.Scanning.MaxScanDuration,
+		snmpPort:           cfg.SNMP.Port,
+		snmpVersion:        cfg.SNMP.Version,
+		quickScanTimeout:   cfg.Scanning.QuickScanTimeout,
+		topology:           cfg.Features.EnableTopology,
+		vendorDatabase:     cfg.Scanning.VendorDatabase,
+		activeARP:          cfg.Scanning.ActiveARP,
+		portScanner:        cfg.Scanning.PortScanner,
+		defaultRanges:      cfg.Scanning.DefaultRanges,
+		scheduler:          NewScheduler(cfg.Scheduler, logger),
+	}
 
-	// Use provided communities or default ones
-	communities := req.Communities
-	if len(communities) == 0 {
-		communities = nd.defaultCommunities
-		nd.logger.Infof("Using default communities: %v", communities)
-	} else {
-		nd.logger.Infof("Using provided communities: %v", communities)
+	if cfg.Performance.EnableCaching && cfg.Performance.CacheTTL > 0 {
+		nd.cache = newDeviceCache(cfg.Performance.CacheTTL, cfg.Performance.MaxCacheSize)
+	}
+	if cfg.Features.EnableFingerprinting {
+		nd.fingerprints = fingerprint.NewEngine(cfg.Scanning.FingerprintRules, cfg.VendorPatterns, logger)
 	}
 
-	// Update client settings if provided
-	if req.Timeout > 0 || req.Retries > 0 {
-		timeout := nd.defaultTimeout
-		retries := nd.defaultRetries
+	nd.snmpClient = nd.newSNMPClient()
+	nd.fullScanner = nd.newFullScanner(nd.snmpClient)
+	return nd
+}
 
-		if req.Timeout > 0 {
-			timeout = time.Duration(req.Timeout) * time.Second
-			nd.logger.Infof("Using custom timeout: %d seconds", req.Timeout)
-		}
-		if req.Retries > 0 {
-			retries = req.Retries
-			nd.logger.Infof("Using custom retries: %d", req.Retries)
-		}
+// newSNMPClient creates the shared SNMP client with the configured defaults, port and version
+func (nd *NetworkDiscovery) newSNMPClient() *snmp.Client {
+	client := snmp.NewClientWithLogger(nd.defaultTimeout, nd.defaultRetries, nd.logger)
+	client.SetPort(nd.snmpPort)
+	if err := client.SetCommunityVersion(nd.snmpVersion); err != nil {
+		nd.logger.Warnf("%v, using 2c", err)
+	}
+	client.SetQuickTimeout(nd.quickScanTimeout)
+	client.SetTopology(nd.topology)
+	return client
+}
 
-		// Create new client with updated settings
-		var client *snmp.Client
-		if nd.logger.Level == logrus.DebugLevel {
-			client = snmp.NewClientWithLogger(timeout, retries, nd.logger)
-		} else {
-			client = snmp.NewClient(timeout, retries)
-		}
-		nd.fullScanner = scanner.NewFullScannerWithLogger(client, nd.maxWorkers, nd.logger)
+// newFullScanner creates a full scanner around client with the configured ARP settings and fingerprint rules
+func (nd *NetworkDiscovery) newFullScanner(client *snmp.Client) *scanner.FullScanner {
+	fullScanner := scanner.NewFullScannerWithConfig(client, nd.maxWorkers, nd.logger, nd.vendorDatabase)
+	fullScanner.SetActiveARPEnabled(nd.activeARP)
+	fullScanner.SetFingerprints(nd.fingerprints)
+	return fullScanner
+}
+
+// DefaultRanges returns the network ranges suggested when a client does not specify one
+func (nd *NetworkDiscovery) DefaultRanges() []string {
+	return nd.defaultRanges
+}
+
+// VendorDatabasePath returns the OUI vendor database file in use
+func (nd *NetworkDiscovery) VendorDatabasePath() string {
+	if nd.vendorDatabase == "" {
+		return arp.DefaultVendorDatabase
 	}
+	return nd.vendorDatabase
+}
 
-	// Perform the scan based on scan type
-	var topology *models.NetworkTopology
-	var err error
+// Fingerprints returns the fingerprint rule engine, or nil when fingerprinting is disabled
+func (nd *NetworkDiscovery) Fingerprints() *fingerprint.Engine {
+	return nd.fingerprints
+}
 
-	// Configure port scan toggle (default true)
-	enablePortScan := true
-	if req.EnablePortScan != nil {
-		enablePortScan = *req.EnablePortScan
+// SetMaxScanDuration sets the upper bound enforced on every scan; 0 disables the limit
+func (nd *NetworkDiscovery) SetMaxScanDuration(d time.Duration) {
+	nd.maxScanDuration = d
+}
+
+// SetActiveARP enables broadcasting raw ARP requests during ARP scans (Linux only).
+// Call it before serving requests.
+func (nd *NetworkDiscovery) SetActiveARP(enabled bool) {
+	nd.activeARP = enabled
+	nd.fullScanner.SetActiveARPEnabled(enabled)
+}
+
+// SetStore enables recording of every scan result in the persistent inventory
+func (nd *NetworkDiscovery) SetStore(store *inventory.Store) {
+	nd.store = store
+}
+
+// Store returns the persistent inventory, or nil when persistence is disabled
+func (nd *NetworkDiscovery) Store() *inventory.Store {
+	return nd.store
+}
+
+// Scheduler returns the scheduler that queues range scans
+func (nd *NetworkDiscovery) Scheduler() *Scheduler {
+	return nd.scheduler
+}
+
+// scanContext derives a context bounded by the configured maximum scan duration whose
+// probes draw from the shared probe budget
+func (nd *NetworkDiscovery) scanContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = limits.WithBudget(ctx, nd.scheduler.Probes())
+	if nd.maxScanDuration > 0 {
+		return context.WithTimeout(ctx, nd.maxScanDuration)
+	}
+	return context.WithCancel(ctx)
+}
+
+// PerformFullScan performs comprehensive network discovery using both SNMP and ARP.
+// Cancelling ctx (or exceeding the maximum scan duration) returns the partial result
+// with Topology.Cancelled set.
+func (nd *NetworkDiscovery) PerformFullScan(ctx context.Context, req *models.ScanRequest) (*models.FullScanResult, error) {
+	nd.logger.Infof("Starting full network discovery for range: %s", req.NetworkRange)
+
+	opts, err := nd.scanOptions(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait for a scan slot; the maximum scan duration starts once the scan runs
+	release, err := nd.scheduler.Acquire(ctx, req.NetworkRange)
+	if err != nil {
+		return nil, err
 	}
-	nd.fullScanner.SetPortScanEnabled(enablePortScan)
+	defer release()
+
+	ctx, cancel := nd.scanContext(ctx)
+	defer cancel()
+
+	// Perform the scan based on scan type
+	var topology *models.NetworkTopology
 
 	switch req.ScanType {
 	case "snmp":
-		topology, err = nd.fullScanner.PerformSNMPScan(req.NetworkRange, communities)
+		topology, err = nd.fullScanner.PerformSNMPScan(ctx, req.NetworkRange, opts)
 	case "arp":
-		topology, err = nd.fullScanner.PerformARPScan(req.NetworkRange)
+		topology, err = nd.fullScanner.PerformARPScan(ctx, req.NetworkRange, opts)
 	case "full", "":
-		topology, err = nd.fullScanner.PerformFullScan(req.NetworkRange, communities)
+		topology, err = nd.fullScanner.PerformFullScan(ctx, req.NetworkRange, opts)
 	default:
 		return nil, fmt.Errorf("invalid scan type: %s. Supported types: snmp, arp, full", req.ScanType)
 	}
@@ -139,6 +283,10 @@ func (nd *NetworkDiscovery) PerformFullScan(req *models.ScanRequest) (*models.Fu
 		return nil, fmt.Errorf("network scan failed: %v", err)
 	}
 
+	if topology.Cancelled {
+		nd.logger.Warnf("Discovery cancelled (%s). Returning partial results", topology.CancelReason)
+	}
+
 	nd.logger.Infof("Discovery completed. Found %d devices (%d reachable, %d SNMP, %d ARP-only)",
 		topology.TotalCount, topology.ReachableCount, topology.SNMPCount, topology.ARPCount)
 
@@ -147,12 +295,16 @@ func (nd *NetworkDiscovery) PerformFullScan(req *models.ScanRequest) (*models.Fu
 
 	// Create scan info
 	scanInfo := models.ScanInfo{
-		ScanType:        req.ScanType,
-		NetworkRange:    req.NetworkRange,
-		SNMPCommunities: communities,
-		Timeout:         req.Timeout,
-		Retries:         req.Retries,
-		WorkerCount:     nd.maxWorkers,
+		ScanType:             req.ScanType,
+		NetworkRange:         req.NetworkRange,
+		SNMPCommunities:      opts.SNMP.Communities,
+		SNMPv3Users:          v3Usernames(req.V3Credentials),
+		PortScanner:          opts.PortScanner.Name(),
+		Timeout:              req.Timeout,
+		Retries:              req.Retries,
+		WorkerCount:          nd.maxWorkers,
+		IncludeIPv6:          req.IncludeIPv6,
+		IncludeARPCacheHosts: req.IncludeARPCacheHosts,
 	}
 
 	result := &models.FullScanResult{
@@ -161,13 +313,25 @@ func (nd *NetworkDiscovery) PerformFullScan(req *models.ScanRequest) (*models.Fu
 		ScanInfo:   scanInfo,
 	}
 
+	// Persist the scan and upsert its devices into the inventory
+	if nd.store != nil {
+		if scanID, err := nd.store.RecordScan(req, topology); err != nil {
+			nd.logger.Errorf("Failed to record scan in inventory: %v", err)
+		} else {
+			result.ScanID = scanID
+		}
+	}
+
+	if req.DiffPrevious {
+		result.Diff = nd.diffWithPrevious(req.NetworkRange, result)
+	}
+
 	return result, nil
 }
 
-// DiscoverNetwork performs SNMP-only network discovery (backward compatibility)
-func (nd *NetworkDiscovery) DiscoverNetwork(req *models.ScanRequest) (*models.NetworkTopology, error) {
-	nd.logger.Infof("Starting SNMP network discovery for range: %s", req.NetworkRange)
-
+// scanOptions builds the per-request scan options from req and the service defaults.
+// Nothing shared is modified, so concurrent requests cannot see each other's settings.
+func (nd *NetworkDiscovery) scanOptions(req *models.ScanRequest) (scanner.Options, error) {
 	// Use provided communities or default ones
 	communities := req.Communities
 	if len(communities) == 0 {
@@ -177,16 +341,25 @@ func (nd *NetworkDiscovery) DiscoverNetwork(req *models.ScanRequest) (*models.Ne
 		nd.logger.Infof("Using provided communities: %v", communities)
 	}
 
-	// Update client settings if provided
+	if len(req.V3Credentials) > 0 {
+		if err := snmp.ValidateV3Credentials(req.V3Credentials); err != nil {
+			return scanner.Options{}, fmt.Errorf("invalid SNMPv3 credentials: %v", err)
+		}
+		nd.logger.Infof("Using %d SNMPv3 credential set(s): %v", len(req.V3Credentials), v3Usernames(req.V3Credentials))
+	}
+
+	// Zero timeout or retries fall back to the client defaults
+	snmpOptions := snmp.Options{
+		Communities:   communities,
+		V3Credentials: req.V3Credentials,
+		Timeout:       time.Duration(req.Timeout) * time.Second,
+		Retries:       req.Retries,
+	}
 	if req.Timeout > 0 {
 		nd.logger.Infof("Using custom timeout: %d seconds", req.Timeout)
-		var client *snmp.Client
-		if nd.logger.Level == logrus.DebugLevel {
-			client = snmp.NewClientWithLogger(time.Duration(req.Timeout)*time.Second, req.Retries, nd.logger)
-		} else {
-			client = snmp.NewClient(time.Duration(req.Timeout)*time.Second, req.Retries)
-		}
-		nd.fullScanner = scanner.NewFullScannerWithLogger(client, nd.maxWorkers, nd.logger)
+	}
+	if req.Retries > 0 {
+		nd.logger.Infof("Using custom retries: %d", req.Retries)
 	}
 
 	// Configure port scan toggle (default true)
@@ -194,10 +367,122 @@ func (nd *NetworkDiscovery) DiscoverNetwork(req *models.ScanRequest) (*models.Ne
 	if req.EnablePortScan != nil {
 		enablePortScan = *req.EnablePortScan
 	}
-	nd.fullScanner.SetPortScanEnabled(enablePortScan)
+
+	// Select the port scanner backend (nmap when installed, built-in TCP otherwise)
+	portSpec, err := ports.ParseSpec(req.PortOptions)
+	if err != nil {
+		return scanner.Options{}, fmt.Errorf("invalid port options: %v", err)
+	}
+	portScanner, err := ports.NewBackend(nd.portScannerName(req.PortScanner), portSpec, nd.maxWorkers, nd.logger)
+	if err != nil {
+		return scanner.Options{}, err
+	}
+
+	return scanner.Options{
+		SNMP:           snmpOptions,
+		EnablePortScan: enablePortScan,
+		PortScanner:    portScanner,
+		IncludeIPv6:    req.IncludeIPv6,
+		ARPCacheHosts:  req.IncludeARPCacheHosts,
+	}, nil
+}
+
+// portScannerName falls back to the configured default backend when a request does not choose one
+func (nd *NetworkDiscovery) portScannerName(name string) string {
+	if name == "" {
+		return nd.portScanner
+	}
+	return name
+}
+
+// diffWithPrevious compares a freshly recorded scan with the previous complete scan of
+// the same range
+func (nd *NetworkDiscovery) diffWithPrevious(networkRange string, result *models.FullScanResult) *models.TopologyDiff {
+	if nd.store == nil || result.ScanID == "" {
+		nd.logger.Warnf("Cannot diff scan of %s: device inventory is disabled", networkRange)
+		return nil
+	}
+	if result.Topology.Cancelled {
+		// Devices the scan did not get to would all show as removed
+		nd.logger.Infof("Not diffing cancelled scan %s of %s", result.ScanID, networkRange)
+		return nil
+	}
+
+	previous, err := nd.store.PreviousScan(networkRange, result.ScanID)
+	if err != nil {
+		nd.logger.Infof("No previous complete scan of %s to diff against", networkRange)
+		return nil
+	}
+
+	topologyDiff := diff.Compare(previous.Topology, result.Topology)
+	topologyDiff.FromScanID = previous.ID
+	topologyDiff.ToScanID = result.ScanID
+
+	nd.logger.Infof("Diff against scan %s: %d added, %d removed, %d changed",
+		previous.ID, topologyDiff.Summary.Added, topologyDiff.Summary.Removed, topologyDiff.Summary.Changed)
+
+	return topologyDiff
+}
+
+// ImportNmap merges the hosts of an nmap XML report into a recorded scan and records
+// the merged topology as a new scan of the same network range. The result's diff
+// lists what the report changed.
+func (nd *NetworkDiscovery) ImportNmap(scan *models.ScanRecord, hosts []ports.HostResult) (*models.FullScanResult, scanner.NmapImport, error) {
+	if nd.store == nil {
+		return nil, scanner.NmapImport{}, fmt.Errorf("device inventory is disabled")
+	}
+	if scan.Topology == nil {
+		return nil, scanner.NmapImport{}, fmt.Errorf("scan %s has no topology", scan.ID)
+	}
+
+	topology, summary := nd.fullScanner.ImportNmap(scan.Topology, hosts)
+	nd.logger.Infof("Merged nmap report into scan %s: %d hosts, %d matched, %d added, %d skipped",
+		scan.ID, summary.Hosts, summary.Matched, summary.Added, summary.Skipped)
+
+	req := &models.ScanRequest{NetworkRange: scan.NetworkRange, ScanType: scan.ScanType}
+	scanID, err := nd.store.RecordScan(req, topology)
+	if err != nil {
+		return nil, summary, err
+	}
+
+	topologyDiff := diff.Compare(scan.Topology, topology)
+	topologyDiff.FromScanID = scan.ID
+	topologyDiff.ToScanID = scanID
+
+	return &models.FullScanResult{
+		ScanID:     scanID,
+		Topology:   topology,
+		Statistics: nd.GetNetworkStatistics(topology),
+		ScanInfo: models.ScanInfo{
+			ScanType:     scan.ScanType,
+			NetworkRange: scan.NetworkRange,
+			PortScanner:  ports.BackendNmap,
+		},
+		Diff: topologyDiff,
+	}, summary, nil
+}
+
+// DiscoverNetwork performs SNMP-only network discovery (backward compatibility)
+func (nd *NetworkDiscovery) DiscoverNetwork(ctx context.Context, req *models.ScanRequest) (*models.NetworkTopology, error) {
+	nd.logger.Infof("Starting SNMP network discovery for range: %s", req.NetworkRange)
+
+	opts, err := nd.scanOptions(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait for a scan slot; the maximum scan duration starts once the scan runs
+	release, err := nd.scheduler.Acquire(ctx, req.NetworkRange)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx, cancel := nd.scanContext(ctx)
+	defer cancel()
 
 	// Perform SNMP-only scan
-	topology, err := nd.fullScanner.PerformSNMPScan(req.NetworkRange, communities)
+	topology, err := nd.fullScanner.PerformSNMPScan(ctx, req.NetworkRange, opts)
 	if err != nil {
 		return nil, fmt.Errorf("network scan failed: %v", err)
 	}
@@ -208,46 +493,86 @@ func (nd *NetworkDiscovery) DiscoverNetwork(req *models.ScanRequest) (*models.Ne
 	return topology, nil
 }
 
-func (nd *NetworkDiscovery) DiscoverDevice(ip string, communities []string, enablePortScan bool) (*models.Device, error) {
+func (nd *NetworkDiscovery) DiscoverDevice(ctx context.Context, ip string, communities []string, v3Credentials []models.SNMPv3Credential, enablePortScan bool, portScanner string, portOptions *models.PortScanOptions) (*models.Device, error) {
 	nd.logger.Infof("Discovering single device: %s", ip)
 
 	if len(communities) == 0 {
 		communities = nd.defaultCommunities
 	}
 
-	// Create a temporary SNMP client for single device query
-	client := snmp.NewClientWithLogger(nd.defaultTimeout, nd.defaultRetries, nd.logger)
-	device, err := client.QueryDevice(ip, communities)
+	portSpec, err := ports.ParseSpec(portOptions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port options: %v", err)
+	}
+	backend, err := ports.NewBackend(nd.portScannerName(portScanner), portSpec, 5, nd.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := deviceCacheKey(ip, communities, v3Credentials, enablePortScan, backend.Name(), portOptions)
+	if cached, ok := nd.cache.get(cacheKey); ok {
+		nd.logger.Debugf("Returning cached result for device %s", ip)
+		return cached, nil
+	}
+
+	// Single device queries skip the scan queue but share the probe budget
+	ctx = limits.WithBudget(ctx, nd.scheduler.Probes())
+	release, err := limits.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	device, err := nd.snmpClient.QueryDevice(ctx, ip, snmp.Options{
+		Communities:   communities,
+		V3Credentials: v3Credentials,
+	})
+	release()
 	if err != nil {
 		return nil, fmt.Errorf("device discovery failed: %v", err)
 	}
 
 	// Best-effort port scan for the single device
-	_ = scanner.NewFullScannerWithLogger(client, nd.maxWorkers, nd.logger) // ensure consistency
-	portScanner := ports.NewScannerWithLogger(5, nd.logger)
-	if device != nil {
-		if portsInfo, err := portScanner.ScanHost(device.IP); err == nil {
-			device.OpenPorts = portsInfo
-		} else {
-			nd.logger.Debugf("Port scan failed for %s: %v", device.IP, err)
+	if device != nil && enablePortScan {
+		if release, err := limits.Acquire(ctx); err == nil {
+			host, err := ports.ScanHostDetails(ctx, backend, device.IP)
+			release()
+			if err == nil {
+				scanner.MergeHostResult(device, host)
+			} else {
+				nd.logger.Debugf("Port scan failed for %s (%s): %v", device.IP, backend.Name(), err)
+			}
 		}
 	}
+	if device != nil {
+		nd.fullScanner.Fingerprint(device)
+	}
+
+	if device != nil && ctx.Err() == nil {
+		nd.cache.put(cacheKey, device)
+	}
 
 	return device, nil
 }
 
-func (nd *NetworkDiscovery) QuickDiscovery(networkRange string, communities []string) ([]string, error) {
+func (nd *NetworkDiscovery) QuickDiscovery(ctx context.Context, networkRange string, communities []string) ([]string, error) {
 	nd.logger.Infof("Starting quick discovery for range: %s", networkRange)
 
+	release, err := nd.scheduler.Acquire(ctx, networkRange)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx, cancel := nd.scanContext(ctx)
+	defer cancel()
+
 	if len(communities) == 0 {
 		communities = nd.defaultCommunities
 	}
 
 	// Use SNMP scanner for quick discovery
-	client := snmp.NewClientWithLogger(nd.defaultTimeout, nd.defaultRetries, nd.logger)
-	scanner := snmp.NewScannerWithLogger(client, nd.maxWorkers, nd.logger)
+	scanner := snmp.NewScannerWithLogger(nd.snmpClient, nd.maxWorkers, nd.logger)
 
-	reachableIPs, err := scanner.QuickScan(networkRange, communities)
+	reachableIPs, err := scanner.QuickScan(ctx, networkRange, communities)
 	if err != nil {
 		return nil, fmt.Errorf("quick discovery failed: %v", err)
 	}
@@ -266,8 +591,19 @@ func (nd *NetworkDiscovery) GetNetworkStatistics(topology *models.NetworkTopolog
 	stats["snmp_devices"] = topology.SNMPCount
 	stats["arp_only_devices"] = topology.ARPCount
 
+	// LLDP/CDP topology
+	placeholderCount := 0
+	for _, device := range topology.Devices {
+		if device.Placeholder {
+			placeholderCount++
+		}
+	}
+	stats["placeholder_devices"] = placeholderCount
+	stats["links"] = len(topology.Links)
+
 	// Vendor distribution
 	vendorCount := make(map[string]int)
+	typeCount := make(map[string]int)
 	scanMethodCount := make(map[string]int)
 	macAddressCount := 0
 
@@ -280,6 +616,13 @@ func (nd *NetworkDiscovery) GetNetworkStatistics(topology *models.NetworkTopolog
 				vendorCount["Unknown"]++
 			}
 
+			// Count device types
+			if device.DeviceType != "" {
+				typeCount[device.DeviceType]++
+			} else {
+				typeCount["unknown"]++
+			}
+
 			// Count scan methods
 			scanMethodCount[device.ScanMethod]++
 
@@ -291,6 +634,7 @@ func (nd *NetworkDiscovery) GetNetworkStatistics(topology *models.NetworkTopolog
 	}
 
 	stats["vendor_distribution"] = vendorCount
+	stats["type_distribution"] = typeCount
 	stats["scan_method_distribution"] = scanMethodCount
 	stats["devices_with_mac"] = macAddressCount
 
@@ -329,13 +673,20 @@ func (nd *NetworkDiscovery) GetNetworkStatistics(topology *models.NetworkTopolog
 	return stats
 }
 
+// ValidateNetworkRange checks that networkRange is a CIDR range a scan would accept,
+// without scanning it or waiting for a scan slot
 func (nd *NetworkDiscovery) ValidateNetworkRange(networkRange string) error {
-	client := snmp.NewClientWithLogger(nd.defaultTimeout, nd.defaultRetries, nd.logger)
-	scanner := snmp.NewScannerWithLogger(client, nd.maxWorkers, nd.logger)
-
-	_, err := scanner.QuickScan(networkRange, []string{"public"})
-	if err != nil {
+	if _, _, err := net.ParseCIDR(networkRange); err != nil {
 		return fmt.Errorf("invalid network range: %v", err)
 	}
-	return nil
+	return nd.scheduler.CheckRange(networkRange)
+}
+
+// v3Usernames returns the usernames of the given SNMPv3 credentials (safe for logs and responses)
+func v3Usernames(creds []models.SNMPv3Credential) []string {
+	var users []string
+	for _, cred := range creds {
+		users = append(users, cred.Username)
+	}
+	return users
 }
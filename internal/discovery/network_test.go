@@ -0,0 +1,232 @@
+package discovery
+
+import (
+	"context"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"network-discovery/internal/config"
+	"network-discovery/internal/models"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	oidSysDescr = "1.3.6.1.2.1.1.1.0"
+	oidSysName  = "1.3.6.1.2.1.1.5.0"
+)
+
+// fakeAgent is an SNMP agent that answers any community. Its sysName carries the
+// community of the request, so a device shows which community found it; sysDescr and
+// sysName are answered after delay, the rest of the system group is missing.
+type fakeAgent struct {
+	sysName string
+	delay   time.Duration
+}
+
+// serve answers SNMP requests on conn until it is closed
+func (a fakeAgent) serve(conn net.PacketConn) {
+	decoder := &gosnmp.GoSNMP{Version: gosnmp.Version2c, Logger: gosnmp.NewLogger(nil)}
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req, err := decoder.SnmpDecodePacket(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		resp := &gosnmp.SnmpPacket{
+			Version:   req.Version,
+			Community: req.Community,
+			PDUType:   gosnmp.GetResponse,
+			RequestID: req.RequestID,
+			Logger:    gosnmp.NewLogger(nil),
+		}
+		delay := time.Duration(0)
+		for _, v := range req.Variables {
+			switch name := strings.TrimPrefix(v.Name, "."); {
+			case req.PDUType != gosnmp.GetRequest:
+				// Tables are empty
+				resp.Variables = append(resp.Variables, gosnmp.SnmpPDU{Name: v.Name, Type: gosnmp.EndOfMibView})
+			case name == oidSysDescr || name == oidSysName:
+				value := a.sysName + "-" + req.Community
+				resp.Variables = append(resp.Variables, gosnmp.SnmpPDU{Name: v.Name, Type: gosnmp.OctetString, Value: []byte(value)})
+				delay = a.delay
+			default:
+				resp.Variables = append(resp.Variables, gosnmp.SnmpPDU{Name: v.Name, Type: gosnmp.NoSuchObject})
+			}
+		}
+
+		out, err := resp.MarshalMsg()
+		if err != nil {
+			continue
+		}
+		go func() {
+			time.Sleep(delay)
+			conn.WriteTo(out, addr)
+		}()
+	}
+}
+
+// startAgents serves an agent on each loopback address, all on the same UDP port, and
+// returns the port
+func startAgents(t *testing.T, agents map[string]fakeAgent) int {
+	t.Helper()
+	for attempt := 0; attempt < 10; attempt++ {
+		var conns []net.PacketConn
+		port := 0
+		ok := true
+		for ip := range agents {
+			conn, err := net.ListenPacket("udp", net.JoinHostPort(ip, strconv.Itoa(port)))
+			if err != nil {
+				ok = false
+				break
+			}
+			conns = append(conns, conn)
+			port = conn.LocalAddr().(*net.UDPAddr).Port
+		}
+		if !ok {
+			for _, conn := range conns {
+				conn.Close()
+			}
+			continue
+		}
+		for _, conn := range conns {
+			conn := conn
+			t.Cleanup(func() { conn.Close() })
+			go agents[conn.LocalAddr().(*net.UDPAddr).IP.String()].serve(conn)
+		}
+		return port
+	}
+	t.Fatal("failed to bind the fake SNMP agents")
+	return 0
+}
+
+// startTCPService accepts and closes connections on every loopback address
+func startTCPService(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			io.WriteString(conn, "SSH-2.0-OpenSSH_9.6\r\n")
+			conn.Close()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// TestConcurrentScansKeepTheirOptions runs scans with different communities, timeouts
+// and port scan settings at the same time; run it with -race. Each scan must find
+// exactly the devices its own options can reach.
+func TestConcurrentScansKeepTheirOptions(t *testing.T) {
+	snmpPort := startAgents(t, map[string]fakeAgent{
+		"127.0.0.1": {sysName: "fast"},
+		// Answers within a 4 second timeout, but not within 1 second and one retry
+		"127.0.0.2": {sysName: "slow", delay: 2500 * time.Millisecond},
+	})
+	tcpPort := startTCPService(t)
+
+	cfg := config.Default()
+	cfg.SNMP.Port = snmpPort
+	cfg.Scanning.PortScanner = "tcp"
+	cfg.Scanning.VendorDatabase = "../../configs/oui_vendors.json"
+	cfg.Features.EnableFingerprinting = false
+	cfg.Performance.EnableCaching = false
+	cfg.Scheduler.MaxConcurrentScans = 4
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	nd := NewNetworkDiscoveryWithConfig(cfg, logger)
+
+	request := func(community string, timeout int, portScan bool) *models.ScanRequest {
+		return &models.ScanRequest{
+			NetworkRange:   "127.0.0.0/30",
+			Communities:    []string{community},
+			Timeout:        timeout,
+			Retries:        1,
+			ScanType:       "snmp",
+			EnablePortScan: &portScan,
+			PortScanner:    "tcp",
+			PortOptions:    &models.PortScanOptions{Ports: strconv.Itoa(tcpPort)},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		req      *models.ScanRequest
+		legacy   bool     // DiscoverNetwork instead of PerformFullScan
+		want     []string // Hostnames of the reachable devices, in IP order
+		wantPort bool
+	}{
+		{name: "alpha with port scan", req: request("alpha", 1, true), want: []string{"fast-alpha"}, wantPort: true},
+		{name: "alpha without port scan", req: request("alpha", 1, false), legacy: true, want: []string{"fast-alpha"}},
+		{name: "beta with long timeout", req: request("beta", 4, false), want: []string{"fast-beta", "slow-beta"}},
+		{name: "beta with short timeout", req: request("beta", 1, true), legacy: true, want: []string{"fast-beta"}, wantPort: true},
+	}
+
+	results := make([][]models.Device, len(tests))
+	errs := make([]error, len(tests))
+	var wg sync.WaitGroup
+	for i, tt := range tests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var topology *models.NetworkTopology
+			if tt.legacy {
+				topology, errs[i] = nd.DiscoverNetwork(context.Background(), tt.req)
+			} else {
+				var result *models.FullScanResult
+				if result, errs[i] = nd.PerformFullScan(context.Background(), tt.req); result != nil {
+					topology = result.Topology
+				}
+			}
+			if topology != nil {
+				results[i] = topology.Devices
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, tt := range tests {
+		if errs[i] != nil {
+			t.Errorf("%s: %v", tt.name, errs[i])
+			continue
+		}
+
+		var found []models.Device
+		for _, d := range results[i] {
+			if d.IsReachable {
+				found = append(found, d)
+			}
+		}
+		sort.Slice(found, func(a, b int) bool { return found[a].IP < found[b].IP })
+
+		var hostnames []string
+		for _, d := range found {
+			hostnames = append(hostnames, d.Hostname)
+			if hasPort := len(d.OpenPorts) > 0; hasPort != tt.wantPort {
+				t.Errorf("%s: %s has open ports %+v, want port scan %v", tt.name, d.IP, d.OpenPorts, tt.wantPort)
+			}
+		}
+		if strings.Join(hostnames, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: found %v, want %v", tt.name, hostnames, tt.want)
+		}
+	}
+}
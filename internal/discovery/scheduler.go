@@ -0,0 +1,256 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"network-discovery/internal/config"
+	"network-discovery/internal/events"
+	"network-discovery/internal/limits"
+	"network-discovery/internal/models"
+	"network-discovery/internal/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrQueueFull is returned when scheduler.max_queued_scans scans are already waiting
+var ErrQueueFull = errors.New("scan queue is full")
+
+// ErrRangeTooLarge is returned for ranges with more hosts than scheduler.max_hosts_per_scan
+var ErrRangeTooLarge = errors.New("network range is too large")
+
+type clientKey struct{}
+
+// WithClient tags ctx with the client (e.g. the remote IP) a scan runs for.
+// The scheduler uses it to share scan slots fairly between clients.
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+func clientFrom(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
+
+// Scheduler admits range scans. At most maxScans run at once and the rest wait in a FIFO
+// queue, from which the client with the fewest running scans is served first. All scans
+// draw their probes from one shared budget.
+type Scheduler struct {
+	maxScans     int
+	maxPerClient int // 0 = no per-client cap
+	maxQueued    int // 0 = unbounded queue
+	maxHosts     int // 0 = any range size
+	probes       *limits.Budget
+	logger       *logrus.Logger
+
+	mu      sync.Mutex
+	running int
+	clients map[string]int // running scans per client
+	queue   []*ticket
+}
+
+// ticket is a scan waiting in (or admitted from) the queue
+type ticket struct {
+	client   string
+	position int // 1-based queue position
+	admitted bool
+	changed  chan struct{} // signalled when position or admitted changes
+}
+
+func NewScheduler(cfg config.SchedulerConfig, logger *logrus.Logger) *Scheduler {
+	maxScans := cfg.MaxConcurrentScans
+	if maxScans <= 0 {
+		maxScans = 1
+	}
+	return &Scheduler{
+		maxScans:     maxScans,
+		maxPerClient: cfg.MaxScansPerClient,
+		maxQueued:    cfg.MaxQueuedScans,
+		maxHosts:     cfg.MaxHostsPerScan,
+		probes:       limits.NewBudget(cfg.MaxProbes),
+		logger:       logger,
+		clients:      make(map[string]int),
+	}
+}
+
+// Probes returns the probe budget shared by all scans (nil when unlimited)
+func (s *Scheduler) Probes() *limits.Budget {
+	return s.probes
+}
+
+// CheckRange rejects network ranges with more hosts than a single scan may cover
+func (s *Scheduler) CheckRange(networkRange string) error {
+	if s.maxHosts <= 0 {
+		return nil
+	}
+	_, ipNet, err := net.ParseCIDR(networkRange)
+	if err != nil {
+		// Malformed ranges are reported by the scanners
+		return nil
+	}
+	if utils.IsEnumerationTooLarge(ipNet) {
+		// Large IPv6 ranges are covered by neighbour discovery on the local links, not enumerated
+		return nil
+	}
+	size, _ := utils.GetNetworkSize(networkRange)
+	if size > s.maxHosts {
+		return fmt.Errorf("%w: %s has %d hosts, at most %d are allowed per scan", ErrRangeTooLarge, networkRange, size, s.maxHosts)
+	}
+	return nil
+}
+
+// Acquire waits until a scan of networkRange may start and returns the function that
+// frees its slot. While waiting it emits queued events with the scan's position on ctx,
+// and a started event once admitted. It fails with ErrRangeTooLarge, ErrQueueFull, or
+// ctx.Err() when ctx ends in the queue.
+func (s *Scheduler) Acquire(ctx context.Context, networkRange string) (func(), error) {
+	if err := s.CheckRange(networkRange); err != nil {
+		return nil, err
+	}
+
+	t := &ticket{client: clientFrom(ctx), changed: make(chan struct{}, 1)}
+
+	s.mu.Lock()
+	s.queue = append(s.queue, t)
+	s.dispatchLocked()
+	if !t.admitted && s.maxQueued > 0 && len(s.queue) > s.maxQueued {
+		s.removeLocked(t)
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d scans running, %d waiting", ErrQueueFull, s.running, s.maxQueued)
+	}
+	s.mu.Unlock()
+
+	reported := 0
+	for {
+		s.mu.Lock()
+		admitted := t.admitted
+		status := models.QueueStatus{Position: t.position, Length: len(s.queue), Running: s.running}
+		s.mu.Unlock()
+
+		if admitted {
+			events.Emit(ctx, models.ScanEvent{Type: models.EventStarted, Queue: &status})
+			var once sync.Once
+			return func() { once.Do(func() { s.release(t.client) }) }, nil
+		}
+
+		if status.Position != reported {
+			if reported == 0 {
+				s.logger.Infof("Scan of %s queued at position %d (%d running)", networkRange, status.Position, status.Running)
+			}
+			reported = status.Position
+			events.Emit(ctx, models.ScanEvent{Type: models.EventQueued, Queue: &status})
+		}
+
+		select {
+		case <-t.changed:
+		case <-ctx.Done():
+			s.mu.Lock()
+			if t.admitted {
+				s.mu.Unlock()
+				s.release(t.client)
+			} else {
+				s.removeLocked(t)
+				s.dispatchLocked()
+				s.mu.Unlock()
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// release frees the slot of a finished scan and admits the next one
+func (s *Scheduler) release(client string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running--
+	if s.clients[client]--; s.clients[client] <= 0 {
+		delete(s.clients, client)
+	}
+	s.dispatchLocked()
+}
+
+// dispatchLocked admits queued scans while slots are free, preferring the earliest
+// ticket of the client with the fewest running scans, then renumbers the queue
+func (s *Scheduler) dispatchLocked() {
+	for s.running < s.maxScans {
+		next := -1
+		for i, t := range s.queue {
+			n := s.clients[t.client]
+			if s.maxPerClient > 0 && n >= s.maxPerClient {
+				continue
+			}
+			if next < 0 || n < s.clients[s.queue[next].client] {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+
+		t := s.queue[next]
+		s.queue = append(s.queue[:next], s.queue[next+1:]...)
+		t.admitted = true
+		s.running++
+		s.clients[t.client]++
+		signal(t)
+	}
+	s.renumberLocked()
+}
+
+// removeLocked drops a ticket that left the queue without being admitted
+func (s *Scheduler) removeLocked(t *ticket) {
+	for i, queued := range s.queue {
+		if queued == t {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			break
+		}
+	}
+	s.renumberLocked()
+}
+
+// renumberLocked updates queue positions and wakes the tickets whose position changed
+func (s *Scheduler) renumberLocked() {
+	for i, t := range s.queue {
+		if t.position != i+1 {
+			t.position = i + 1
+			signal(t)
+		}
+	}
+}
+
+func signal(t *ticket) {
+	select {
+	case t.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Status returns a snapshot of running and queued scans
+func (s *Scheduler) Status() models.SchedulerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clients := make(map[string]int, len(s.clients))
+	for client, n := range s.clients {
+		clients[client] = n
+	}
+	for _, t := range s.queue {
+		clients[t.client]++
+	}
+
+	return models.SchedulerStatus{
+		Running:            s.running,
+		Queued:             len(s.queue),
+		MaxConcurrentScans: s.maxScans,
+		MaxScansPerClient:  s.maxPerClient,
+		MaxQueuedScans:     s.maxQueued,
+		MaxHostsPerScan:    s.maxHosts,
+		ProbesInUse:        s.probes.InUse(),
+		MaxProbes:          s.probes.Size(),
+		Clients:            clients,
+	}
+}
@@ -0,0 +1,124 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"network-discovery/internal/models"
+)
+
+// Observer receives live scan events. It is called from scanner worker goroutines
+// and must be safe for concurrent use.
+type Observer func(event models.ScanEvent)
+
+type observerKey struct{}
+
+// WithObserver returns a context whose scans report their events to obs
+func WithObserver(ctx context.Context, obs Observer) context.Context {
+	return context.WithValue(ctx, observerKey{}, obs)
+}
+
+// Emit sends an event to the observer attached to ctx, if any
+func Emit(ctx context.Context, event models.ScanEvent) {
+	obs, ok := ctx.Value(observerKey{}).(Observer)
+	if !ok || obs == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	obs(event)
+}
+
+// DeviceFound reports a device discovered (or enriched) during the given phase
+func DeviceFound(ctx context.Context, phase string, device *models.Device) {
+	if device == nil {
+		return
+	}
+	snapshot := *device
+	Emit(ctx, models.ScanEvent{
+		Type:   models.EventDevice,
+		Phase:  phase,
+		Device: &snapshot,
+	})
+}
+
+// PhaseComplete emits a single 100% progress event for a phase that is not tracked step by step
+func PhaseComplete(ctx context.Context, phase string, total, found int) {
+	Emit(ctx, models.ScanEvent{
+		Type:  models.EventProgress,
+		Phase: phase,
+		Progress: &models.ScanProgress{
+			Phase:        phase,
+			Probed:       total,
+			Total:        total,
+			Percent:      100,
+			DevicesFound: found,
+		},
+	})
+}
+
+// Tracker counts probed targets for a phase and emits throttled progress events
+type Tracker struct {
+	ctx    context.Context
+	phase  string
+	total  int
+	mu     sync.Mutex
+	probed int
+	found  int
+	last   int // last emitted percent
+}
+
+// NewTracker starts tracking a phase over total targets and emits the initial progress event
+func NewTracker(ctx context.Context, phase string, total int) *Tracker {
+	t := &Tracker{ctx: ctx, phase: phase, total: total, last: -1}
+	if total > 0 {
+		t.emit()
+	}
+	return t
+}
+
+// Step records one probed target; found marks that it produced a device.
+// Progress is emitted at most once per percent to keep large ranges cheap.
+func (t *Tracker) Step(found bool) {
+	t.mu.Lock()
+	t.probed++
+	if found {
+		t.found++
+	}
+	percent := t.percentLocked()
+	shouldEmit := percent != t.last || t.probed == t.total
+	t.mu.Unlock()
+
+	if shouldEmit {
+		t.emit()
+	}
+}
+
+func (t *Tracker) percentLocked() int {
+	if t.total <= 0 {
+		return 100
+	}
+	return t.probed * 100 / t.total
+}
+
+func (t *Tracker) emit() {
+	t.mu.Lock()
+	percent := t.percentLocked()
+	t.last = percent
+	progress := models.ScanProgress{
+		Phase:        t.phase,
+		Probed:       t.probed,
+		Total:        t.total,
+		Percent:      percent,
+		DevicesFound: t.found,
+	}
+	t.mu.Unlock()
+
+	Emit(t.ctx, models.ScanEvent{
+		Type:     models.EventProgress,
+		Phase:    t.phase,
+		Progress: &progress,
+	})
+}
@@ -0,0 +1,190 @@
+package export
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Export formats
+const (
+	FormatDOT       = "dot"
+	FormatGraphML   = "graphml"
+	FormatJSONGraph = "json-graph"
+)
+
+// Render encodes the graph in the given format and returns it with its content type
+func Render(graph *Graph, format string) ([]byte, string, error) {
+	switch format {
+	case FormatDOT:
+		return DOT(graph), "text/vnd.graphviz; charset=utf-8", nil
+	case FormatGraphML:
+		data, err := GraphML(graph)
+		return data, "application/graphml+xml; charset=utf-8", err
+	case FormatJSONGraph:
+		data, err := json.MarshalIndent(graph, "", "  ")
+		return data, "application/json; charset=utf-8", err
+	}
+	return nil, "", fmt.Errorf("unsupported format %q, use %s, %s or %s", format, FormatDOT, FormatGraphML, FormatJSONGraph)
+}
+
+// DOT encodes the graph for Graphviz as an undirected graph. Subnets are drawn as
+// ellipses and placeholder devices, known only from neighbour tables, dashed.
+func DOT(graph *Graph) []byte {
+	var b strings.Builder
+	b.WriteString("graph topology {\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, node := range graph.Nodes {
+		attrs := []string{"label=" + dotQuote(node.Label)}
+		switch node.Kind {
+		case "subnet":
+			attrs = append(attrs, "shape=ellipse")
+		case "placeholder":
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(node.ID), strings.Join(attrs, ", "))
+	}
+	for _, edge := range graph.Edges {
+		var attrs []string
+		if label := edgeLabel(edge); label != "" {
+			attrs = append(attrs, "label="+dotQuote(label))
+		}
+		if edge.Kind == "subnet" || edge.Kind == "gateway" {
+			attrs = append(attrs, "style=dotted")
+		}
+		fmt.Fprintf(&b, "  %s -- %s", dotQuote(edge.Source), dotQuote(edge.Target))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// dotQuote quotes a DOT identifier; line breaks become centred DOT line breaks
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// edgeLabel describes the ports of an edge, e.g. "Gi0/1 - eth0" or "Gi0/2 (VLAN 10)"
+func edgeLabel(edge Edge) string {
+	ports := edge.SourcePort
+	if edge.TargetPort != "" {
+		ports = strings.TrimPrefix(ports+" - "+edge.TargetPort, " - ")
+	}
+	if edge.VLAN > 0 {
+		ports = strings.TrimSpace(fmt.Sprintf("%s (VLAN %d)", ports, edge.VLAN))
+	}
+	if ports == "" {
+		return edge.Label
+	}
+	return ports
+}
+
+// GraphML document structure
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// GraphML encodes the graph as GraphML, with the node and edge fields as data keys
+func GraphML(graph *Graph) ([]byte, error) {
+	doc := graphMLDocument{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "label", For: "node", Name: "label", Type: "string"},
+			{ID: "kind", For: "node", Name: "kind", Type: "string"},
+			{ID: "ip", For: "node", Name: "ip", Type: "string"},
+			{ID: "hostname", For: "node", Name: "hostname", Type: "string"},
+			{ID: "mac", For: "node", Name: "mac_address", Type: "string"},
+			{ID: "vendor", For: "node", Name: "vendor", Type: "string"},
+			{ID: "type", For: "node", Name: "type", Type: "string"},
+			{ID: "edge_kind", For: "edge", Name: "kind", Type: "string"},
+			{ID: "source_port", For: "edge", Name: "source_port", Type: "string"},
+			{ID: "target_port", For: "edge", Name: "target_port", Type: "string"},
+			{ID: "vlan", For: "edge", Name: "vlan", Type: "int"},
+			{ID: "edge_label", For: "edge", Name: "label", Type: "string"},
+		},
+		Graph: graphMLGraph{ID: "topology", EdgeDefault: "undirected"},
+	}
+	for _, node := range graph.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: node.ID,
+			Data: graphMLValues(
+				"label", node.Label, "kind", node.Kind, "ip", node.IP, "hostname", node.Hostname,
+				"mac", node.MAC, "vendor", node.Vendor, "type", node.Type,
+			),
+		})
+	}
+	for i, edge := range graph.Edges {
+		vlan := ""
+		if edge.VLAN > 0 {
+			vlan = strconv.Itoa(edge.VLAN)
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     "e" + strconv.Itoa(i),
+			Source: edge.Source,
+			Target: edge.Target,
+			Data: graphMLValues(
+				"edge_kind", edge.Kind, "source_port", edge.SourcePort, "target_port", edge.TargetPort,
+				"vlan", vlan, "edge_label", edge.Label,
+			),
+		})
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode GraphML: %v", err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// graphMLValues pairs up keys and values, leaving out the empty values
+func graphMLValues(pairs ...string) []graphMLData {
+	var data []graphMLData
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			data = append(data, graphMLData{Key: pairs[i], Value: pairs[i+1]})
+		}
+	}
+	return data
+}
@@ -0,0 +1,256 @@
+package export
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"network-discovery/internal/models"
+)
+
+// Graph is a topology as nodes and edges, the common ground of the export formats
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"links"` // "links" is what D3 force layouts read
+}
+
+// Node is a device, or a subnet that groups hosts without known L2 links
+type Node struct {
+	ID       string `json:"id"`
+	Label    string `json:"label"`
+	Kind     string `json:"kind"` // "device", "placeholder" or "subnet"
+	IP       string `json:"ip,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	MAC      string `json:"mac_address,omitempty"`
+	Vendor   string `json:"vendor,omitempty"`
+	Type     string `json:"type,omitempty"`
+}
+
+// Edge connects two nodes
+type Edge struct {
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	Kind       string `json:"kind"` // "link" (LLDP/CDP), "switch_port", "subnet" or "gateway"
+	SourcePort string `json:"source_port,omitempty"`
+	TargetPort string `json:"target_port,omitempty"`
+	VLAN       int    `json:"vlan,omitempty"`
+	Label      string `json:"label,omitempty"`
+}
+
+// Build turns a topology into a graph. Edges come from the LLDP/CDP links and the
+// switch ports devices were located on. Devices left without any such edge are grouped
+// by subnet instead, and each subnet is connected to its default gateway: a device with
+// addresses in several subnets, one of them in this subnet.
+func Build(topology *models.NetworkTopology) *Graph {
+	graph := &Graph{Nodes: []Node{}, Edges: []Edge{}}
+	if topology == nil {
+		return graph
+	}
+
+	ids := make(map[string]bool)
+	for i := range topology.Devices {
+		node := deviceNode(&topology.Devices[i])
+		ids[node.ID] = true
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	connected := make(map[string]bool)
+	for _, link := range topology.Links {
+		if !ids[link.LocalDevice] || !ids[link.RemoteDevice] {
+			continue
+		}
+		graph.Edges = append(graph.Edges, Edge{
+			Source:     link.LocalDevice,
+			Target:     link.RemoteDevice,
+			Kind:       "link",
+			SourcePort: link.LocalPort,
+			TargetPort: link.RemotePort,
+			Label:      strings.Join(link.Protocols, "+"),
+		})
+		connected[link.LocalDevice], connected[link.RemoteDevice] = true, true
+	}
+	for i := range topology.Devices {
+		d := &topology.Devices[i]
+		if d.SwitchPort == nil || !ids[d.SwitchPort.Switch] {
+			continue
+		}
+		id := nodeID(d)
+		graph.Edges = append(graph.Edges, Edge{
+			Source:     d.SwitchPort.Switch,
+			Target:     id,
+			Kind:       "switch_port",
+			SourcePort: d.SwitchPort.Port,
+			VLAN:       d.SwitchPort.VLAN,
+		})
+		connected[d.SwitchPort.Switch], connected[id] = true, true
+	}
+
+	groupBySubnet(graph, topology.Devices, connected)
+	return graph
+}
+
+// groupBySubnet connects the devices without L2 edges to a node for their subnet, and
+// every such subnet to its gateway
+func groupBySubnet(graph *Graph, devices []models.Device, connected map[string]bool) {
+	// Prefixes reported by SNMP address tables, and the devices with several of them
+	var known []netip.Prefix
+	owners := make(map[netip.Prefix][]string)
+	for i := range devices {
+		for _, addr := range devices[i].Addresses {
+			prefix, ok := addressPrefix(addr.IP, addr.PrefixLength)
+			if !ok || addr.PrefixLength == 0 {
+				continue
+			}
+			if _, seen := owners[prefix]; !seen {
+				known = append(known, prefix)
+			}
+			owners[prefix] = append(owners[prefix], nodeID(&devices[i]))
+		}
+	}
+	routers := make(map[string]int)
+	for _, ids := range owners {
+		for _, id := range unique(ids) {
+			routers[id]++
+		}
+	}
+
+	subnets := make(map[netip.Prefix]bool)
+	for i := range devices {
+		d := &devices[i]
+		id := nodeID(d)
+		if connected[id] || routers[id] > 1 || id == "" {
+			continue
+		}
+		prefix, ok := subnetOf(d, known)
+		if !ok {
+			continue
+		}
+		subnetID := "subnet:" + prefix.String()
+		if !subnets[prefix] {
+			subnets[prefix] = true
+			graph.Nodes = append(graph.Nodes, Node{ID: subnetID, Label: prefix.String(), Kind: "subnet"})
+			for _, owner := range unique(owners[prefix]) {
+				if routers[owner] > 1 {
+					graph.Edges = append(graph.Edges, Edge{Source: subnetID, Target: owner, Kind: "gateway", Label: "gateway"})
+					break
+				}
+			}
+		}
+		graph.Edges = append(graph.Edges, Edge{Source: subnetID, Target: id, Kind: "subnet"})
+	}
+}
+
+// subnetOf returns the subnet of a device's primary IP: its own prefix when SNMP reported
+// it, the longest known prefix containing it, or else the /24 (IPv4) or /64 (IPv6)
+func subnetOf(d *models.Device, known []netip.Prefix) (netip.Prefix, bool) {
+	for _, addr := range d.Addresses {
+		if addr.IP == d.IP && addr.PrefixLength > 0 {
+			return addressPrefix(addr.IP, addr.PrefixLength)
+		}
+	}
+	ip, err := netip.ParseAddr(d.IP)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	ip = ip.WithZone("")
+
+	best, found := netip.Prefix{}, false
+	for _, prefix := range known {
+		if prefix.Contains(ip) && (!found || prefix.Bits() > best.Bits()) {
+			best, found = prefix, true
+		}
+	}
+	if found {
+		return best, true
+	}
+	bits := 24
+	if ip.Is6() {
+		bits = 64
+	}
+	prefix, err := ip.Prefix(bits)
+	return prefix, err == nil
+}
+
+// addressPrefix returns the network of ip/length
+func addressPrefix(ip string, length int) (netip.Prefix, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	prefix, err := addr.WithZone("").Prefix(length)
+	return prefix, err == nil
+}
+
+// deviceNode describes a device as a node labelled with its hostname, IP, vendor and type
+func deviceNode(d *models.Device) Node {
+	node := Node{
+		ID:       nodeID(d),
+		Kind:     "device",
+		IP:       d.IP,
+		Hostname: d.Hostname,
+		MAC:      d.MACAddress,
+		Type:     deviceType(d),
+	}
+	if d.Vendor != "Unknown" {
+		node.Vendor = d.Vendor
+	}
+	if d.Placeholder {
+		node.Kind = "placeholder"
+	}
+
+	var lines []string
+	for _, part := range []string{d.Hostname, d.IP, node.Vendor, node.Type} {
+		if part != "" && !contains(lines, part) {
+			lines = append(lines, part)
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, node.ID)
+	}
+	node.Label = strings.Join(lines, "\n")
+	return node
+}
+
+// nodeID identifies a device the way topology links do: by primary IP, or by name or
+// MAC for placeholders without an address
+func nodeID(d *models.Device) string {
+	switch {
+	case d.IP != "":
+		return d.IP
+	case d.Hostname != "":
+		return d.Hostname
+	}
+	return d.MACAddress
+}
+
+// deviceType is the classified type of a device, or else how much is known about it
+func deviceType(d *models.Device) string {
+	switch {
+	case d.DeviceType != "":
+		return d.DeviceType
+	case d.Placeholder:
+		return fmt.Sprintf("%s neighbour", d.ScanMethod)
+	case d.SNMPVersion != "":
+		return "SNMP device"
+	}
+	return "host"
+}
+
+func unique(list []string) []string {
+	var result []string
+	for _, item := range list {
+		if !contains(result, item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package fingerprint
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"network-discovery/internal/models"
+)
+
+// sysServices bits of the OSI layers a device serves (SNMPv2-MIB)
+const (
+	serviceDatalink     = 0x02 // Layer 2, bridges and switches
+	serviceInternet     = 0x04 // Layer 3, routers and hosts that route
+	serviceApplications = 0x40 // Layer 7, hosts
+)
+
+// minTypeConfidence is the combined confidence below which a device stays unclassified
+const minTypeConfidence = 30
+
+// capabilityTypes are the device types the SNMP and LLDP/CDP capabilities point to,
+// with their confidence
+var capabilityTypes = map[string]struct {
+	deviceType string
+	confidence int
+}{
+	"printer":         {"printer", 90},
+	"telephone":       {"phone", 80},
+	"wlanAccessPoint": {"access_point", 70},
+	"bridge":          {"switch", 60},
+	"switch":          {"switch", 60},
+	"router":          {"router", 50},
+}
+
+// nmapTypes are the device types of nmap OS classes
+var nmapTypes = map[string]string{
+	"router":           "router",
+	"broadband router": "router",
+	"switch":           "switch",
+	"firewall":         "firewall",
+	"WAP":              "access_point",
+	"printer":          "printer",
+	"phone":            "phone",
+	"VoIP phone":       "phone",
+	"webcam":           "camera",
+}
+
+// vote is one signal in favour of a device type
+type vote struct {
+	deviceType string
+	confidence int
+	evidence   string
+}
+
+// Classify sets the DeviceType of a device from its SNMP sysServices, the router, bridge
+// and printer MIBs it implements (or the LLDP/CDP capabilities of placeholders), the
+// class of its nmap OS match, and the device_type of its fingerprint, which covers sysObjectIDs, OUI vendors, open ports and
+// hostnames. The confidences of the signals for a type add up as independent evidence
+// and the type with the highest total wins, unless it stays below minTypeConfidence.
+func Classify(device *models.Device) {
+	votes := typeVotes(device)
+
+	device.DeviceType, device.TypeConfidence, device.TypeEvidence = "", 0, nil
+	if len(votes) == 0 {
+		return
+	}
+
+	// Combined confidence per type: the chance that not every signal is wrong
+	doubt := make(map[string]float64)
+	for _, v := range votes {
+		if _, ok := doubt[v.deviceType]; !ok {
+			doubt[v.deviceType] = 1
+		}
+		doubt[v.deviceType] *= 1 - float64(v.confidence)/100
+	}
+
+	best, bestDoubt := "", 1.0
+	for _, deviceType := range deviceTypes {
+		if d, ok := doubt[deviceType]; ok && d < bestDoubt {
+			best, bestDoubt = deviceType, d
+		}
+	}
+	confidence := int(math.Round((1 - bestDoubt) * 100))
+	if best == "" || confidence < minTypeConfidence {
+		return
+	}
+
+	device.DeviceType = best
+	device.TypeConfidence = confidence
+	for _, v := range votes {
+		if v.deviceType == best {
+			device.TypeEvidence = append(device.TypeEvidence, v.evidence)
+		}
+	}
+}
+
+// typeVotes collects the signals of a device
+func typeVotes(device *models.Device) []vote {
+	var votes []vote
+
+	if fp := device.Fingerprint; fp != nil && fp.DeviceType != "" {
+		for _, m := range fp.Matches {
+			if contains(m.Fields, "device_type") {
+				votes = append(votes, vote{fp.DeviceType, m.Confidence, "fingerprint rule " + m.Rule})
+				break
+			}
+		}
+	}
+
+	hostServices := device.Services&serviceApplications != 0 && device.Services&serviceDatalink == 0
+	for _, capability := range device.Capabilities {
+		t, ok := capabilityTypes[capability]
+		if !ok {
+			continue
+		}
+		confidence := t.confidence
+		// Hosts forwarding IP, e.g. for containers, are rarely routers
+		if capability == "router" && !device.Placeholder && hostServices {
+			confidence /= 2
+		}
+		source := "snmp"
+		if device.Placeholder {
+			source = strings.ToLower(device.ScanMethod)
+		}
+		votes = append(votes, vote{t.deviceType, confidence, fmt.Sprintf("%s capability %s", source, capability)})
+	}
+
+	// nmap OS classes name the device type too; an OS match is not proof of the hardware
+	if len(device.OSMatches) > 0 {
+		best := device.OSMatches[0]
+		if deviceType, ok := nmapTypes[best.Type]; ok {
+			votes = append(votes, vote{deviceType, best.Accuracy * 3 / 4, fmt.Sprintf("nmap osclass %s (%s)", best.Type, best.Name)})
+		}
+	}
+
+	// sysServices without the application layer describe network equipment
+	if services := device.Services; services != 0 && services&serviceApplications == 0 {
+		evidence := fmt.Sprintf("sys_services %d", services)
+		switch {
+		case services&serviceDatalink != 0 && services&serviceInternet != 0:
+			votes = append(votes, vote{"switch", 40, evidence}, vote{"router", 30, evidence})
+		case services&serviceDatalink != 0:
+			votes = append(votes, vote{"switch", 50, evidence})
+		case services&serviceInternet != 0:
+			votes = append(votes, vote{"router", 50, evidence})
+		}
+	}
+
+	return votes
+}
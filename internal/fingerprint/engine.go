@@ -0,0 +1,255 @@
+package fingerprint
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"network-discovery/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Engine identifies the vendor, model, OS, version and type of devices from the rules
+// of a YAML file, the built-in sysObjectID tables and config.yaml's vendor_patterns.
+// It is safe for concurrent use; Reload swaps the rules while scans are running.
+type Engine struct {
+	path     string
+	patterns map[string][]string
+	logger   *logrus.Logger
+
+	mu     sync.RWMutex
+	rules  []*rule // Highest priority first
+	loaded time.Time
+}
+
+// NewEngine creates an engine with the rules of path (DefaultRulesPath when empty) and
+// the vendor_patterns of config.yaml. When the file cannot be loaded, the engine starts
+// with the sysObjectID tables and vendor_patterns only.
+func NewEngine(path string, vendorPatterns map[string][]string, logger *logrus.Logger) *Engine {
+	if path == "" {
+		path = DefaultRulesPath
+	}
+
+	e := &Engine{
+		path:     path,
+		patterns: vendorPatterns,
+		logger:   logger,
+	}
+	if err := e.Load(); err != nil {
+		logger.Errorf("Failed to load fingerprint rules: %v", err)
+		e.install(nil)
+	}
+	return e
+}
+
+// Load reads the rule file. The rules in use are only replaced when every rule of the
+// file is valid.
+func (e *Engine) Load() error {
+	e.logger.Infof("Loading fingerprint rules from: %s", e.path)
+
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return fmt.Errorf("failed to read fingerprint rules: %v", err)
+	}
+
+	var file RuleFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse fingerprint rules: %v", err)
+	}
+	rules, err := compileRules(file.Rules)
+	if err != nil {
+		return fmt.Errorf("invalid fingerprint rules: %v", err)
+	}
+
+	e.install(rules)
+	e.logger.Infof("Loaded %d fingerprint rules", len(rules))
+	return nil
+}
+
+// Reload re-reads the rule file; the previous rules stay in use when it is invalid
+func (e *Engine) Reload() error {
+	e.logger.Info("Reloading fingerprint rules...")
+	return e.Load()
+}
+
+// install puts the rules of the file, plus the vendor_patterns rules, in use
+func (e *Engine) install(rules []*rule) {
+	rules = append(rules, patternRules(e.patterns, rules)...)
+	sort.SliceStable(rules, func(a, b int) bool {
+		return rankBefore(rules[a].Priority, rules[a].Confidence, rules[a].order, rules[b].Priority, rules[b].Confidence, rules[b].order)
+	})
+
+	e.mu.Lock()
+	e.rules = rules
+	e.loaded = time.Now()
+	e.mu.Unlock()
+}
+
+// Path returns the rule file
+func (e *Engine) Path() string {
+	return e.path
+}
+
+// Rules returns the rules in use, highest priority first, including the rules made
+// from vendor_patterns
+func (e *Engine) Rules() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	rules := make([]Rule, len(e.rules))
+	for i, r := range e.rules {
+		rules[i] = r.Rule
+	}
+	return rules
+}
+
+// Info describes the rule set in use
+func (e *Engine) Info() map[string]interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return map[string]interface{}{
+		"rules_path":         e.path,
+		"total_rules":        len(e.rules),
+		"object_id_priority": ObjectIDPriority,
+		"last_loaded":        e.loaded.Format(time.RFC3339),
+	}
+}
+
+// candidate is a matching rule, or the sysObjectID tables, with the fields it would set
+type candidate struct {
+	match models.FingerprintMatch
+	set   Result
+	order int
+}
+
+// Evaluate runs the rules against a device. macVendor is the OUI vendor of its MAC
+// address, if known. It returns nil when nothing matched.
+func (e *Engine) Evaluate(device *models.Device, macVendor string) *models.Fingerprint {
+	if e == nil || device == nil {
+		return nil
+	}
+
+	ev := &evidence{
+		sysDescr:  device.Description,
+		objectID:  device.ObjectID,
+		mac:       normalizeMAC(device.MACAddress),
+		macVendor: macVendor,
+		hostname:  device.Hostname,
+		ports:     device.OpenPorts,
+	}
+
+	var candidates []candidate
+	if ev.objectID != "" {
+		if t := resolveObjectID(ev.objectID); t != (objectIDModel{}) {
+			candidates = append(candidates, candidate{
+				match: models.FingerprintMatch{
+					Rule:       "sys_object_id",
+					Priority:   ObjectIDPriority,
+					Confidence: objectIDConfidence,
+					Evidence:   []string{"sys_object_id " + ev.objectID},
+				},
+				set:   Result{Vendor: t.Vendor, Model: t.Model, OS: t.OS, DeviceType: t.DeviceType},
+				order: -1,
+			})
+		}
+	}
+
+	e.mu.RLock()
+	for _, r := range e.rules {
+		groups, found, ok := r.match(ev)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			match: models.FingerprintMatch{
+				Rule:       r.Name,
+				Priority:   r.Priority,
+				Confidence: r.Confidence,
+				Evidence:   found,
+			},
+			set: Result{
+				Vendor:     expand(r.Set.Vendor, groups),
+				Model:      expand(r.Set.Model, groups),
+				OS:         expand(r.Set.OS, groups),
+				Version:    expand(r.Set.Version, groups),
+				DeviceType: expand(r.Set.DeviceType, groups),
+			},
+			order: r.order,
+		})
+	}
+	e.mu.RUnlock()
+
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		ca, cb := candidates[a], candidates[b]
+		return rankBefore(ca.match.Priority, ca.match.Confidence, ca.order, cb.match.Priority, cb.match.Confidence, cb.order)
+	})
+
+	result := &models.Fingerprint{}
+	for i := range candidates {
+		c := &candidates[i]
+		for _, field := range []struct {
+			name  string
+			value string
+			dst   *string
+		}{
+			{"vendor", c.set.Vendor, &result.Vendor},
+			{"model", c.set.Model, &result.Model},
+			{"os", c.set.OS, &result.OS},
+			{"version", c.set.Version, &result.Version},
+			{"device_type", c.set.DeviceType, &result.DeviceType},
+		} {
+			if field.value != "" && *field.dst == "" {
+				*field.dst = field.value
+				c.match.Fields = append(c.match.Fields, field.name)
+			}
+		}
+		if len(c.match.Fields) > 0 && c.match.Confidence > result.Confidence {
+			result.Confidence = c.match.Confidence
+		}
+		result.Matches = append(result.Matches, c.match)
+	}
+	return result
+}
+
+// Apply fingerprints a device and sets its Vendor, Model, OS and Version from the
+// result. Fields no rule decides keep their value, e.g. the OUI vendor.
+func (e *Engine) Apply(device *models.Device, macVendor string) {
+	fp := e.Evaluate(device, macVendor)
+	if fp == nil {
+		return
+	}
+
+	device.Fingerprint = fp
+	for _, field := range []struct {
+		value string
+		dst   *string
+	}{
+		{fp.Vendor, &device.Vendor},
+		{fp.Model, &device.Model},
+		{fp.OS, &device.OS},
+		{fp.Version, &device.Version},
+	} {
+		if field.value != "" {
+			*field.dst = field.value
+		}
+	}
+}
+
+// rankBefore orders rules by priority, then confidence, then position in the file
+func rankBefore(priorityA, confidenceA, orderA, priorityB, confidenceB, orderB int) bool {
+	if priorityA != priorityB {
+		return priorityA > priorityB
+	}
+	if confidenceA != confidenceB {
+		return confidenceA > confidenceB
+	}
+	return orderA < orderB
+}
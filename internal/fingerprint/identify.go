@@ -0,0 +1,116 @@
+package fingerprint
+
+import (
+	"sort"
+	"strings"
+
+	"network-discovery/internal/models"
+)
+
+// descrVendors map lower-case sysDescr words to vendor names, for agents whose
+// sysObjectID enterprise is not known
+var descrVendors = map[string]string{
+	"cisco":     "Cisco",
+	"juniper":   "Juniper",
+	"huawei":    "Huawei",
+	"hp":        "HP",
+	"dell":      "Dell",
+	"netgear":   "Netgear",
+	"d-link":    "D-Link",
+	"tp-link":   "TP-Link",
+	"mikrotik":  "MikroTik",
+	"ubiquiti":  "Ubiquiti",
+	"fortinet":  "Fortinet",
+	"palo alto": "Palo Alto",
+	"microsoft": "Microsoft",
+	"windows":   "Microsoft",
+	"linux":     "Linux",
+	"ubuntu":    "Ubuntu",
+	"centos":    "CentOS",
+	"redhat":    "Red Hat",
+}
+
+// descrPatterns are the keys of descrVendors, longest first so specific words win over
+// generic ones (e.g. "ubuntu" over "linux")
+var descrPatterns = func() []string {
+	list := make([]string, 0, len(descrVendors))
+	for pattern := range descrVendors {
+		list = append(list, pattern)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if len(list[a]) != len(list[b]) {
+			return len(list[a]) > len(list[b])
+		}
+		return list[a] < list[b]
+	})
+	return list
+}()
+
+// Identify sets the vendor and model of an SNMP device from its sysObjectID, the vendor
+// from well-known sysDescr words when the enterprise is not known (else "Unknown"), and
+// the version from the sysDescr line naming it. It runs whether or not fingerprinting
+// is enabled; the rule engine overrides its result. Devices without SNMP data are left
+// alone.
+func Identify(device *models.Device) {
+	if device == nil || (device.ObjectID == "" && device.Description == "") {
+		return
+	}
+
+	vendor := ""
+	if device.ObjectID != "" {
+		t := resolveObjectID(device.ObjectID)
+		vendor = t.Vendor
+		if t.Model != "" {
+			device.Model = t.Model
+		}
+	}
+	if vendor == "" {
+		desc := strings.ToLower(device.Description)
+		for _, pattern := range descrPatterns {
+			if containsWord(desc, pattern) {
+				vendor = descrVendors[pattern]
+				break
+			}
+		}
+	}
+	switch {
+	case vendor != "":
+		device.Vendor = vendor
+	case device.Vendor == "":
+		device.Vendor = "Unknown"
+	}
+
+	for _, line := range strings.Split(device.Description, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.Contains(strings.ToLower(line), "version") {
+			device.Version = line
+			break
+		}
+	}
+}
+
+// containsWord reports whether word occurs in s at the start of a word. Words of up to
+// three letters must also end there or be followed by a digit, so "hp" matches
+// "hp procurve" and "hp2530" but not "sphere".
+func containsWord(s, word string) bool {
+	for offset := 0; ; {
+		i := strings.Index(s[offset:], word)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(word)
+		offset = start + 1
+
+		if start > 0 && isWordChar(s[start-1]) {
+			continue
+		}
+		if len(word) <= 3 && end < len(s) && s[end] >= 'a' && s[end] <= 'z' {
+			continue
+		}
+		return true
+	}
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
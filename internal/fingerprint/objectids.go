@@ -0,0 +1,116 @@
+package fingerprint
+
+import (
+	"strconv"
+	"strings"
+)
+
+// OIDEnterprises is the arc of the IANA private enterprise numbers; a sysObjectID
+// below it names the vendor of the agent (1.3.6.1.4.1.<enterprise>...)
+const OIDEnterprises = "1.3.6.1.4.1"
+
+// enterpriseVendors maps IANA private enterprise numbers of network equipment, server
+// and OS vendors to vendor names, spelled as in the bundled fingerprint rules. Generic
+// agents such as Net-SNMP are left out: their vendor is the operating system.
+var enterpriseVendors = map[int]string{
+	2:     "IBM",
+	9:     "Cisco",
+	11:    "HP",
+	42:    "Sun",
+	43:    "3Com",
+	171:   "D-Link",
+	207:   "Allied Telesis",
+	311:   "Microsoft",
+	318:   "APC",
+	674:   "Dell",
+	789:   "NetApp",
+	890:   "ZyXEL",
+	1916:  "Extreme Networks",
+	1991:  "Brocade",
+	2011:  "Huawei",
+	2620:  "Check Point",
+	2636:  "Juniper",
+	3097:  "Watchguard",
+	3375:  "F5",
+	4526:  "Netgear",
+	6027:  "Dell",
+	6486:  "Alcatel-Lucent",
+	6574:  "Synology",
+	6876:  "VMware",
+	8741:  "SonicWall",
+	11863: "TP-Link",
+	12356: "Fortinet",
+	14823: "Aruba",
+	14988: "MikroTik",
+	24681: "QNAP",
+	25461: "Palo Alto",
+	25506: "H3C",
+	29671: "Meraki",
+	30065: "Arista",
+	41112: "Ubiquiti",
+}
+
+// objectIDModel is what a product OID tells beyond the enterprise: the model, OS and
+// device type, and for generic agents such as Net-SNMP the vendor of the operating
+// system instead
+type objectIDModel struct {
+	Vendor     string
+	Model      string
+	OS         string
+	DeviceType string
+}
+
+// objectIDModels maps product OIDs (the sysObjectID itself or a prefix of it) to models
+var objectIDModels = map[string]objectIDModel{
+	// Cisco ciscoProducts (CISCO-PRODUCTS-MIB)
+	"1.3.6.1.4.1.9.1.516":  {Model: "catalyst37xxStack", DeviceType: "switch"},
+	"1.3.6.1.4.1.9.1.576":  {Model: "cisco2811", DeviceType: "router"},
+	"1.3.6.1.4.1.9.1.577":  {Model: "cisco2821", DeviceType: "router"},
+	"1.3.6.1.4.1.9.1.578":  {Model: "cisco2851", DeviceType: "router"},
+	"1.3.6.1.4.1.9.1.620":  {Model: "cisco1841", DeviceType: "router"},
+	"1.3.6.1.4.1.9.1.669":  {Model: "ciscoASA5510", OS: "ASA", DeviceType: "firewall"},
+	"1.3.6.1.4.1.9.1.670":  {Model: "ciscoASA5520", OS: "ASA", DeviceType: "firewall"},
+	"1.3.6.1.4.1.9.1.1208": {Model: "cat29xxStack", DeviceType: "switch"},
+	"1.3.6.1.4.1.9.1.1745": {Model: "cat38xxstack", DeviceType: "switch"},
+
+	// Microsoft Windows agents
+	"1.3.6.1.4.1.311.1.1.3.1.1": {Model: "Windows Workstation", OS: "Windows", DeviceType: "workstation"},
+	"1.3.6.1.4.1.311.1.1.3.1.2": {Model: "Windows Server", OS: "Windows", DeviceType: "server"},
+	"1.3.6.1.4.1.311.1.1.3.1.3": {Model: "Windows Domain Controller", OS: "Windows", DeviceType: "server"},
+
+	// Net-SNMP agents (NET-SNMP-TC netSnmpAgentOIDs) report the operating system
+	"1.3.6.1.4.1.8072.3.2.3":  {Vendor: "Solaris", OS: "Solaris"},
+	"1.3.6.1.4.1.8072.3.2.7":  {Vendor: "NetBSD", OS: "NetBSD"},
+	"1.3.6.1.4.1.8072.3.2.8":  {Vendor: "FreeBSD", OS: "FreeBSD"},
+	"1.3.6.1.4.1.8072.3.2.10": {Vendor: "Linux", OS: "Linux"},
+	"1.3.6.1.4.1.8072.3.2.12": {Vendor: "OpenBSD", OS: "OpenBSD"},
+	"1.3.6.1.4.1.8072.3.2.13": {Vendor: "Microsoft", OS: "Windows"},
+	"1.3.6.1.4.1.8072.3.2.16": {Vendor: "Apple", OS: "macOS"},
+}
+
+// resolveObjectID returns what a sysObjectID identifies. The longest matching product
+// OID gives the model, OS and device type; the enterprise number gives the vendor.
+func resolveObjectID(objectID string) objectIDModel {
+	objectID = strings.TrimPrefix(objectID, ".")
+
+	var result objectIDModel
+	for prefix := objectID; prefix != ""; {
+		if entry, ok := objectIDModels[prefix]; ok {
+			result = entry
+			break
+		}
+		i := strings.LastIndex(prefix, ".")
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+
+	if rest, ok := strings.CutPrefix(objectID, OIDEnterprises+"."); ok && result.Vendor == "" {
+		number, _, _ := strings.Cut(rest, ".")
+		if enterprise, err := strconv.Atoi(number); err == nil {
+			result.Vendor = enterpriseVendors[enterprise]
+		}
+	}
+	return result
+}
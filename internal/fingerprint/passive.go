@@ -0,0 +1,192 @@
+package fingerprint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"network-discovery/internal/models"
+)
+
+// osSignature is how the TCP stack of an operating system answers a connect. Window is
+// "*" for any window, a list of sizes ("8192|65535"), or "mss" for a multiple of the MSS.
+type osSignature struct {
+	Name        string
+	OS          string
+	TTL         int      // Initial TTL
+	Options     []string // TCP option layouts of the SYN-ACK, see models.TCPSignature
+	Window      string
+	WindowScale int // -1 for any
+	Confidence  int // When every field matches
+}
+
+// osSignatures are the bundled SYN-ACK signatures, most specific first
+var osSignatures = []osSignature{
+	{Name: "Linux 2.6+", OS: "Linux", TTL: 64, Options: []string{"mss,sok,ts,nop,ws", "mss,nop,nop,sok,nop,ws"}, Window: "mss", WindowScale: -1, Confidence: 85},
+	{Name: "Linux 2.4", OS: "Linux", TTL: 64, Options: []string{"mss,sok,ts", "mss,nop,nop,sok"}, Window: "mss", WindowScale: -1, Confidence: 70},
+	{Name: "FreeBSD 9+", OS: "FreeBSD", TTL: 64, Options: []string{"mss,nop,ws,sok,ts"}, Window: "65535", WindowScale: 6, Confidence: 80},
+	{Name: "macOS / iOS", OS: "macOS", TTL: 64, Options: []string{"mss,nop,ws,nop,nop,ts,sok,eol", "mss,nop,ws,nop,nop,ts,sok"}, Window: "65535", WindowScale: -1, Confidence: 80},
+	{Name: "OpenBSD", OS: "OpenBSD", TTL: 64, Options: []string{"mss,nop,nop,sok,nop,ws,nop,nop,ts"}, Window: "16384", WindowScale: -1, Confidence: 80},
+	{Name: "Windows 7+ / Server 2008+", OS: "Windows", TTL: 128, Options: []string{"mss,nop,ws,nop,nop,sok", "mss,nop,ws,sok,ts"}, Window: "8192|64240|65535", WindowScale: 8, Confidence: 85},
+	{Name: "Windows XP / Server 2003", OS: "Windows", TTL: 128, Options: []string{"mss,nop,nop,sok", "mss,nop,ws,nop,nop,sok"}, Window: "16384|64240|65535", WindowScale: 0, Confidence: 70},
+	{Name: "Cisco IOS", OS: "IOS", TTL: 255, Options: []string{"mss"}, Window: "4128|16384", WindowScale: -1, Confidence: 75},
+	{Name: "Embedded TCP/IP stack", OS: "Embedded", TTL: 64, Options: []string{"mss"}, Window: "*", WindowScale: -1, Confidence: 50},
+	{Name: "Embedded TCP/IP stack", OS: "Embedded", TTL: 255, Options: []string{"mss"}, Window: "*", WindowScale: -1, Confidence: 45},
+}
+
+// ttlGuesses are the operating systems an initial TTL alone suggests
+var ttlGuesses = map[int]osSignature{
+	64:  {Name: "TTL 64 (Linux, BSD, macOS or embedded)", OS: "Linux/Unix", Confidence: 30},
+	128: {Name: "TTL 128 (Windows)", OS: "Windows", Confidence: 50},
+	255: {Name: "TTL 255 (network equipment)", OS: "Network OS", Confidence: 30},
+}
+
+// GuessOS guesses the operating system of a device from the TTL of its ICMP replies
+// and the SYN-ACK of its first open TCP port with a captured one, against the bundled
+// signatures. nmap's most accurate OS match replaces that guess when its accuracy is at
+// least as high. It sets OSGuess, and OS when the fingerprint rules did not decide it.
+func GuessOS(device *models.Device) {
+	device.OSGuess = passiveGuess(device)
+	if guess := nmapGuess(device); guess != nil && (device.OSGuess == nil || guess.Confidence >= device.OSGuess.Confidence) {
+		device.OSGuess = guess
+	}
+	if device.OSGuess != nil && device.OS == "" {
+		device.OS = device.OSGuess.OS
+	}
+}
+
+// passiveGuess matches the TTL and SYN-ACK of a device against the bundled signatures
+func passiveGuess(device *models.Device) *models.OSGuess {
+	var (
+		port   models.PortInfo
+		synACK *models.TCPSignature
+	)
+	for _, p := range device.OpenPorts {
+		if p.SYNACK != nil {
+			port, synACK = p, p.SYNACK
+			break
+		}
+	}
+
+	var evidence []string
+	ttl := 0
+	if device.TTL > 0 {
+		ttl = device.TTL
+		evidence = append(evidence, describeTTL("icmp", ttl))
+	}
+	if synACK != nil {
+		if ttl == 0 {
+			ttl = synACK.TTL
+			evidence = append(evidence, describeTTL("syn_ack", ttl))
+		}
+		evidence = append(evidence, describeSYNACK(port, synACK))
+	}
+	if ttl == 0 {
+		return nil
+	}
+
+	var guess *models.OSGuess
+	if synACK != nil {
+		best := 0
+		for _, sig := range osSignatures {
+			if confidence := sig.match(synACK); confidence > best {
+				best = confidence
+				guess = &models.OSGuess{OS: sig.OS, Signature: sig.Name, Confidence: confidence}
+			}
+		}
+	}
+	if guess == nil {
+		sig, ok := ttlGuesses[initialTTL(ttl)]
+		if !ok {
+			return nil
+		}
+		guess = &models.OSGuess{OS: sig.OS, Signature: sig.Name, Confidence: sig.Confidence}
+	}
+
+	guess.Evidence = evidence
+	return guess
+}
+
+// nmapGuess turns the most accurate nmap OS match of a device into a guess
+func nmapGuess(device *models.Device) *models.OSGuess {
+	if len(device.OSMatches) == 0 {
+		return nil
+	}
+	best := device.OSMatches[0]
+	for _, m := range device.OSMatches[1:] {
+		if m.Accuracy > best.Accuracy {
+			best = m
+		}
+	}
+
+	name := best.Family
+	if name == "" {
+		name = best.Name
+	}
+	evidence := []string{fmt.Sprintf("nmap osmatch %q accuracy %d", best.Name, best.Accuracy)}
+	if class := strings.Join(strings.Fields(best.Vendor+" "+best.Family+" "+best.Generation+" "+best.Type), " "); class != "" {
+		evidence = append(evidence, "nmap osclass "+class)
+	}
+	return &models.OSGuess{OS: name, Signature: "nmap " + best.Name, Confidence: best.Accuracy, Evidence: evidence}
+}
+
+// match returns the confidence of the signature for a SYN-ACK, or 0 when its initial
+// TTL or option layout differ. A different window or window scale lowers the confidence.
+func (sig osSignature) match(synACK *models.TCPSignature) int {
+	if initialTTL(synACK.TTL) != sig.TTL || !contains(sig.Options, synACK.Options) {
+		return 0
+	}
+	confidence := sig.Confidence
+	if !sig.windowMatches(synACK) {
+		confidence -= 25
+	}
+	if sig.WindowScale >= 0 && strings.Contains(synACK.Options, "ws") && synACK.WindowScale != sig.WindowScale {
+		confidence -= 10
+	}
+	return confidence
+}
+
+func (sig osSignature) windowMatches(synACK *models.TCPSignature) bool {
+	switch sig.Window {
+	case "*":
+		return true
+	case "mss":
+		// With timestamps, stacks size the window in segments of MSS - 12
+		for _, segment := range []int{synACK.MSS, synACK.MSS - 12} {
+			if segment > 0 && synACK.Window%segment == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	return contains(strings.Split(sig.Window, "|"), strconv.Itoa(synACK.Window))
+}
+
+// initialTTL is the TTL a packet most likely started with: the next of the usual 32,
+// 64, 128 and 255
+func initialTTL(ttl int) int {
+	for _, initial := range []int{32, 64, 128} {
+		if ttl <= initial {
+			return initial
+		}
+	}
+	return 255
+}
+
+// describeSYNACK lists the fields of a SYN-ACK the signatures compare
+func describeSYNACK(port models.PortInfo, synACK *models.TCPSignature) string {
+	description := fmt.Sprintf("syn_ack %d/%s window %d options %s", port.Port, port.Protocol, synACK.Window, synACK.Options)
+	if synACK.MSS > 0 {
+		description += fmt.Sprintf(" mss %d", synACK.MSS)
+	}
+	if strings.Contains(synACK.Options, "ws") {
+		description += fmt.Sprintf(" ws %d", synACK.WindowScale)
+	}
+	return description
+}
+
+// describeTTL explains a TTL with its initial value and the hops it took
+func describeTTL(source string, ttl int) string {
+	initial := initialTTL(ttl)
+	return fmt.Sprintf("%s ttl %d (initial %d, %d hops)", source, ttl, initial, initial-ttl)
+}
@@ -0,0 +1,359 @@
+package fingerprint
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"network-discovery/internal/models"
+)
+
+// DefaultRulesPath is the fingerprint rule file used when no path is configured
+const DefaultRulesPath = "configs/fingerprints.yaml"
+
+// Rank of the built-in evidence among the rules of the file. Rules with a higher
+// priority override what the sysObjectID tables say; config.yaml's vendor_patterns
+// only decide fields no more specific rule sets.
+const (
+	ObjectIDPriority      = 50
+	VendorPatternPriority = 20
+
+	objectIDConfidence      = 95
+	vendorPatternConfidence = 60
+	defaultConfidence       = 50
+)
+
+// Device types a rule may set
+var deviceTypes = []string{"router", "switch", "firewall", "access_point", "printer", "phone", "camera", "server", "workstation"}
+
+// RuleFile is the YAML file of fingerprint rules
+type RuleFile struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule sets fingerprint fields of the devices that match all of its conditions
+type Rule struct {
+	Name        string     `yaml:"name" json:"name"`
+	Description string     `yaml:"description,omitempty" json:"description,omitempty"`
+	Priority    int        `yaml:"priority" json:"priority"`     // Higher priorities decide a field first
+	Confidence  int        `yaml:"confidence" json:"confidence"` // 0-100, default 50
+	Match       Conditions `yaml:"match" json:"match"`
+	Set         Result     `yaml:"set" json:"set"`
+}
+
+// Conditions of a rule. Regexes use Go syntax, e.g. "(?i)" for case-insensitive matching.
+type Conditions struct {
+	SysDescr    string   `yaml:"sys_descr,omitempty" json:"sys_descr,omitempty"`         // Regex on the SNMP sysDescr
+	SysObjectID []string `yaml:"sys_object_id,omitempty" json:"sys_object_id,omitempty"` // sysObjectID prefixes, any of them
+	OUI         []string `yaml:"oui,omitempty" json:"oui,omitempty"`                     // MAC address prefixes, any of them
+	MACVendor   string   `yaml:"mac_vendor,omitempty" json:"mac_vendor,omitempty"`       // Regex on the OUI vendor of the MAC
+	OpenPorts   []int    `yaml:"open_ports,omitempty" json:"open_ports,omitempty"`       // Any of these ports open
+	Banner      string   `yaml:"banner,omitempty" json:"banner,omitempty"`               // Regex on the banner of any open port
+	Hostname    string   `yaml:"hostname,omitempty" json:"hostname,omitempty"`           // Regex on the hostname
+}
+
+// Result is what a rule sets. Values may refer to named groups of the rule's regexes
+// as ${name}, e.g. version: "${version}" with sys_descr: "Version (?P<version>\\S+)".
+type Result struct {
+	Vendor     string `yaml:"vendor,omitempty" json:"vendor,omitempty"`
+	Model      string `yaml:"model,omitempty" json:"model,omitempty"`
+	OS         string `yaml:"os,omitempty" json:"os,omitempty"`
+	Version    string `yaml:"version,omitempty" json:"version,omitempty"`
+	DeviceType string `yaml:"device_type,omitempty" json:"device_type,omitempty"`
+}
+
+// rule is a Rule with its regexes compiled
+type rule struct {
+	Rule
+	order     int
+	sysDescr  *regexp.Regexp
+	macVendor *regexp.Regexp
+	banner    *regexp.Regexp
+	hostname  *regexp.Regexp
+	oui       []string // Upper-case hex digits without separators
+}
+
+// compileRules validates and compiles rules, reporting every invalid rule
+func compileRules(rules []Rule) ([]*rule, error) {
+	var (
+		compiled []*rule
+		problems []string
+	)
+	names := make(map[string]bool)
+	for i, r := range rules {
+		c, err := compileRule(r, i)
+		if err == nil && names[r.Name] {
+			err = errors.New("duplicate rule name")
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("rule %d (%s): %v", i+1, r.Name, err))
+			continue
+		}
+		names[r.Name] = true
+		compiled = append(compiled, c)
+	}
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	return compiled, nil
+}
+
+func compileRule(r Rule, order int) (*rule, error) {
+	if r.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if r.Confidence == 0 {
+		r.Confidence = defaultConfidence
+	}
+	if r.Confidence < 0 || r.Confidence > 100 {
+		return nil, fmt.Errorf("confidence %d is not between 0 and 100", r.Confidence)
+	}
+	m := r.Match
+	if m.SysDescr == "" && len(m.SysObjectID) == 0 && len(m.OUI) == 0 && m.MACVendor == "" && len(m.OpenPorts) == 0 && m.Banner == "" && m.Hostname == "" {
+		return nil, errors.New("match needs at least one condition")
+	}
+	if r.Set == (Result{}) {
+		return nil, errors.New("set needs at least one field")
+	}
+	if r.Set.DeviceType != "" && !strings.Contains(r.Set.DeviceType, "${") && !contains(deviceTypes, r.Set.DeviceType) {
+		return nil, fmt.Errorf("device_type %q is not one of %s", r.Set.DeviceType, strings.Join(deviceTypes, ", "))
+	}
+
+	c := &rule{Rule: r, order: order}
+	var err error
+	for _, re := range []struct {
+		field   string
+		pattern string
+		target  **regexp.Regexp
+	}{
+		{"sys_descr", m.SysDescr, &c.sysDescr},
+		{"mac_vendor", m.MACVendor, &c.macVendor},
+		{"banner", m.Banner, &c.banner},
+		{"hostname", m.Hostname, &c.hostname},
+	} {
+		if re.pattern == "" {
+			continue
+		}
+		if *re.target, err = regexp.Compile(re.pattern); err != nil {
+			return nil, fmt.Errorf("invalid %s regex: %v", re.field, err)
+		}
+	}
+	for _, prefix := range m.OUI {
+		hex := normalizeMAC(prefix)
+		if hex == "" {
+			return nil, fmt.Errorf("invalid oui %q", prefix)
+		}
+		c.oui = append(c.oui, hex)
+	}
+	c.Match.SysObjectID = nil
+	for _, prefix := range m.SysObjectID {
+		c.Match.SysObjectID = append(c.Match.SysObjectID, strings.TrimPrefix(prefix, "."))
+	}
+	return c, nil
+}
+
+// patternRules turns config.yaml's vendor_patterns into rules matching the patterns as
+// words of the sysDescr. Patterns of up to three letters must also end the word or be
+// followed by a digit, so "hp" does not match "sHPere" and "ex" matches "EX4300" but
+// not "Extreme". Vendor keys take the spelling of a vendor the rules already know.
+func patternRules(patterns map[string][]string, known []*rule) []*rule {
+	vendors := make([]string, 0, len(patterns))
+	for vendor := range patterns {
+		vendors = append(vendors, vendor)
+	}
+	sort.Strings(vendors)
+
+	var rules []*rule
+	for _, vendor := range vendors {
+		var words []string
+		for _, pattern := range patterns[vendor] {
+			pattern = strings.ToLower(strings.TrimSpace(pattern))
+			if pattern == "" {
+				continue
+			}
+			word := regexp.QuoteMeta(pattern)
+			if last := pattern[len(pattern)-1]; len(pattern) <= 3 && last >= 'a' && last <= 'z' {
+				word += "(?:$|[^a-z])"
+			}
+			words = append(words, word)
+		}
+		if len(words) == 0 {
+			continue
+		}
+		r := Rule{
+			Name:       "vendor_patterns." + vendor,
+			Priority:   VendorPatternPriority,
+			Confidence: vendorPatternConfidence,
+			Match:      Conditions{SysDescr: "(?i)(?:^|[^a-z0-9])(?:" + strings.Join(words, "|") + ")"},
+			Set:        Result{Vendor: displayName(vendor, known)},
+		}
+		if c, err := compileRule(r, len(known)+len(rules)); err == nil {
+			rules = append(rules, c)
+		}
+	}
+	return rules
+}
+
+// displayName spells a vendor_patterns key like a vendor of the rules (e.g. "mikrotik" -> "MikroTik")
+func displayName(vendor string, known []*rule) string {
+	for _, r := range known {
+		if strings.EqualFold(r.Set.Vendor, vendor) {
+			return r.Set.Vendor
+		}
+	}
+	if vendor == "" {
+		return vendor
+	}
+	return strings.ToUpper(vendor[:1]) + vendor[1:]
+}
+
+// evidence is what a device offers to the rules
+type evidence struct {
+	sysDescr  string
+	objectID  string
+	mac       string // Upper-case hex digits without separators
+	macVendor string
+	hostname  string
+	ports     []models.PortInfo
+}
+
+// match checks the rule's conditions and returns the named groups its regexes captured
+// and a description of each condition that held
+func (r *rule) match(e *evidence) (map[string]string, []string, bool) {
+	groups := make(map[string]string)
+	var found []string
+
+	matchRegex := func(re *regexp.Regexp, field, value string) bool {
+		if re == nil {
+			return true
+		}
+		m := re.FindStringSubmatchIndex(value)
+		if m == nil {
+			return false
+		}
+		for i, name := range re.SubexpNames() {
+			if name != "" && m[2*i] >= 0 {
+				groups[name] = value[m[2*i]:m[2*i+1]]
+			}
+		}
+		found = append(found, fmt.Sprintf("%s %s", field, quote(value[m[0]:m[1]])))
+		return true
+	}
+
+	if !matchRegex(r.sysDescr, "sys_descr", e.sysDescr) ||
+		!matchRegex(r.macVendor, "mac_vendor", e.macVendor) ||
+		!matchRegex(r.hostname, "hostname", e.hostname) {
+		return nil, nil, false
+	}
+
+	if len(r.Match.SysObjectID) > 0 {
+		ok := false
+		for _, prefix := range r.Match.SysObjectID {
+			if e.objectID == prefix || strings.HasPrefix(e.objectID, strings.TrimSuffix(prefix, ".")+".") {
+				found = append(found, "sys_object_id "+e.objectID)
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return nil, nil, false
+		}
+	}
+
+	if len(r.oui) > 0 {
+		ok := false
+		for _, prefix := range r.oui {
+			if e.mac != "" && strings.HasPrefix(e.mac, prefix) {
+				found = append(found, "oui "+prefix)
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return nil, nil, false
+		}
+	}
+
+	if len(r.Match.OpenPorts) > 0 {
+		ok := false
+		for _, p := range e.ports {
+			if p.State == "open" && containsInt(r.Match.OpenPorts, p.Port) {
+				found = append(found, fmt.Sprintf("open_port %d/%s", p.Port, p.Protocol))
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return nil, nil, false
+		}
+	}
+
+	if r.banner != nil {
+		ok := false
+		for _, p := range e.ports {
+			if p.Banner != "" && matchRegex(r.banner, fmt.Sprintf("banner %d/%s", p.Port, p.Protocol), p.Banner) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return nil, nil, false
+		}
+	}
+
+	return groups, found, true
+}
+
+var groupReference = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// expand replaces ${name} with the named group of the rule's regexes
+func expand(value string, groups map[string]string) string {
+	value = groupReference.ReplaceAllStringFunc(value, func(ref string) string {
+		return groups[ref[2:len(ref)-1]]
+	})
+	return strings.TrimSpace(value)
+}
+
+// normalizeMAC returns the hex digits of a MAC address or prefix in upper case, or ""
+// when it contains anything else
+func normalizeMAC(mac string) string {
+	hex := strings.ToUpper(strings.NewReplacer(":", "", "-", "", ".", "").Replace(strings.TrimSpace(mac)))
+	if hex == "" {
+		return ""
+	}
+	for _, ch := range hex {
+		if !strings.ContainsRune("0123456789ABCDEF", ch) {
+			return ""
+		}
+	}
+	return hex
+}
+
+// quote quotes matched text for the evidence, shortened to 60 characters
+func quote(s string) string {
+	if runes := []rune(s); len(runes) > 60 {
+		s = string(runes[:57]) + "..."
+	}
+	return strconv.Quote(s)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, value int) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,525 @@
+package inventory
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"network-discovery/internal/models"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultPath is where the inventory database is created when no path is configured
+const DefaultPath = "data/inventory.db"
+
+var (
+	bucketScans        = []byte("scans")        // scan id -> ScanRecord (with topology)
+	bucketDevices      = []byte("devices")      // device id -> InventoryDevice
+	bucketDeviceIndex  = []byte("device_index") // "mac:<MAC>" / "ip:<IP>" -> device id
+	bucketObservations = []byte("observations") // device id + "/" + scan key -> DeviceObservation
+)
+
+// ErrNotFound is returned when a scan or device does not exist in the inventory
+var ErrNotFound = errors.New("not found")
+
+// Store persists scans and the device inventory in an embedded bbolt file
+type Store struct {
+	db     *bolt.DB
+	path   string
+	logger *logrus.Logger
+
+	// Retention of scans and their observations; zero keeps everything
+	maxScans int
+	maxAge   time.Duration
+}
+
+// Open opens (or creates) the inventory database at path
+func Open(path string, logger *logrus.Logger) (*Store, error) {
+	if path == "" {
+		path = DefaultPath
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create inventory directory: %v", err)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inventory database %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketScans, bucketDevices, bucketDeviceIndex, bucketObservations} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize inventory database: %v", err)
+	}
+
+	logger.Infof("Opened device inventory at %s", path)
+
+	return &Store{db: db, path: path, logger: logger}, nil
+}
+
+// SetRetention limits the stored scans to the newest maxScans and to those younger than
+// maxAge; zero disables a limit. Older scans and their device observations are pruned
+// whenever a scan is recorded.
+func (s *Store) SetRetention(maxScans int, maxAge time.Duration) {
+	s.maxScans = maxScans
+	s.maxAge = maxAge
+}
+
+// Close closes the underlying database file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// RecordScan stores a scan result and upserts every device it contains, returning the new scan id
+func (s *Store) RecordScan(req *models.ScanRequest, topology *models.NetworkTopology) (string, error) {
+	var scanID string
+	var pruned int
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		scans := tx.Bucket(bucketScans)
+
+		seq, err := scans.NextSequence()
+		if err != nil {
+			return err
+		}
+		scanKey := itob(seq)
+		scanID = strconv.FormatUint(seq, 10)
+
+		record := models.ScanRecord{
+			ID:           scanID,
+			ScanType:     req.ScanType,
+			NetworkRange: req.NetworkRange,
+			ScanTime:     topology.ScanTime,
+			ScanDuration: topology.ScanDuration,
+			DeviceCount:  topology.TotalCount,
+			Cancelled:    topology.Cancelled,
+			Topology:     topology,
+		}
+		if err := putJSON(scans, scanKey, record); err != nil {
+			return err
+		}
+
+		for i := range topology.Devices {
+			if err := s.upsertDevice(tx, scanID, scanKey, topology.ScanTime, &topology.Devices[i]); err != nil {
+				return err
+			}
+		}
+
+		pruned, err = s.prune(tx, time.Now())
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to record scan: %v", err)
+	}
+
+	s.logger.Infof("Recorded scan %s (%s, %d devices) in inventory", scanID, req.NetworkRange, topology.TotalCount)
+	if pruned > 0 {
+		s.logger.Infof("Pruned %d scans beyond the inventory retention", pruned)
+	}
+	return scanID, nil
+}
+
+// prune deletes the scans beyond the retention limits, oldest first, together with the
+// device observations recorded by them, and returns how many scans were deleted. The
+// scan just recorded is always kept.
+func (s *Store) prune(tx *bolt.Tx, now time.Time) (int, error) {
+	if s.maxScans <= 0 && s.maxAge <= 0 {
+		return 0, nil
+	}
+	scans := tx.Bucket(bucketScans)
+
+	total := 0
+	c := scans.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		total++
+	}
+
+	var expired [][]byte
+	for k, v := c.First(); k != nil && total-len(expired) > 1; k, v = c.Next() {
+		if s.maxScans <= 0 || total-len(expired) <= s.maxScans {
+			// Within the count limit; scans are keyed in recording order, so the first
+			// scan young enough ends the pruning
+			var record struct {
+				ScanTime time.Time `json:"scan_time"`
+			}
+			if err := json.Unmarshal(v, &record); err != nil {
+				return 0, err
+			}
+			if s.maxAge <= 0 || now.Sub(record.ScanTime) <= s.maxAge {
+				break
+			}
+		}
+		expired = append(expired, append([]byte(nil), k...))
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	deleted := make(map[string]bool, len(expired))
+	for _, key := range expired {
+		if err := scans.Delete(key); err != nil {
+			return 0, err
+		}
+		deleted[string(key)] = true
+	}
+
+	// Observation keys end in the 8-byte key of their scan
+	observations := tx.Bucket(bucketObservations)
+	var stale [][]byte
+	err := observations.ForEach(func(k, _ []byte) error {
+		if len(k) > 8 && deleted[string(k[len(k)-8:])] {
+			stale = append(stale, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range stale {
+		if err := observations.Delete(key); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
+
+// upsertDevice merges one scanned device into the inventory and stores the observation
+func (s *Store) upsertDevice(tx *bolt.Tx, scanID string, scanKey []byte, seen time.Time, device *models.Device) error {
+	devices := tx.Bucket(bucketDevices)
+	index := tx.Bucket(bucketDeviceIndex)
+
+	mac := normalizeMAC(device.MACAddress)
+	if mac == "" && device.IP == "" {
+		// LLDP/CDP placeholders known only by name have nothing to be keyed by
+		return nil
+	}
+	id := resolveDeviceID(index, devices, mac, device.IP)
+
+	var inv models.InventoryDevice
+	if data := devices.Get([]byte(id)); data != nil {
+		if err := json.Unmarshal(data, &inv); err != nil {
+			return fmt.Errorf("corrupt inventory device %s: %v", id, err)
+		}
+	} else {
+		inv = models.InventoryDevice{ID: id, FirstSeen: seen}
+	}
+
+	if mac != "" {
+		inv.MACAddress = mac
+	}
+	inv.IP = device.IP
+	if device.Hostname != "" {
+		inv.Hostname = device.Hostname
+	}
+	if device.Vendor != "" && device.Vendor != "Unknown" {
+		inv.Vendor = device.Vendor
+	}
+	if seen.After(inv.LastSeen) {
+		inv.LastSeen = seen
+	}
+	inv.LastScanID = scanID
+	inv.ScanCount++
+	inv.Latest = *device
+	for _, ip := range device.IPs() {
+		inv.IPHistory = touchIPHistory(inv.IPHistory, ip, seen)
+	}
+
+	if err := putJSON(devices, []byte(id), inv); err != nil {
+		return err
+	}
+	if mac != "" {
+		if err := index.Put([]byte("mac:"+mac), []byte(id)); err != nil {
+			return err
+		}
+	}
+	// Interface MACs find the device when another of its interfaces is seen alone,
+	// unless they already belong to a device of their own
+	for _, iface := range device.Interfaces {
+		ifMAC := normalizeMAC(iface.MACAddress)
+		if ifMAC == "" || index.Get([]byte("mac:"+ifMAC)) != nil {
+			continue
+		}
+		if err := index.Put([]byte("mac:"+ifMAC), []byte(id)); err != nil {
+			return err
+		}
+	}
+	// Only the scanned IP identifies the device; its other addresses may be shared with
+	// unrelated hosts (docker0, libvirt bridges, VRRP/HSRP or anycast addresses)
+	if device.IP != "" {
+		if err := index.Put([]byte("ip:"+device.IP), []byte(id)); err != nil {
+			return err
+		}
+	}
+
+	observation := models.DeviceObservation{
+		ScanID:   scanID,
+		ScanTime: seen,
+		Device:   *device,
+	}
+	return putJSON(tx.Bucket(bucketObservations), observationKey(id, scanKey), observation)
+}
+
+// resolveDeviceID finds the inventory id for a device: by MAC first, then by its IP for
+// devices that were previously stored without a MAC, otherwise a new id is derived
+func resolveDeviceID(index, devices *bolt.Bucket, mac, ip string) string {
+	if mac != "" {
+		if id := index.Get([]byte("mac:" + mac)); id != nil {
+			return string(id)
+		}
+	}
+
+	if ip != "" {
+		if id := index.Get([]byte("ip:" + ip)); id != nil {
+			// Only reuse an IP match when it cannot belong to a different physical device
+			var existing models.InventoryDevice
+			if data := devices.Get(id); data != nil && json.Unmarshal(data, &existing) == nil {
+				if mac == "" || existing.MACAddress == "" {
+					return string(id)
+				}
+			}
+		}
+	}
+
+	if mac != "" {
+		return "mac-" + strings.ToLower(strings.ReplaceAll(mac, ":", ""))
+	}
+	return "ip-" + ip
+}
+
+// touchIPHistory extends the history entry for ip or appends a new one
+func touchIPHistory(history []models.IPHistoryEntry, ip string, seen time.Time) []models.IPHistoryEntry {
+	if ip == "" {
+		return history
+	}
+	for i := range history {
+		if history[i].IP == ip {
+			if seen.After(history[i].LastSeen) {
+				history[i].LastSeen = seen
+			}
+			return history
+		}
+	}
+	return append(history, models.IPHistoryEntry{IP: ip, FirstSeen: seen, LastSeen: seen})
+}
+
+// ListScans returns scans newest first without their topologies; limit <= 0 returns all
+func (s *Store) ListScans(limit int) ([]models.ScanRecord, error) {
+	var scans []models.ScanRecord
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketScans).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var record models.ScanRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			record.Topology = nil
+			scans = append(scans, record)
+			if limit > 0 && len(scans) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scans: %v", err)
+	}
+	return scans, nil
+}
+
+// GetScan returns a scan including its stored topology
+func (s *Store) GetScan(id string) (*models.ScanRecord, error) {
+	seq, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	var record models.ScanRecord
+	err = s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketScans).Get(itob(seq))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// PreviousScan returns the most recent complete scan of networkRange recorded before the
+// scan beforeID; cancelled scans hold partial results and make no baseline
+func (s *Store) PreviousScan(networkRange, beforeID string) (*models.ScanRecord, error) {
+	before, err := strconv.ParseUint(beforeID, 10, 64)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	var record *models.ScanRecord
+	err = s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketScans).Cursor()
+		c.Seek(itob(before))
+		for k, v := c.Prev(); k != nil; k, v = c.Prev() {
+			var candidate models.ScanRecord
+			if err := json.Unmarshal(v, &candidate); err != nil {
+				return err
+			}
+			if candidate.NetworkRange == networkRange && !candidate.Cancelled {
+				record = &candidate
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// LatestScan returns the most recent scan including its topology, restricted to scans
+// of networkRange unless it is empty
+func (s *Store) LatestScan(networkRange string) (*models.ScanRecord, error) {
+	var record *models.ScanRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketScans).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var candidate models.ScanRecord
+			if err := json.Unmarshal(v, &candidate); err != nil {
+				return err
+			}
+			if networkRange == "" || candidate.NetworkRange == networkRange {
+				record = &candidate
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ListDevices returns inventory devices, most recently seen first
+func (s *Store) ListDevices() ([]models.InventoryDevice, error) {
+	var devices []models.InventoryDevice
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketDevices).ForEach(func(k, v []byte) error {
+			var inv models.InventoryDevice
+			if err := json.Unmarshal(v, &inv); err != nil {
+				return err
+			}
+			devices = append(devices, inv)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %v", err)
+	}
+
+	sort.Slice(devices, func(a, b int) bool {
+		return devices[a].LastSeen.After(devices[b].LastSeen)
+	})
+	return devices, nil
+}
+
+// GetDevice returns an inventory device by id, MAC address or the IP it was scanned at,
+// together with its observations (newest first)
+func (s *Store) GetDevice(key string) (*models.InventoryDevice, []models.DeviceObservation, error) {
+	var inv models.InventoryDevice
+	var observations []models.DeviceObservation
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		devices := tx.Bucket(bucketDevices)
+		index := tx.Bucket(bucketDeviceIndex)
+
+		id := []byte(key)
+		if devices.Get(id) == nil {
+			if byMAC := index.Get([]byte("mac:" + normalizeMAC(key))); byMAC != nil {
+				id = byMAC
+			} else if byIP := index.Get([]byte("ip:" + key)); byIP != nil {
+				id = byIP
+			}
+		}
+
+		data := devices.Get(id)
+		if data == nil {
+			return ErrNotFound
+		}
+		if err := json.Unmarshal(data, &inv); err != nil {
+			return err
+		}
+
+		prefix := []byte(inv.ID + "/")
+		c := tx.Bucket(bucketObservations).Cursor()
+		for k, v := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = c.Next() {
+			var obs models.DeviceObservation
+			if err := json.Unmarshal(v, &obs); err != nil {
+				return err
+			}
+			observations = append(observations, obs)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Keys sort oldest first; present newest first
+	for i, j := 0, len(observations)-1; i < j; i, j = i+1, j-1 {
+		observations[i], observations[j] = observations[j], observations[i]
+	}
+	return &inv, observations, nil
+}
+
+func putJSON(b *bolt.Bucket, key []byte, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+func observationKey(deviceID string, scanKey []byte) []byte {
+	key := make([]byte, 0, len(deviceID)+1+len(scanKey))
+	key = append(key, deviceID...)
+	key = append(key, '/')
+	return append(key, scanKey...)
+}
+
+// itob encodes a sequence number as a sortable big-endian key
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// normalizeMAC upper-cases a MAC and uses ':' separators; the all-zero MAC is treated as unknown
+func normalizeMAC(mac string) string {
+	mac = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(mac), "-", ":"))
+	if mac == "" || mac == "00:00:00:00:00:00" {
+		return ""
+	}
+	return mac
+}
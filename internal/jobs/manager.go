@@ -0,0 +1,347 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"network-discovery/internal/discovery"
+	"network-discovery/internal/events"
+	"network-discovery/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultMaxJobs is the number of finished jobs kept in memory before the oldest are evicted
+const DefaultMaxJobs = 100
+
+// job couples the public job state with its cancellation handle and event history
+type job struct {
+	info   models.ScanJob
+	cancel context.CancelFunc
+
+	events  []models.ScanEvent
+	notify  chan struct{}  // closed and replaced whenever events are appended
+	partial map[string]int // IP -> index in info.Partial
+}
+
+// Manager runs scan requests asynchronously and keeps track of their state
+type Manager struct {
+	discovery *discovery.NetworkDiscovery
+	logger    *logrus.Logger
+	maxJobs   int
+
+	mu   sync.RWMutex
+	jobs map[string]*job
+	wg   sync.WaitGroup
+}
+
+func NewManager(discovery *discovery.NetworkDiscovery, logger *logrus.Logger) *Manager {
+	return &Manager{
+		discovery: discovery,
+		logger:    logger,
+		maxJobs:   DefaultMaxJobs,
+		jobs:      make(map[string]*job),
+	}
+}
+
+// Submit registers a scan request of client and starts it in the background, returning the
+// queued job. The job stays queued until the discovery scheduler admits the scan.
+func (m *Manager) Submit(req *models.ScanRequest, client string) (*models.ScanJob, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate job id: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(discovery.WithClient(context.Background(), client))
+	j := &job{
+		info: models.ScanJob{
+			ID:           id,
+			State:        models.JobStateQueued,
+			ScanType:     req.ScanType,
+			NetworkRange: req.NetworkRange,
+			Progress:     models.ScanProgress{Phase: models.JobStateQueued},
+			CreatedAt:    time.Now(),
+		},
+		cancel:  cancel,
+		notify:  make(chan struct{}),
+		partial: make(map[string]int),
+	}
+	ctx = events.WithObserver(ctx, m.observer(j))
+
+	m.mu.Lock()
+	m.jobs[id] = j
+	m.pruneLocked()
+	snapshot := j.info
+	m.mu.Unlock()
+
+	m.logger.Infof("Submitted scan job %s for %s (type: %s)", id, req.NetworkRange, req.ScanType)
+
+	m.wg.Add(1)
+	go m.run(ctx, j, req)
+
+	return &snapshot, nil
+}
+
+// run executes the scan for a job and records its outcome
+func (m *Manager) run(ctx context.Context, j *job, req *models.ScanRequest) {
+	defer m.wg.Done()
+	defer j.cancel()
+
+	// The job switches to running when the scheduler emits its started event
+	if !m.update(j, func(info *models.ScanJob) bool {
+		if info.State != models.JobStateQueued {
+			now := time.Now()
+			info.FinishedAt = &now
+			m.appendEventLocked(j, models.ScanEvent{Type: models.EventDone, State: info.State})
+			return false
+		}
+		return true
+	}) {
+		return
+	}
+
+	result, err := m.discovery.PerformFullScan(ctx, req)
+
+	m.update(j, func(info *models.ScanJob) bool {
+		now := time.Now()
+		info.FinishedAt = &now
+
+		switch {
+		case err == nil && result.Topology.Cancelled:
+			// Cancelled by the user or stopped by the maximum scan duration; keep partial results
+			info.State = models.JobStateCancelled
+			info.Error = result.Topology.CancelReason
+			info.Result = result
+			info.Progress.DevicesFound = result.Topology.TotalCount
+		case ctx.Err() != nil:
+			info.State = models.JobStateCancelled
+		case err != nil:
+			info.State = models.JobStateFailed
+			info.Error = err.Error()
+		default:
+			info.State = models.JobStateCompleted
+			info.Result = result
+			info.Progress.Percent = 100
+			info.Progress.DevicesFound = result.Topology.TotalCount
+		}
+		info.Progress.Phase = info.State
+		info.Queue = nil
+		if info.Result != nil {
+			info.Partial = nil
+		}
+		m.appendEventLocked(j, models.ScanEvent{Type: models.EventDone, State: info.State})
+		return true
+	})
+
+	m.logger.Infof("Scan job %s finished with state %s", j.info.ID, m.state(j))
+}
+
+// observer records live scan events for a job, updating its progress and partial devices
+func (m *Manager) observer(j *job) events.Observer {
+	return func(event models.ScanEvent) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		switch event.Type {
+		case models.EventQueued:
+			if j.info.State == models.JobStateQueued {
+				j.info.Queue = event.Queue
+				m.appendEventLocked(j, event)
+			}
+			return
+		case models.EventStarted:
+			if j.info.State == models.JobStateQueued {
+				now := time.Now()
+				j.info.State = models.JobStateRunning
+				j.info.StartedAt = &now
+				j.info.Progress.Phase = "scanning"
+				j.info.Queue = nil
+				m.appendEventLocked(j, event)
+			}
+			return
+		}
+
+		if j.info.State != models.JobStateRunning {
+			return
+		}
+
+		if event.Device != nil {
+			if idx, ok := j.partial[event.Device.IP]; ok {
+				j.info.Partial[idx] = *event.Device
+			} else {
+				j.partial[event.Device.IP] = len(j.info.Partial)
+				j.info.Partial = append(j.info.Partial, *event.Device)
+			}
+		}
+		if event.Progress != nil {
+			j.info.Progress = *event.Progress
+		}
+		j.info.Progress.DevicesFound = len(j.info.Partial)
+
+		m.appendEventLocked(j, event)
+	}
+}
+
+// appendEventLocked stores an event and wakes up every subscriber of the job
+func (m *Manager) appendEventLocked(j *job, event models.ScanEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	j.events = append(j.events, event)
+	close(j.notify)
+	j.notify = make(chan struct{})
+}
+
+// Events returns the job events starting at index from together with the index of the
+// first one, a channel that is closed when more events arrive, and whether the job has
+// emitted its final "done" event. A from outside the stored events starts after the
+// last one.
+func (m *Manager) Events(id string, from int) ([]models.ScanEvent, int, <-chan struct{}, bool, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, 0, nil, false, false
+	}
+
+	if from < 0 || from > len(j.events) {
+		from = len(j.events)
+	}
+	pending := make([]models.ScanEvent, len(j.events)-from)
+	copy(pending, j.events[from:])
+
+	finished := len(j.events) > 0 && j.events[len(j.events)-1].Type == models.EventDone
+	return pending, from, j.notify, finished, true
+}
+
+// update applies fn to the job state under the manager lock
+func (m *Manager) update(j *job, fn func(info *models.ScanJob) bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fn(&j.info)
+}
+
+func (m *Manager) state(j *job) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return j.info.State
+}
+
+// Get returns a snapshot of the job with the given id
+func (m *Manager) Get(id string) (*models.ScanJob, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	snapshot := j.info
+	snapshot.Partial = append([]models.Device(nil), j.info.Partial...)
+	return &snapshot, true
+}
+
+// List returns snapshots of all known jobs, newest first, without their devices
+func (m *Manager) List() []models.ScanJob {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]models.ScanJob, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		snapshot := j.info
+		snapshot.Result = nil
+		snapshot.Partial = nil
+		list = append(list, snapshot)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].CreatedAt.After(list[b].CreatedAt)
+	})
+	return list
+}
+
+// Cancel stops a queued or running job. Finished jobs are left untouched. The job gets
+// its FinishedAt, and becomes eligible for pruning, once its scan has returned.
+func (m *Manager) Cancel(id string) (*models.ScanJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("job not found: %s", id)
+	}
+
+	switch j.info.State {
+	case models.JobStateQueued, models.JobStateRunning:
+		j.info.State = models.JobStateCancelled
+		j.info.Progress.Phase = models.JobStateCancelled
+		j.cancel()
+		m.logger.Infof("Cancelled scan job %s", id)
+	default:
+		return nil, fmt.Errorf("job %s already %s", id, j.info.State)
+	}
+
+	snapshot := j.info
+	snapshot.Partial = append([]models.Device(nil), j.info.Partial...)
+	return &snapshot, nil
+}
+
+// Shutdown cancels every unfinished job and waits for their goroutines until ctx expires
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.RLock()
+	for _, j := range m.jobs {
+		j.cancel()
+	}
+	m.mu.RUnlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pruneLocked evicts the oldest finished jobs once more than maxJobs are tracked. Only
+// run sets FinishedAt, so jobs whose scan is still returning are never evicted.
+func (m *Manager) pruneLocked() {
+	if len(m.jobs) <= m.maxJobs {
+		return
+	}
+
+	var finished []*job
+	for _, j := range m.jobs {
+		if j.info.FinishedAt != nil {
+			finished = append(finished, j)
+		}
+	}
+	sort.Slice(finished, func(a, b int) bool {
+		return finished[a].info.CreatedAt.Before(finished[b].info.CreatedAt)
+	})
+
+	for _, j := range finished {
+		if len(m.jobs) <= m.maxJobs {
+			break
+		}
+		delete(m.jobs, j.info.ID)
+	}
+}
+
+func newJobID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
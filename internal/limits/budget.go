@@ -0,0 +1,61 @@
+package limits
+
+import "context"
+
+// Budget bounds the number of probes in flight across all scans. A probe is one unit of
+// network work: an SNMP query, a ping, or the port scan of a host (one nmap process or
+// one TCP connect sweep).
+type Budget struct {
+	slots chan struct{}
+}
+
+// NewBudget creates a budget of size concurrent probes; size <= 0 returns nil (unlimited)
+func NewBudget(size int) *Budget {
+	if size <= 0 {
+		return nil
+	}
+	return &Budget{slots: make(chan struct{}, size)}
+}
+
+// Size returns the number of probes allowed at once, 0 meaning unlimited
+func (b *Budget) Size() int {
+	if b == nil {
+		return 0
+	}
+	return cap(b.slots)
+}
+
+// InUse returns the number of probes currently holding a slot
+func (b *Budget) InUse() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.slots)
+}
+
+type budgetKey struct{}
+
+// WithBudget returns a context whose probes draw from b
+func WithBudget(ctx context.Context, b *Budget) context.Context {
+	if b == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// Acquire waits for a probe slot of the budget attached to ctx and returns the function
+// that gives it back. It fails with ctx.Err() if ctx ends first, and returns immediately
+// when ctx carries no budget.
+func Acquire(ctx context.Context) (func(), error) {
+	b, ok := ctx.Value(budgetKey{}).(*Budget)
+	if !ok || b == nil {
+		return func() {}, ctx.Err()
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return func() { <-b.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -2,69 +2,444 @@ package models
 
 import "time"
 
-// Device represents a network device discovered via SNMP or ARP
+// Device represents a network device discovered via SNMP or ARP. IP is its primary
+// (management) address; a device reachable on several addresses lists all of them in
+// Addresses.
 type Device struct {
-	IP           string     `json:"ip"`
-	MACAddress   string     `json:"mac_address,omitempty"` // MAC address from ARP or SNMP
-	Hostname     string     `json:"hostname"`
-	Description  string     `json:"description"`
-	Contact      string     `json:"contact"`
-	Location     string     `json:"location"`
-	Uptime       string     `json:"uptime"`
-	Vendor       string     `json:"vendor"`
-	Model        string     `json:"model"`
-	Version      string     `json:"version"`
-	Community    string     `json:"-"` // SNMP community string (hidden from JSON)
-	LastSeen     time.Time  `json:"last_seen"`
-	IsReachable  bool       `json:"is_reachable"`
-	ResponseTime int64      `json:"response_time_ms"`
-	ScanMethod   string     `json:"scan_method"` // "SNMP", "ARP", or "COMBINED"
-	OpenPorts    []PortInfo `json:"open_ports,omitempty"`
+	IP             string          `json:"ip"`
+	MACAddress     string          `json:"mac_address,omitempty"` // MAC address from ARP or SNMP
+	Hostname       string          `json:"hostname"`
+	Description    string          `json:"description"`
+	Contact        string          `json:"contact"`
+	Location       string          `json:"location"`
+	Uptime         string          `json:"uptime"`
+	Vendor         string          `json:"vendor"`
+	Model          string          `json:"model"`
+	Version        string          `json:"version"`
+	ObjectID       string          `json:"object_id,omitempty"`              // sysObjectID of SNMP devices, e.g. "1.3.6.1.4.1.9.1.1208"
+	OS             string          `json:"os,omitempty"`                     // Operating system from the fingerprint rules, else the OS guess
+	Services       int             `json:"sys_services,omitempty"`           // SNMP sysServices, the OSI layers the device serves (bit mask)
+	Capabilities   []string        `json:"capabilities,omitempty"`           // "router", "bridge", "printer" from SNMP MIBs, or the LLDP/CDP capabilities of placeholders
+	DeviceType     string          `json:"device_type,omitempty"`            // Classified type, e.g. "router", "switch", "printer"
+	TypeConfidence int             `json:"device_type_confidence,omitempty"` // 0-100
+	TypeEvidence   []string        `json:"device_type_evidence,omitempty"`   // The signals that decided DeviceType
+	TTL            int             `json:"ttl,omitempty"`                    // TTL of the ICMP echo reply
+	OSGuess        *OSGuess        `json:"os_guess,omitempty"`               // OS guessed from TTL and SYN-ACKs, or nmap's best OS match
+	OSMatches      []OSMatch       `json:"os_matches,omitempty"`             // nmap -O matches, most accurate first
+	Community      string          `json:"-"`                                // SNMP community string (hidden from JSON)
+	SNMPVersion    string          `json:"snmp_version,omitempty"`           // "1", "2c" or "3" when the device answered SNMP
+	SNMPUsername   string          `json:"snmp_username,omitempty"`          // SNMPv3 user that answered (no secrets)
+	LastSeen       time.Time       `json:"last_seen"`
+	IsReachable    bool            `json:"is_reachable"`
+	ResponseTime   int64           `json:"response_time_ms"`
+	ScanMethod     string          `json:"scan_method"` // "SNMP", "ARP", "NDP", "COMBINED", "ARP_CACHE", or "LLDP"/"CDP" for placeholders
+	OpenPorts      []PortInfo      `json:"open_ports,omitempty"`
+	Addresses      []DeviceAddress `json:"addresses,omitempty"`   // Every known address, the primary IP first
+	Interfaces     []Interface     `json:"interfaces,omitempty"`  // Interface table of SNMP devices
+	Neighbors      []Neighbor      `json:"neighbors,omitempty"`   // LLDP/CDP neighbours of SNMP devices
+	Placeholder    bool            `json:"placeholder,omitempty"` // Only known from a neighbour's LLDP/CDP table, not scanned
+	SwitchPort     *SwitchPort     `json:"switch_port,omitempty"` // Access port the device is connected to
+	Fingerprint    *Fingerprint    `json:"fingerprint,omitempty"` // How vendor, model, OS and version were identified
+
+	// Forwarding table of SNMP switches; only used to locate devices during the scan
+	ForwardingTable []ForwardingEntry `json:"-"`
+	// ARP/neighbour cache of SNMP devices; only used to resolve MACs during the scan
+	ARPCache []ARPEntry `json:"-"`
+}
+
+// IPs returns the primary IP followed by the device's other known addresses
+func (d *Device) IPs() []string {
+	ips := []string{}
+	if d.IP != "" {
+		ips = append(ips, d.IP)
+	}
+	for _, addr := range d.Addresses {
+		if addr.IP != "" && addr.IP != d.IP {
+			ips = append(ips, addr.IP)
+		}
+	}
+	return ips
+}
+
+// DeviceAddress is one IP address of a device
+type DeviceAddress struct {
+	IP           string `json:"ip"`
+	PrefixLength int    `json:"prefix_length,omitempty"` // From the SNMP address table
+	MACAddress   string `json:"mac_address,omitempty"`   // MAC the address resolves to
+	IfIndex      int    `json:"if_index,omitempty"`      // SNMP interface the address is assigned to
+	Source       string `json:"source"`                  // Scan method that found the address
+}
+
+// Interface is a network interface of an SNMP device (IF-MIB ifTable and ifXTable)
+type Interface struct {
+	Index       int        `json:"index"`
+	Name        string     `json:"name,omitempty"`        // ifName, e.g. "Gi0/1"
+	Alias       string     `json:"alias,omitempty"`       // ifAlias, the administrator's label
+	Description string     `json:"description,omitempty"` // ifDescr
+	Type        int        `json:"type,omitempty"`        // IANAifType number
+	TypeName    string     `json:"type_name,omitempty"`   // IANAifType name, e.g. "ethernetCsmacd"
+	SpeedMbps   uint64     `json:"speed_mbps,omitempty"`  // ifHighSpeed, or ifSpeed on agents without ifXTable
+	MTU         int        `json:"mtu,omitempty"`
+	MACAddress  string     `json:"mac_address,omitempty"` // ifPhysAddress
+	AdminStatus string     `json:"admin_status,omitempty"`
+	OperStatus  string     `json:"oper_status,omitempty"`
+	LastChange  *time.Time `json:"last_change,omitempty"` // When the interface last changed its oper status
+	IPAddresses []string   `json:"ip_addresses,omitempty"`
+}
+
+// Neighbor is a device an SNMP device sees on one of its ports over LLDP or CDP
+type Neighbor struct {
+	Protocol          string   `json:"protocol"` // "LLDP" or "CDP"
+	LocalPort         string   `json:"local_port"`
+	LocalIfIndex      int      `json:"local_if_index,omitempty"`
+	ChassisID         string   `json:"chassis_id,omitempty"` // LLDP chassis ID (often a MAC) or CDP device ID
+	SysName           string   `json:"sys_name,omitempty"`
+	SysDescription    string   `json:"sys_description,omitempty"` // LLDP sysDescr or CDP software version
+	Platform          string   `json:"platform,omitempty"`        // CDP platform, e.g. "cisco WS-C2960-24TT-L"
+	RemotePort        string   `json:"remote_port,omitempty"`
+	RemotePortDescr   string   `json:"remote_port_description,omitempty"`
+	ManagementAddress string   `json:"management_address,omitempty"`
+	Capabilities      []string `json:"capabilities,omitempty"` // e.g. "bridge", "router", "telephone"
+}
+
+// ForwardingEntry is a MAC address a switch learned on one of its ports (BRIDGE-MIB
+// dot1dTpFdbTable or Q-BRIDGE-MIB dot1qTpFdbTable)
+type ForwardingEntry struct {
+	MACAddress string
+	FDBID      int // Filtering database ID of dot1qTpFdbTable; 0 for dot1dTpFdbTable
+	VLAN       int // VLAN using the filtering database (dot1qVlanFdbId); 0 when unknown or shared
+	Port       string
+	IfIndex    int
+}
+
+// Fingerprint is what the fingerprint rules concluded about a device, and which rules
+// matched. Each field comes from the matching rule with the highest priority that sets it.
+type Fingerprint struct {
+	Vendor     string             `json:"vendor,omitempty"`
+	Model      string             `json:"model,omitempty"`
+	OS         string             `json:"os,omitempty"`
+	Version    string             `json:"version,omitempty"`
+	DeviceType string             `json:"device_type,omitempty"` // e.g. "router", "switch", "printer"
+	Confidence int                `json:"confidence"`            // 0-100, the highest confidence of the rules that set a field
+	Matches    []FingerprintMatch `json:"matches,omitempty"`     // Matching rules, highest priority first
+}
+
+// FingerprintMatch is a fingerprint rule that matched a device
+type FingerprintMatch struct {
+	Rule       string   `json:"rule"`
+	Priority   int      `json:"priority"`
+	Confidence int      `json:"confidence"`
+	Fields     []string `json:"fields,omitempty"` // Fingerprint fields this rule decided
+	Evidence   []string `json:"evidence"`         // The conditions that matched, e.g. `sys_descr "Cisco IOS Software"`
+}
+
+// ARPEntry is an IP to MAC mapping from the ARP or IPv6 neighbour cache of an SNMP
+// device (IP-MIB ipNetToPhysicalTable or ipNetToMediaTable)
+type ARPEntry struct {
+	IP         string
+	MACAddress string
+	IfIndex    int
+}
+
+// SwitchPort is the switch port a device is connected to, found in the forwarding
+// table of a scanned switch
+type SwitchPort struct {
+	Switch     string `json:"switch"` // Primary IP of the switch
+	SwitchName string `json:"switch_name,omitempty"`
+	Port       string `json:"port"`
+	IfIndex    int    `json:"if_index,omitempty"`
+	VLAN       int    `json:"vlan,omitempty"`
+	FDBID      int    `json:"fdb_id,omitempty"` // Filtering database the address was learned in
+	MACCount   int    `json:"mac_count"`        // MAC addresses the switch learned on the port
+}
+
+// Link is a connection between ports of two devices, reported over LLDP or CDP by at
+// least one of them. Devices are referenced by their primary IP, or by name for
+// placeholders without a known address.
+type Link struct {
+	LocalDevice  string   `json:"local_device"`
+	LocalPort    string   `json:"local_port"`
+	RemoteDevice string   `json:"remote_device"`
+	RemotePort   string   `json:"remote_port,omitempty"`
+	Protocols    []string `json:"protocols"` // "LLDP", "CDP"
 }
 
 // NetworkTopology represents the overall network topology
 type NetworkTopology struct {
 	Devices        []Device  `json:"devices"`
+	Links          []Link    `json:"links,omitempty"` // LLDP/CDP links between devices
 	TotalCount     int       `json:"total_count"`
 	ReachableCount int       `json:"reachable_count"`
 	SNMPCount      int       `json:"snmp_count"` // Number of SNMP-enabled devices
 	ARPCount       int       `json:"arp_count"`  // Number of ARP-only devices
 	ScanTime       time.Time `json:"scan_time"`
 	ScanDuration   int64     `json:"scan_duration_ms"`
-	ScanMethod     string    `json:"scan_method"` // "SNMP", "ARP", or "FULL"
+	ScanMethod     string    `json:"scan_method"`             // "SNMP", "ARP", or "FULL"
+	Cancelled      bool      `json:"cancelled,omitempty"`     // Scan stopped early; devices are partial
+	CancelReason   string    `json:"cancel_reason,omitempty"` // e.g. "context canceled" or "context deadline exceeded"
 }
 
 // ScanRequest represents a network scan request
 type ScanRequest struct {
-	NetworkRange   string   `json:"network_range" binding:"required"` // e.g., "192.168.1.0/24"
-	Communities    []string `json:"communities"`                      // SNMP communities to try
-	Timeout        int      `json:"timeout"`                          // Timeout in seconds
-	Retries        int      `json:"retries"`                          // Number of retries
-	ScanType       string   `json:"scan_type"`                        // "snmp", "arp", or "full"
-	EnablePortScan *bool    `json:"enable_port_scan"`                 // Optional: enable/disable port scanning
+	NetworkRange         string             `json:"network_range" binding:"required"` // e.g., "192.168.1.0/24"
+	Communities          []string           `json:"communities"`                      // SNMP communities to try
+	V3Credentials        []SNMPv3Credential `json:"v3_credentials"`                   // SNMPv3 credential sets to try
+	Timeout              int                `json:"timeout"`                          // Timeout in seconds
+	Retries              int                `json:"retries"`                          // Number of retries
+	ScanType             string             `json:"scan_type"`                        // "snmp", "arp", or "full"
+	EnablePortScan       *bool              `json:"enable_port_scan"`                 // Optional: enable/disable port scanning
+	PortScanner          string             `json:"port_scanner"`                     // Optional: "auto" (default), "nmap" or "tcp"
+	PortOptions          *PortScanOptions   `json:"port_options"`                     // Optional: ports, protocols and timing of the port scan
+	DiffPrevious         bool               `json:"diff_previous"`                    // Optional: diff against the previous scan of the same range
+	IncludeIPv6          bool               `json:"include_ipv6"`                     // Optional: also discover IPv6 neighbours on the links of an IPv4 range
+	IncludeARPCacheHosts bool               `json:"include_arp_cache_hosts"`          // Optional: add hosts of the range only seen in router ARP caches
 }
 
 // FullScanResult represents the result of a full scan (SNMP + ARP)
 type FullScanResult struct {
+	ScanID     string                 `json:"scan_id,omitempty"` // Inventory scan id when the result was persisted
 	Topology   *NetworkTopology       `json:"topology"`
 	Statistics map[string]interface{} `json:"statistics"`
 	ScanInfo   ScanInfo               `json:"scan_info"`
+	Diff       *TopologyDiff          `json:"diff,omitempty"` // Set when diff_previous was requested and a previous scan exists
 }
 
 // ScanInfo provides detailed information about the scan
 type ScanInfo struct {
-	ScanType        string   `json:"scan_type"`
-	NetworkRange    string   `json:"network_range"`
-	SNMPCommunities []string `json:"snmp_communities,omitempty"`
-	Timeout         int      `json:"timeout"`
-	Retries         int      `json:"retries"`
-	WorkerCount     int      `json:"worker_count"`
+	ScanType             string   `json:"scan_type"`
+	NetworkRange         string   `json:"network_range"`
+	SNMPCommunities      []string `json:"snmp_communities,omitempty"`
+	SNMPv3Users          []string `json:"snmpv3_users,omitempty"`
+	PortScanner          string   `json:"port_scanner,omitempty"`
+	Timeout              int      `json:"timeout"`
+	Retries              int      `json:"retries"`
+	WorkerCount          int      `json:"worker_count"`
+	IncludeIPv6          bool     `json:"include_ipv6,omitempty"`
+	IncludeARPCacheHosts bool     `json:"include_arp_cache_hosts,omitempty"`
 }
 
-// PortInfo describes an open port discovered via Nmap
+// PortInfo describes an open port discovered by a port scanner backend (nmap or built-in TCP)
 type PortInfo struct {
-	Port     int    `json:"port"`
-	Protocol string `json:"protocol"`
-	Service  string `json:"service,omitempty"`
-	State    string `json:"state"`
+	Port      int           `json:"port"`
+	Protocol  string        `json:"protocol"`
+	Service   string        `json:"service,omitempty"`
+	State     string        `json:"state"`
+	Banner    string        `json:"banner,omitempty"`     // First line the service sent on connect (SSH, FTP, SMTP...)
+	SYNACK    *TCPSignature `json:"syn_ack,omitempty"`    // How the port answered the connect, for passive OS fingerprinting
+	Product   string        `json:"product,omitempty"`    // nmap -sV product, e.g. "OpenSSH"
+	Version   string        `json:"version,omitempty"`    // nmap -sV product version, e.g. "8.9p1 Ubuntu 3ubuntu0.6"
+	ExtraInfo string        `json:"extra_info,omitempty"` // nmap -sV extra information, e.g. "Ubuntu Linux; protocol 2.0"
+	Tunnel    string        `json:"tunnel,omitempty"`     // "ssl" when nmap found the service behind TLS
+	CPE       []string      `json:"cpe,omitempty"`        // nmap -sV CPE names, e.g. "cpe:/a:openbsd:openssh:8.9p1"
+}
+
+// TCPSignature describes the SYN-ACK of an open TCP port
+type TCPSignature struct {
+	TTL         int    `json:"ttl"`
+	Window      int    `json:"window"`
+	MSS         int    `json:"mss,omitempty"`
+	WindowScale int    `json:"window_scale,omitempty"`
+	Options     string `json:"options"` // TCP options in order, e.g. "mss,sok,ts,nop,ws"
+	DF          bool   `json:"df"`      // IP don't fragment bit
+}
+
+// OSGuess is an operating system guessed passively from the TTL of ICMP replies and
+// the SYN-ACKs of open TCP ports, or taken from nmap OS detection
+type OSGuess struct {
+	OS         string   `json:"os"`
+	Signature  string   `json:"signature"`  // Name of the matching signature, e.g. "Linux 3.x+"
+	Confidence int      `json:"confidence"` // 0-100
+	Evidence   []string `json:"evidence"`
+}
+
+// OSMatch is an operating system nmap OS detection (-O) matched a host with
+type OSMatch struct {
+	Name       string   `json:"name"`     // e.g. "Linux 4.15 - 5.8"
+	Accuracy   int      `json:"accuracy"` // 0-100
+	Vendor     string   `json:"vendor,omitempty"`
+	Family     string   `json:"family,omitempty"`     // e.g. "Linux", "Windows", "IOS"
+	Generation string   `json:"generation,omitempty"` // e.g. "5.X", "10"
+	Type       string   `json:"type,omitempty"`       // e.g. "general purpose", "router"
+	CPE        []string `json:"cpe,omitempty"`
+}
+
+// PortScanOptions selects the ports, protocols and timing used by the port scan
+type PortScanOptions struct {
+	Ports       string `json:"ports"`        // e.g. "22,80,8000-8100", "top-100", "web" or "T:22,U:161"
+	Protocol    string `json:"protocol"`     // "tcp" (default), "udp" or "both"
+	Timing      *int   `json:"timing"`       // Timing template from 0 (slowest) to 5 (fastest), default 4
+	HostTimeout int    `json:"host_timeout"` // Per-host timeout in seconds, default 30
+
+	ServiceDetection bool `json:"service_detection"` // nmap only: probe service versions (-sV)
+	OSDetection      bool `json:"os_detection"`      // nmap only: detect the OS (-O), needs root
+}
+
+// SNMPv3Credential describes a USM credential set used to query SNMPv3 agents
+type SNMPv3Credential struct {
+	Username       string `json:"username"`
+	AuthProtocol   string `json:"auth_protocol"`   // "", "MD5", "SHA", "SHA-224", "SHA-256", "SHA-384", "SHA-512"
+	AuthPassphrase string `json:"auth_passphrase"` // Required when AuthProtocol is set
+	PrivProtocol   string `json:"priv_protocol"`   // "", "DES", "AES", "AES-192", "AES-256" (+ "AES-192C", "AES-256C")
+	PrivPassphrase string `json:"priv_passphrase"` // Required when PrivProtocol is set
+	ContextName    string `json:"context_name"`
+}
+
+// Scan job states
+const (
+	JobStateQueued    = "queued"
+	JobStateRunning   = "running"
+	JobStateCompleted = "completed"
+	JobStateFailed    = "failed"
+	JobStateCancelled = "cancelled"
+)
+
+// ScanJob represents an asynchronous scan submitted through the jobs API
+type ScanJob struct {
+	ID           string          `json:"id"`
+	State        string          `json:"state"` // "queued", "running", "completed", "failed", or "cancelled"
+	ScanType     string          `json:"scan_type"`
+	NetworkRange string          `json:"network_range"`
+	Progress     ScanProgress    `json:"progress"`
+	Queue        *QueueStatus    `json:"queue,omitempty"`           // position while waiting for a scan slot
+	Partial      []Device        `json:"partial_devices,omitempty"` // devices found so far while running
+	Result       *FullScanResult `json:"result,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	StartedAt    *time.Time      `json:"started_at,omitempty"`
+	FinishedAt   *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Scan phases reported in progress events
+const (
+	PhaseARP   = "arp"
+	PhaseSNMP  = "snmp"
+	PhasePorts = "ports"
+	PhaseMerge = "merge"
+	PhaseNDP   = "ndp"
+)
+
+// ScanProgress reports how far a scan job has come
+type ScanProgress struct {
+	Phase        string `json:"phase"`  // current scan phase
+	Probed       int    `json:"probed"` // IPs/hosts probed in the current phase
+	Total        int    `json:"total"`  // IPs/hosts to probe in the current phase
+	Percent      int    `json:"percent"`
+	DevicesFound int    `json:"devices_found"`
+}
+
+// Scan event types emitted while a scan is running
+const (
+	EventQueued   = "queued"   // the scan is waiting for a slot; sent whenever its position changes
+	EventStarted  = "started"  // the scan left the queue and is running
+	EventDevice   = "device"   // a worker found (or enriched) a device
+	EventProgress = "progress" // periodic probed/total update for a phase
+	EventDone     = "done"     // the scan job reached a final state
+)
+
+// ScanEvent is a single live update from a running scan
+type ScanEvent struct {
+	Type     string        `json:"type"`
+	Phase    string        `json:"phase,omitempty"`
+	Device   *Device       `json:"device,omitempty"`
+	Progress *ScanProgress `json:"progress,omitempty"`
+	Queue    *QueueStatus  `json:"queue,omitempty"`
+	State    string        `json:"state,omitempty"` // final job state for "done" events
+	Time     time.Time     `json:"time"`
+}
+
+// QueueStatus reports where a scan waits in the scan scheduler queue
+type QueueStatus struct {
+	Position int `json:"position"` // 1 = next to start, subject to per-client fairness
+	Length   int `json:"length"`   // scans currently queued
+	Running  int `json:"running"`  // scans currently running
+}
+
+// SchedulerStatus is a snapshot of the scan scheduler
+type SchedulerStatus struct {
+	Running            int            `json:"running"`
+	Queued             int            `json:"queued"`
+	MaxConcurrentScans int            `json:"max_concurrent_scans"`
+	MaxScansPerClient  int            `json:"max_scans_per_client"`
+	MaxQueuedScans     int            `json:"max_queued_scans"`
+	MaxHostsPerScan    int            `json:"max_hosts_per_scan"`
+	ProbesInUse        int            `json:"probes_in_use"`
+	MaxProbes          int            `json:"max_probes"`
+	Clients            map[string]int `json:"clients"` // client -> running plus queued scans
+}
+
+// ScanRecord is a persisted scan in the device inventory
+type ScanRecord struct {
+	ID           string           `json:"id"`
+	ScanType     string           `json:"scan_type"`
+	NetworkRange string           `json:"network_range"`
+	ScanTime     time.Time        `json:"scan_time"`
+	ScanDuration int64            `json:"scan_duration_ms"`
+	DeviceCount  int              `json:"device_count"`
+	Cancelled    bool             `json:"cancelled,omitempty"`
+	Topology     *NetworkTopology `json:"topology,omitempty"` // Omitted in scan listings
+}
+
+// InventoryDevice is a device tracked across scans, keyed by MAC address (or IP when no MAC is known)
+type InventoryDevice struct {
+	ID         string           `json:"id"`
+	MACAddress string           `json:"mac_address,omitempty"`
+	IP         string           `json:"ip"` // Most recently observed IP
+	Hostname   string           `json:"hostname,omitempty"`
+	Vendor     string           `json:"vendor,omitempty"`
+	FirstSeen  time.Time        `json:"first_seen"`
+	LastSeen   time.Time        `json:"last_seen"`
+	LastScanID string           `json:"last_scan_id"`
+	ScanCount  int              `json:"scan_count"`
+	IPHistory  []IPHistoryEntry `json:"ip_history"`
+	Latest     Device           `json:"latest"` // Device as seen in the most recent scan
+}
+
+// IPHistoryEntry records when an inventory device was seen with a given IP
+type IPHistoryEntry struct {
+	IP        string    `json:"ip"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// DeviceObservation is a single sighting of an inventory device in a scan
+type DeviceObservation struct {
+	ScanID   string    `json:"scan_id"`
+	ScanTime time.Time `json:"scan_time"`
+	Device   Device    `json:"device"`
+}
+
+// TopologyDiff describes what changed between two topologies of the same network
+type TopologyDiff struct {
+	FromScanID string         `json:"from_scan_id,omitempty"`
+	ToScanID   string         `json:"to_scan_id,omitempty"`
+	FromTime   time.Time      `json:"from_time"`
+	ToTime     time.Time      `json:"to_time"`
+	Added      []Device       `json:"added"`   // Devices only present in the newer topology
+	Removed    []Device       `json:"removed"` // Devices only present in the older topology
+	Changed    []DeviceChange `json:"changed"` // Devices present in both with different attributes
+	Summary    DiffSummary    `json:"summary"`
+}
+
+// DeviceChange lists the differences found for one device between two topologies
+type DeviceChange struct {
+	IP          string        `json:"ip"`                    // IP in the newer topology
+	MACAddress  string        `json:"mac_address,omitempty"` // MAC in the newer topology
+	Changes     []FieldChange `json:"changes,omitempty"`
+	OpenedPorts []PortInfo    `json:"opened_ports,omitempty"`
+	ClosedPorts []PortInfo    `json:"closed_ports,omitempty"`
+}
+
+// FieldChange is a single attribute that differs between two observations of a device
+type FieldChange struct {
+	Field string `json:"field"` // "ip", "mac_address", "vendor", "hostname", "description", ...
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// DiffSummary counts the entries of a TopologyDiff
+type DiffSummary struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
 }
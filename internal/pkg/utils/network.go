@@ -18,7 +18,8 @@ func IsValidCIDR(cidr string) bool {
 	return err == nil
 }
 
-// GetLocalNetworks returns the local network ranges
+// GetLocalNetworks returns the IPv4 and IPv6 network ranges of the up, non-loopback interfaces.
+// Link-local IPv6 prefixes are left out since every interface has fe80::/64.
 func GetLocalNetworks() ([]string, error) {
 	var networks []string
 
@@ -38,50 +39,19 @@ func GetLocalNetworks() ([]string, error) {
 		}
 
 		for _, addr := range addrs {
-			if ipnet, ok := addr.(*net.IPNet); ok {
-				if ipnet.IP.To4() != nil {
-					networks = append(networks, ipnet.String())
-				}
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.IsLinkLocalUnicast() {
+				continue
 			}
+			// Report the network, not the host address (192.168.1.0/24, 2001:db8::/64)
+			network := &net.IPNet{IP: ipnet.IP.Mask(ipnet.Mask), Mask: ipnet.Mask}
+			networks = append(networks, network.String())
 		}
 	}
 
 	return networks, nil
 }
 
-// ParseVendorFromDescription extracts vendor information from SNMP description
-func ParseVendorFromDescription(description string) string {
-	desc := strings.ToLower(description)
-
-	vendorPatterns := map[string][]string{
-		"Cisco":      {"cisco", "ios", "catalyst", "nexus"},
-		"Juniper":    {"juniper", "junos", "srx", "mx", "ex"},
-		"Huawei":     {"huawei", "vrp", "s5700", "s6700"},
-		"HP":         {"hp", "hewlett", "packard", "procurve", "aruba"},
-		"Dell":       {"dell", "powerconnect", "force10"},
-		"Netgear":    {"netgear", "prosafe"},
-		"D-Link":     {"d-link", "dgs", "des"},
-		"TP-Link":    {"tp-link", "tl-", "archer"},
-		"MikroTik":   {"mikrotik", "routeros", "routerboard"},
-		"Ubiquiti":   {"ubiquiti", "unifi", "edgemax"},
-		"Fortinet":   {"fortinet", "fortigate", "fortios"},
-		"Palo Alto":  {"palo alto", "pa-", "panorama"},
-		"SonicWall":  {"sonicwall", "sonicpoints"},
-		"Watchguard": {"watchguard", "firebox"},
-		"Meraki":     {"meraki", "cisco meraki"},
-	}
-
-	for vendor, patterns := range vendorPatterns {
-		for _, pattern := range patterns {
-			if strings.Contains(desc, pattern) {
-				return vendor
-			}
-		}
-	}
-
-	return "Unknown"
-}
-
 // FormatUptime formats uptime ticks into human readable format
 func FormatUptime(ticks uint32) string {
 	seconds := ticks / 100
@@ -114,6 +84,22 @@ func SanitizeHostname(hostname string) string {
 	return cleaned
 }
 
+// MaxEnumeratedIPv6Bits is the largest IPv6 host part that is scanned address by address
+// (a /112). Larger IPv6 ranges are only reachable through neighbour discovery.
+const MaxEnumeratedIPv6Bits = 16
+
+// IsIPv6CIDR reports whether cidr is a valid IPv6 network range
+func IsIPv6CIDR(cidr string) bool {
+	ip, _, err := net.ParseCIDR(cidr)
+	return err == nil && ip.To4() == nil
+}
+
+// IsEnumerationTooLarge reports whether ipNet is an IPv6 range too large to enumerate
+func IsEnumerationTooLarge(ipNet *net.IPNet) bool {
+	ones, bits := ipNet.Mask.Size()
+	return bits == 128 && bits-ones > MaxEnumeratedIPv6Bits
+}
+
 // GetNetworkSize calculates the number of hosts in a CIDR range
 func GetNetworkSize(cidr string) (int, error) {
 	_, ipNet, err := net.ParseCIDR(cidr)
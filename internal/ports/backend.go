@@ -0,0 +1,100 @@
+package ports
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"network-discovery/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Port scanner backends selectable per request (ScanRequest.PortScanner)
+const (
+	BackendAuto = "auto" // nmap when installed, the built-in TCP scanner otherwise
+	BackendNmap = "nmap"
+	BackendTCP  = "tcp"
+)
+
+// Backend detects the open ports of a single host. A backend is built per request by
+// NewBackend and is only read afterwards, so ScanHost may be called concurrently.
+type Backend interface {
+	// Name identifies the backend in logs and scan info
+	Name() string
+	// ScanHost returns the open ports of ip, stopping when ctx is cancelled
+	ScanHost(ctx context.Context, ip string) ([]models.PortInfo, error)
+	// ApplySpec configures ports, protocols and timing; call it before the first scan
+	ApplySpec(spec *Spec)
+}
+
+// DetailBackend is a Backend that reports more about a host than its open ports:
+// addresses, hostnames, service versions and OS matches (nmap)
+type DetailBackend interface {
+	Backend
+	ScanHostDetails(ctx context.Context, ip string) (*HostResult, error)
+}
+
+// ScanHostDetails scans ip with backend, returning everything the backend found out
+// about the host; for plain backends that is its open ports
+func ScanHostDetails(ctx context.Context, backend Backend, ip string) (*HostResult, error) {
+	if detailed, ok := backend.(DetailBackend); ok {
+		return detailed.ScanHostDetails(ctx, ip)
+	}
+	openPorts, err := backend.ScanHost(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	return &HostResult{IP: ip, Up: len(openPorts) > 0, Ports: openPorts}, nil
+}
+
+// ValidateBackend reports whether name is a known backend; empty selects auto
+func ValidateBackend(name string) error {
+	switch strings.ToLower(name) {
+	case "", BackendAuto, BackendNmap, BackendTCP:
+		return nil
+	default:
+		return fmt.Errorf("unsupported port scanner %q (supported: %s, %s, %s)", name, BackendAuto, BackendNmap, BackendTCP)
+	}
+}
+
+// NewBackend creates the named port scanner backend configured with spec (nil keeps
+// the backend defaults). "auto" (or empty) picks nmap when it is on PATH and falls
+// back to the built-in TCP connect scanner.
+func NewBackend(name string, spec *Spec, maxWorkers int, logger *logrus.Logger) (Backend, error) {
+	if err := ValidateBackend(name); err != nil {
+		return nil, err
+	}
+
+	var backend Backend
+	switch strings.ToLower(name) {
+	case BackendNmap:
+		backend = NewScannerWithLogger(maxWorkers, logger)
+	case BackendTCP:
+		backend = NewTCPScannerWithLogger(logger)
+	default:
+		backend = DefaultBackend(maxWorkers, logger)
+	}
+
+	if spec != nil {
+		backend.ApplySpec(spec)
+	}
+	return backend, nil
+}
+
+// DefaultBackend returns the nmap backend when nmap is installed and the built-in
+// TCP connect scanner otherwise
+func DefaultBackend(maxWorkers int, logger *logrus.Logger) Backend {
+	if NmapAvailable() {
+		return NewScannerWithLogger(maxWorkers, logger)
+	}
+	logger.Debugf("nmap not found in PATH, using built-in TCP port scanner")
+	return NewTCPScannerWithLogger(logger)
+}
+
+// NmapAvailable reports whether the nmap binary can be found in PATH
+func NmapAvailable() bool {
+	_, err := exec.LookPath("nmap")
+	return err == nil
+}
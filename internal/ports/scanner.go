@@ -5,8 +5,11 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
+	"os"
 	"os/exec"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"network-discovery/internal/models"
@@ -18,6 +21,7 @@ import (
 type Scanner struct {
 	MaxWorkers     int
 	TimeoutPerHost time.Duration
+	Spec           *Spec // nil scans nmap's default top-1000 TCP ports with -T4
 	logger         *logrus.Logger
 }
 
@@ -26,7 +30,7 @@ func NewScanner(maxWorkers int) *Scanner {
 	logger.SetLevel(logrus.InfoLevel)
 	return &Scanner{
 		MaxWorkers:     maxWorkers,
-		TimeoutPerHost: 30 * time.Second,
+		TimeoutPerHost: DefaultHostTimeout,
 		logger:         logger,
 	}
 }
@@ -34,20 +38,59 @@ func NewScanner(maxWorkers int) *Scanner {
 func NewScannerWithLogger(maxWorkers int, logger *logrus.Logger) *Scanner {
 	return &Scanner{
 		MaxWorkers:     maxWorkers,
-		TimeoutPerHost: 30 * time.Second,
+		TimeoutPerHost: DefaultHostTimeout,
 		logger:         logger,
 	}
 }
 
-// ScanHost runs nmap for a single IP and returns open ports
-func (s *Scanner) ScanHost(ip string) ([]models.PortInfo, error) {
+// ApplySpec configures ports, protocols, timing and detection from a port scan spec
+func (s *Scanner) ApplySpec(spec *Spec) {
+	if spec == nil {
+		return
+	}
+	s.Spec = spec
+	s.TimeoutPerHost = spec.HostTimeout
+	if spec.OSDetection && os.Geteuid() != 0 {
+		s.logger.Warnf("nmap OS detection needs root, scanning without -O")
+	}
+}
+
+// Name implements Backend
+func (s *Scanner) Name() string {
+	return BackendNmap
+}
+
+// ScanHost runs nmap for a single IP and returns open ports. The nmap process is
+// killed when ctx is cancelled or the per-host timeout expires.
+func (s *Scanner) ScanHost(ctx context.Context, ip string) ([]models.PortInfo, error) {
+	host, err := s.ScanHostDetails(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	return host.Ports, nil
+}
+
+// ScanHostDetails runs nmap for a single IP and returns everything it reported: open
+// ports, with service versions when ServiceDetection is set, and OS matches when
+// OSDetection is set.
+func (s *Scanner) ScanHostDetails(ctx context.Context, ip string) (*HostResult, error) {
 	if ip == "" {
 		return nil, fmt.Errorf("empty ip")
 	}
 
-	// Build command: fast, no DNS, open ports only, XML to stdout
-	args := []string{"-Pn", "-T4", "-n", "--open", "-oX", "-", ip}
-	ctx, cancel := context.WithTimeout(context.Background(), s.TimeoutPerHost)
+	// Build command: no DNS, open ports only, XML to stdout
+	args := []string{"-Pn", "-n", "--open", "-oX", "-"}
+	if s.Spec != nil {
+		args = append(args, s.Spec.nmapArgs()...)
+	} else {
+		args = append(args, "-T4")
+	}
+	// nmap needs -6 for IPv6 targets, including zoned link-local ones (fe80::1%eth0)
+	if strings.Contains(ip, ":") {
+		args = append(args, "-6")
+	}
+	args = append(args, ip)
+	ctx, cancel := context.WithTimeout(ctx, s.TimeoutPerHost)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "nmap", args...)
@@ -60,21 +103,55 @@ func (s *Scanner) ScanHost(ip string) ([]models.PortInfo, error) {
 		return nil, fmt.Errorf("nmap run failed: %v, stderr: %s", err, stderr.String())
 	}
 
-	ports, err := parseNmapXML(stdout.Bytes())
+	hosts, err := ParseNmapXML(stdout.Bytes())
 	if err != nil {
 		return nil, fmt.Errorf("parse nmap xml failed: %v", err)
 	}
-	return ports, nil
+	if len(hosts) == 0 {
+		return &HostResult{IP: ip}, nil
+	}
+	// Report the target as given; nmap drops the zone of link-local addresses. With -Pn
+	// nmap reports every host up, so only open ports show that it is.
+	hosts[0].IP = ip
+	hosts[0].Up = len(hosts[0].Ports) > 0
+	return &hosts[0], nil
+}
+
+// HostResult is what nmap reported about one host
+type HostResult struct {
+	IP         string
+	MACAddress string
+	Vendor     string // nmap's vendor of the MAC address
+	Hostnames  []string
+	Up         bool      // nmap found the host up; scans require an open port
+	Seen       time.Time // When nmap finished the host, zero when not reported
+	Ports      []models.PortInfo
+	OSMatches  []models.OSMatch // Most accurate first
 }
 
-// Minimal XML structures to extract open ports
+// XML structures of the nmap report (-oX)
 type nmapRun struct {
 	XMLName xml.Name   `xml:"nmaprun"`
 	Hosts   []nmapHost `xml:"host"`
 }
 
 type nmapHost struct {
-	Ports nmapPorts `xml:"ports"`
+	EndTime   string         `xml:"endtime,attr"`
+	Status    nmapState      `xml:"status"`
+	Addresses []nmapAddress  `xml:"address"`
+	Hostnames []nmapHostname `xml:"hostnames>hostname"`
+	Ports     nmapPorts      `xml:"ports"`
+	OSMatches []nmapOSMatch  `xml:"os>osmatch"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"` // "ipv4", "ipv6" or "mac"
+	Vendor   string `xml:"vendor,attr"`
+}
+
+type nmapHostname struct {
+	Name string `xml:"name,attr"`
 }
 
 type nmapPorts struct {
@@ -93,28 +170,113 @@ type nmapState struct {
 }
 
 type nmapService struct {
-	Name string `xml:"name,attr"`
+	Name      string   `xml:"name,attr"`
+	Product   string   `xml:"product,attr"`
+	Version   string   `xml:"version,attr"`
+	ExtraInfo string   `xml:"extrainfo,attr"`
+	Tunnel    string   `xml:"tunnel,attr"`
+	CPE       []string `xml:"cpe"`
+}
+
+type nmapOSMatch struct {
+	Name     string        `xml:"name,attr"`
+	Accuracy int           `xml:"accuracy,attr"`
+	Classes  []nmapOSClass `xml:"osclass"`
 }
 
-func parseNmapXML(data []byte) ([]models.PortInfo, error) {
+type nmapOSClass struct {
+	Type     string   `xml:"type,attr"`
+	Vendor   string   `xml:"vendor,attr"`
+	Family   string   `xml:"osfamily,attr"`
+	Gen      string   `xml:"osgen,attr"`
+	Accuracy int      `xml:"accuracy,attr"`
+	CPE      []string `xml:"cpe"`
+}
+
+// ParseNmapXML reads an nmap XML report (nmap -oX), as produced by the nmap backend or
+// uploaded from a scan run elsewhere. Every host is returned, with its open ports only.
+func ParseNmapXML(data []byte) ([]HostResult, error) {
 	var run nmapRun
 	if err := xml.Unmarshal(data, &run); err != nil {
 		return nil, err
 	}
-	var result []models.PortInfo
+
+	result := make([]HostResult, 0, len(run.Hosts))
 	for _, h := range run.Hosts {
+		host := HostResult{Up: h.Status.State == "up"}
+		for _, addr := range h.Addresses {
+			switch addr.AddrType {
+			case "mac":
+				host.MACAddress = strings.ToUpper(addr.Addr)
+				host.Vendor = addr.Vendor
+			case "ipv4", "ipv6":
+				if host.IP == "" {
+					host.IP = addr.Addr
+				}
+			}
+		}
+		for _, name := range h.Hostnames {
+			if name.Name != "" {
+				host.Hostnames = append(host.Hostnames, name.Name)
+			}
+		}
+		if end, err := strconv.ParseInt(h.EndTime, 10, 64); err == nil && end > 0 {
+			host.Seen = time.Unix(end, 0)
+		}
+
 		for _, p := range h.Ports.Ports {
 			if p.State.State != "open" {
 				continue
 			}
 			portNum, _ := strconv.Atoi(p.PortID)
-			result = append(result, models.PortInfo{
-				Port:     portNum,
-				Protocol: p.Protocol,
-				Service:  p.Service.Name,
-				State:    p.State.State,
+			host.Ports = append(host.Ports, models.PortInfo{
+				Port:      portNum,
+				Protocol:  p.Protocol,
+				Service:   p.Service.Name,
+				State:     p.State.State,
+				Product:   p.Service.Product,
+				Version:   p.Service.Version,
+				ExtraInfo: p.Service.ExtraInfo,
+				Tunnel:    p.Service.Tunnel,
+				CPE:       p.Service.CPE,
 			})
 		}
+
+		for _, m := range h.OSMatches {
+			match := models.OSMatch{Name: m.Name, Accuracy: m.Accuracy}
+			// The most accurate class describes the match
+			if len(m.Classes) > 0 {
+				class := m.Classes[0]
+				for _, c := range m.Classes[1:] {
+					if c.Accuracy > class.Accuracy {
+						class = c
+					}
+				}
+				match.Vendor, match.Family, match.Generation, match.Type = class.Vendor, class.Family, class.Gen, class.Type
+			}
+			for _, c := range m.Classes {
+				for _, cpe := range c.CPE {
+					if !containsString(match.CPE, cpe) {
+						match.CPE = append(match.CPE, cpe)
+					}
+				}
+			}
+			host.OSMatches = append(host.OSMatches, match)
+		}
+		sort.SliceStable(host.OSMatches, func(a, b int) bool {
+			return host.OSMatches[a].Accuracy > host.OSMatches[b].Accuracy
+		})
+
+		result = append(result, host)
 	}
 	return result, nil
 }
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package ports
+
+import "sort"
+
+// tcpServices maps well-known TCP ports to nmap-style service names
+var tcpServices = map[int]string{
+	20:    "ftp-data",
+	21:    "ftp",
+	22:    "ssh",
+	23:    "telnet",
+	25:    "smtp",
+	53:    "domain",
+	80:    "http",
+	81:    "hosts2-ns",
+	88:    "kerberos-sec",
+	110:   "pop3",
+	111:   "rpcbind",
+	113:   "ident",
+	119:   "nntp",
+	135:   "msrpc",
+	139:   "netbios-ssn",
+	143:   "imap",
+	179:   "bgp",
+	389:   "ldap",
+	427:   "svrloc",
+	443:   "https",
+	445:   "microsoft-ds",
+	465:   "smtps",
+	513:   "login",
+	514:   "shell",
+	515:   "printer",
+	548:   "afp",
+	554:   "rtsp",
+	587:   "submission",
+	631:   "ipp",
+	636:   "ldapssl",
+	646:   "ldp",
+	873:   "rsync",
+	902:   "iss-realsecure",
+	993:   "imaps",
+	995:   "pop3s",
+	1080:  "socks",
+	1433:  "ms-sql-s",
+	1521:  "oracle",
+	1723:  "pptp",
+	1883:  "mqtt",
+	1900:  "upnp",
+	2000:  "cisco-sccp",
+	2049:  "nfs",
+	2082:  "infowave",
+	2083:  "radsec",
+	2181:  "eforward",
+	2375:  "docker",
+	2376:  "docker-s",
+	3000:  "ppp",
+	3128:  "squid-http",
+	3268:  "globalcatLDAP",
+	3306:  "mysql",
+	3389:  "ms-wbt-server",
+	5000:  "upnp",
+	5060:  "sip",
+	5061:  "sip-tls",
+	5432:  "postgresql",
+	5672:  "amqp",
+	5900:  "vnc",
+	5985:  "wsman",
+	5986:  "wsmans",
+	6379:  "redis",
+	6443:  "sun-sr-https",
+	6667:  "irc",
+	8000:  "http-alt",
+	8008:  "http",
+	8080:  "http-proxy",
+	8081:  "blackice-icecap",
+	8443:  "https-alt",
+	8888:  "sun-answerbook",
+	9000:  "cslistener",
+	9090:  "zeus-admin",
+	9100:  "jetdirect",
+	9200:  "wap-wsp",
+	9443:  "tungsten-https",
+	10000: "snet-sensor-mgmt",
+	11211: "memcache",
+	27017: "mongod",
+
+	// IoT and industrial protocols used by the built-in profiles
+	102:   "iso-tsap",
+	502:   "mbap",
+	830:   "netconf-ssh",
+	1911:  "mtp",
+	2404:  "iec-104",
+	4840:  "opcua-tcp",
+	4911:  "niagara-fox",
+	8883:  "secure-mqtt",
+	9600:  "omron-fins",
+	20000: "dnp",
+	44818: "EtherNetIP-2",
+}
+
+// udpServices maps well-known UDP ports to nmap-style service names
+var udpServices = map[int]string{
+	53:    "domain",
+	67:    "dhcps",
+	68:    "dhcpc",
+	69:    "tftp",
+	111:   "rpcbind",
+	123:   "ntp",
+	137:   "netbios-ns",
+	138:   "netbios-dgm",
+	161:   "snmp",
+	162:   "snmptrap",
+	500:   "isakmp",
+	514:   "syslog",
+	520:   "route",
+	623:   "asf-rmcp",
+	631:   "ipp",
+	1434:  "ms-sql-m",
+	1701:  "L2TP",
+	1812:  "radius",
+	1900:  "upnp",
+	2222:  "EtherNet-IP-1",
+	4500:  "nat-t-ike",
+	5353:  "zeroconf",
+	5683:  "coap",
+	44818: "EtherNetIP-2",
+	47808: "bacnet",
+}
+
+// ServiceName returns the well-known service name for a TCP port, or "" when unknown
+func ServiceName(port int) string {
+	return tcpServices[port]
+}
+
+// UDPServiceName returns the well-known service name for a UDP port, or "" when unknown
+func UDPServiceName(port int) string {
+	return udpServices[port]
+}
+
+// topTCPPorts lists TCP ports by how often they are found open (nmap-services order)
+var topTCPPorts = []int{
+	80, 23, 443, 21, 22, 25, 3389, 110, 445, 139, 143, 53, 135, 3306, 8080, 1723, 111, 995, 993, 5900,
+	1025, 587, 8888, 199, 1720, 465, 548, 113, 81, 6001, 10000, 514, 5060, 179, 1026, 2000, 8443, 8000, 32768, 554,
+	26, 1433, 49152, 2001, 515, 8008, 49154, 1027, 5666, 646, 5000, 5631, 631, 49153, 8081, 2049, 88, 79, 5800, 106,
+	2121, 1110, 49155, 6000, 513, 990, 5357, 427, 49156, 543, 544, 5101, 144, 7, 389, 8009, 3128, 444, 9999, 5009,
+	7070, 5190, 3000, 5432, 1900, 3986, 13, 1029, 9, 5051, 6646, 49157, 1028, 873, 1755, 2717, 4899, 9100, 119, 37,
+}
+
+// topUDPPorts lists UDP ports by how often they are found open (nmap-services order)
+var topUDPPorts = []int{
+	631, 161, 137, 123, 138, 1434, 445, 135, 67, 53, 139, 500, 68, 520, 1900, 4500, 514, 49152, 162, 69,
+	5353, 111, 49154, 1701, 998, 996, 997, 999, 3283, 49153, 1812, 136, 2222, 2049, 32768, 5060, 1025, 1433, 3456, 80,
+}
+
+// CommonPorts returns the 100 most frequently open TCP ports in ascending order
+func CommonPorts() []int {
+	ports := append([]int(nil), topTCPPorts...)
+	sort.Ints(ports)
+	return ports
+}
+
+// CommonUDPPorts returns the most frequently open UDP ports in ascending order
+func CommonUDPPorts() []int {
+	ports := append([]int(nil), topUDPPorts...)
+	sort.Ints(ports)
+	return ports
+}
@@ -0,0 +1,350 @@
+package ports
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"network-discovery/internal/models"
+)
+
+// Port protocols accepted in PortScanOptions.Protocol
+const (
+	ProtocolTCP  = "tcp"
+	ProtocolUDP  = "udp"
+	ProtocolBoth = "both"
+)
+
+const (
+	// DefaultTiming matches the -T4 nmap template used before port specs existed
+	DefaultTiming = 4
+	// DefaultHostTimeout bounds the port scan of a single host
+	DefaultHostTimeout = 30 * time.Second
+	// MaxHostTimeout is the largest per-host timeout a request may ask for
+	MaxHostTimeout = 10 * time.Minute
+
+	maxProfileDepth = 4
+)
+
+// Spec is a validated port scan specification. Nil port lists with the protocol
+// enabled mean "backend default" (nmap top-1000, built-in top-100).
+type Spec struct {
+	TCP         bool
+	UDP         bool
+	TCPPorts    []int
+	UDPPorts    []int
+	Timing      int
+	HostTimeout time.Duration
+
+	// nmap only: probe service versions (-sV) and detect the OS (-O)
+	ServiceDetection bool
+	OSDetection      bool
+}
+
+// timingProfile is how the built-in scanner interprets a timing template
+type timingProfile struct {
+	concurrency int
+	dialTimeout time.Duration
+}
+
+var timingProfiles = [...]timingProfile{
+	{concurrency: 1, dialTimeout: 5 * time.Second},
+	{concurrency: 2, dialTimeout: 3 * time.Second},
+	{concurrency: 10, dialTimeout: 2 * time.Second},
+	{concurrency: 50, dialTimeout: 1500 * time.Millisecond},
+	{concurrency: DefaultHostConcurrency, dialTimeout: DefaultDialTimeout},
+	{concurrency: 200, dialTimeout: 500 * time.Millisecond},
+}
+
+// builtinProfiles are the named port lists available without configuration.
+// "T:" and "U:" prefixes restrict the following ports to TCP or UDP.
+var builtinProfiles = map[string]string{
+	"web":        "T:80,81,443,591,3000,5000,8000,8008,8080,8081,8443,8888,9000,9090,9443",
+	"management": "T:22,23,80,443,830,3389,5900,5985,5986,8291,8443,U:161,623",
+	"iot":        "T:23,80,443,554,1883,5683,8080,8883,9100,49152,U:1900,5353,5683",
+	"ics":        "T:102,502,1911,2404,4840,4911,9600,18245,20000,44818,U:161,2222,44818,47808",
+}
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = copyProfiles(builtinProfiles)
+)
+
+// SetProfiles adds or replaces named port profiles (e.g. from config.yaml). Every
+// profile is validated before any of them is applied.
+func SetProfiles(custom map[string]string) error {
+	merged := copyProfiles(builtinProfiles)
+	for name, expr := range custom {
+		merged[strings.ToLower(name)] = expr
+	}
+	for name := range merged {
+		p := &specParser{tcp: true, udp: true, profiles: merged, tcpSet: map[int]bool{}, udpSet: map[int]bool{}}
+		if err := p.add(name, "", 0); err != nil {
+			return fmt.Errorf("invalid port profile %q: %v", name, err)
+		}
+	}
+
+	profilesMu.Lock()
+	profiles = merged
+	profilesMu.Unlock()
+	return nil
+}
+
+// Profiles returns the named port profiles currently available
+func Profiles() map[string]string {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	return copyProfiles(profiles)
+}
+
+// ParseSpec validates port scan options. A nil result means backend defaults.
+//
+// Ports accepts a comma separated list of ports ("22"), ranges ("8000-8100"),
+// "top-N" and profile names, optionally prefixed with "T:" or "U:".
+func ParseSpec(opts *models.PortScanOptions) (*Spec, error) {
+	if opts == nil {
+		return nil, nil
+	}
+
+	spec := &Spec{
+		Timing:           DefaultTiming,
+		HostTimeout:      DefaultHostTimeout,
+		ServiceDetection: opts.ServiceDetection,
+		OSDetection:      opts.OSDetection,
+	}
+
+	switch strings.ToLower(strings.TrimSpace(opts.Protocol)) {
+	case "", ProtocolTCP:
+		spec.TCP = true
+	case ProtocolUDP:
+		spec.UDP = true
+	case ProtocolBoth:
+		spec.TCP, spec.UDP = true, true
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q (supported: %s, %s, %s)", opts.Protocol, ProtocolTCP, ProtocolUDP, ProtocolBoth)
+	}
+
+	if opts.Timing != nil {
+		if *opts.Timing < 0 || *opts.Timing >= len(timingProfiles) {
+			return nil, fmt.Errorf("timing must be between 0 and %d", len(timingProfiles)-1)
+		}
+		spec.Timing = *opts.Timing
+	}
+
+	if opts.HostTimeout != 0 {
+		timeout := time.Duration(opts.HostTimeout) * time.Second
+		if timeout < 0 || timeout > MaxHostTimeout {
+			return nil, fmt.Errorf("host_timeout must be between 1 and %d seconds", int(MaxHostTimeout.Seconds()))
+		}
+		spec.HostTimeout = timeout
+	}
+
+	if strings.TrimSpace(opts.Ports) == "" {
+		return spec, nil
+	}
+
+	p := &specParser{tcp: spec.TCP, udp: spec.UDP, profiles: Profiles(), tcpSet: map[int]bool{}, udpSet: map[int]bool{}}
+	if err := p.add(opts.Ports, "", 0); err != nil {
+		return nil, err
+	}
+	spec.TCPPorts = sortedPorts(p.tcpSet)
+	spec.UDPPorts = sortedPorts(p.udpSet)
+	if len(spec.TCPPorts) == 0 && len(spec.UDPPorts) == 0 {
+		return nil, fmt.Errorf("port specification %q selects no %s ports", opts.Ports, describeProtocols(spec))
+	}
+	// Only scan the protocols that actually received ports
+	spec.TCP = len(spec.TCPPorts) > 0
+	spec.UDP = len(spec.UDPPorts) > 0
+
+	return spec, nil
+}
+
+// ValidateOptions checks port scan options without keeping the result
+func ValidateOptions(opts *models.PortScanOptions) error {
+	_, err := ParseSpec(opts)
+	return err
+}
+
+// nmapArgs translates the spec into nmap scan type, port, timing and detection arguments
+func (s *Spec) nmapArgs() []string {
+	args := []string{fmt.Sprintf("-T%d", s.Timing)}
+
+	if s.UDP {
+		args = append(args, "-sU")
+		if s.TCP {
+			// Mixing scan types needs an explicit TCP scan; SYN scans require root
+			if os.Geteuid() == 0 {
+				args = append(args, "-sS")
+			} else {
+				args = append(args, "-sT")
+			}
+		}
+	}
+
+	var parts []string
+	if len(s.TCPPorts) > 0 {
+		parts = append(parts, "T:"+joinPorts(s.TCPPorts))
+	}
+	if len(s.UDPPorts) > 0 {
+		parts = append(parts, "U:"+joinPorts(s.UDPPorts))
+	}
+	if len(parts) > 0 {
+		args = append(args, "-p", strings.Join(parts, ","))
+	}
+
+	if s.ServiceDetection {
+		args = append(args, "-sV")
+	}
+	// nmap refuses to run OS detection without root instead of skipping it
+	if s.OSDetection && os.Geteuid() == 0 {
+		args = append(args, "-O")
+	}
+	return args
+}
+
+// specParser accumulates the ports selected by a port expression
+type specParser struct {
+	tcp, udp bool
+	profiles map[string]string
+	tcpSet   map[int]bool
+	udpSet   map[int]bool
+}
+
+// add parses expr; restrict limits the ports to "tcp" or "udp" when set
+func (p *specParser) add(expr, restrict string, depth int) error {
+	if depth > maxProfileDepth {
+		return fmt.Errorf("port profiles nested too deeply")
+	}
+
+	// A "T:"/"U:" prefix applies to the following items too, as in nmap
+	current := restrict
+	for _, item := range strings.Split(expr, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if len(item) > 2 && item[1] == ':' {
+			switch item[0] {
+			case 'T', 't':
+				current = ProtocolTCP
+			case 'U', 'u':
+				current = ProtocolUDP
+			default:
+				return fmt.Errorf("invalid protocol prefix in %q (use T: or U:)", item)
+			}
+			item = item[2:]
+		}
+		if restrict != "" && current != restrict {
+			continue
+		}
+		itemProto := current
+
+		lower := strings.ToLower(item)
+		switch {
+		case strings.HasPrefix(lower, "top-"):
+			n, err := strconv.Atoi(lower[len("top-"):])
+			if err != nil || n < 1 || n > len(topTCPPorts) {
+				return fmt.Errorf("invalid %q: top-N supports N from 1 to %d", item, len(topTCPPorts))
+			}
+			for _, port := range topTCPPorts[:n] {
+				p.addPort(port, itemProto, ProtocolTCP)
+			}
+			for _, port := range topUDPPorts[:min(n, len(topUDPPorts))] {
+				p.addPort(port, itemProto, ProtocolUDP)
+			}
+		case p.profiles[lower] != "":
+			if err := p.add(p.profiles[lower], itemProto, depth+1); err != nil {
+				return err
+			}
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+			first, err1 := parsePort(bounds[0])
+			last, err2 := parsePort(bounds[1])
+			if err1 != nil || err2 != nil || first > last {
+				return fmt.Errorf("invalid port range %q", item)
+			}
+			for port := first; port <= last; port++ {
+				p.addPort(port, itemProto, "")
+			}
+		default:
+			port, err := parsePort(item)
+			if err != nil {
+				return fmt.Errorf("invalid port or unknown profile %q", item)
+			}
+			p.addPort(port, itemProto, "")
+		}
+	}
+	return nil
+}
+
+// addPort records port for the selected protocols allowed by both the item prefix and only
+func (p *specParser) addPort(port int, prefix, only string) {
+	if p.tcp && prefix != ProtocolUDP && only != ProtocolUDP {
+		p.tcpSet[port] = true
+	}
+	if p.udp && prefix != ProtocolTCP && only != ProtocolTCP {
+		p.udpSet[port] = true
+	}
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return port, nil
+}
+
+func sortedPorts(set map[int]bool) []int {
+	if len(set) == 0 {
+		return nil
+	}
+	ports := make([]int, 0, len(set))
+	for port := range set {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// joinPorts renders ports compactly, collapsing consecutive runs into ranges
+func joinPorts(ports []int) string {
+	var parts []string
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", ports[i], ports[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(ports[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+func describeProtocols(s *Spec) string {
+	switch {
+	case s.TCP && s.UDP:
+		return "TCP or UDP"
+	case s.UDP:
+		return "UDP"
+	default:
+		return "TCP"
+	}
+}
+
+func copyProfiles(src map[string]string) map[string]string {
+	dst := make(map[string]string, len(src))
+	for name, expr := range src {
+		dst[name] = expr
+	}
+	return dst
+}
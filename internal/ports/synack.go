@@ -0,0 +1,201 @@
+package ports
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"network-discovery/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// synACKWait is how long a connect waits for the capture to see its SYN-ACK
+	synACKWait = 100 * time.Millisecond
+	// synACKRetention is how long unclaimed SYN-ACKs are kept
+	synACKRetention = 10 * time.Second
+	// maxSYNACKs bounds the SYN-ACKs kept before old ones are dropped
+	maxSYNACKs = 4096
+
+	protocolNumberTCP = 6
+	tcpFlagSYN        = 0x02
+	tcpFlagRST        = 0x04
+	tcpFlagACK        = 0x10
+)
+
+// synACKCapture records the SYN-ACKs this host receives, so that the TCP connect scan
+// can describe how each open port answered for passive OS fingerprinting. It reads a
+// raw socket, which needs root or CAP_NET_RAW, and is only available on Linux.
+type synACKCapture struct {
+	logger *logrus.Logger
+
+	mu      sync.Mutex
+	entries map[synACKKey]synACKEntry
+}
+
+// synACKKey identifies the connection a SYN-ACK answers
+type synACKKey struct {
+	remote     string
+	remotePort int
+	localPort  int
+}
+
+type synACKEntry struct {
+	signature models.TCPSignature
+	received  time.Time
+}
+
+var (
+	synACKOnce   sync.Once
+	sharedSYNACK *synACKCapture
+)
+
+// sharedSYNACKCapture starts the process-wide SYN-ACK capture on first use. It returns
+// nil when raw sockets are unavailable; ports are then reported without SYN-ACK.
+func sharedSYNACKCapture(logger *logrus.Logger) *synACKCapture {
+	synACKOnce.Do(func() {
+		c := &synACKCapture{
+			logger:  logger,
+			entries: make(map[synACKKey]synACKEntry),
+		}
+		if err := c.start(); err != nil {
+			logger.Infof("Passive TCP fingerprinting unavailable, reporting open ports without SYN-ACK: %v", err)
+			return
+		}
+		sharedSYNACK = c
+	})
+	return sharedSYNACK
+}
+
+// record keeps the signature of a captured IPv4 packet if it is a SYN-ACK
+func (c *synACKCapture) record(packet []byte) {
+	key, signature, ok := parseSYNACK(packet)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxSYNACKs {
+		for k, entry := range c.entries {
+			if now.Sub(entry.received) > synACKRetention {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxSYNACKs {
+			return
+		}
+	}
+	c.entries[key] = synACKEntry{signature: signature, received: now}
+}
+
+// lookup returns the SYN-ACK that opened conn, waiting up to synACKWait for the
+// capture to see it, or nil
+func (c *synACKCapture) lookup(conn net.Conn) *models.TCPSignature {
+	local, ok := conn.LocalAddr().(*net.TCPAddr)
+	remote, ok2 := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok || !ok2 || remote.IP.To4() == nil {
+		return nil
+	}
+	key := synACKKey{remote: remote.IP.To4().String(), remotePort: remote.Port, localPort: local.Port}
+
+	deadline := time.Now().Add(synACKWait)
+	for {
+		c.mu.Lock()
+		entry, found := c.entries[key]
+		delete(c.entries, key)
+		c.mu.Unlock()
+		if found {
+			return &entry.signature
+		}
+		if time.Now().After(deadline) {
+			return nil
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// parseSYNACK reads the IPv4 and TCP headers of a SYN-ACK
+func parseSYNACK(packet []byte) (synACKKey, models.TCPSignature, bool) {
+	if len(packet) < 20 || packet[0]>>4 != 4 || packet[9] != protocolNumberTCP {
+		return synACKKey{}, models.TCPSignature{}, false
+	}
+	headerLen := int(packet[0]&0x0f) * 4
+	if headerLen < 20 || len(packet) < headerLen+20 {
+		return synACKKey{}, models.TCPSignature{}, false
+	}
+	tcp := packet[headerLen:]
+	flags := tcp[13]
+	if flags&(tcpFlagSYN|tcpFlagACK) != tcpFlagSYN|tcpFlagACK || flags&tcpFlagRST != 0 {
+		return synACKKey{}, models.TCPSignature{}, false
+	}
+
+	key := synACKKey{
+		remote:     net.IP(packet[12:16]).String(),
+		remotePort: int(binary.BigEndian.Uint16(tcp[0:2])),
+		localPort:  int(binary.BigEndian.Uint16(tcp[2:4])),
+	}
+	signature := models.TCPSignature{
+		TTL:    int(packet[8]),
+		Window: int(binary.BigEndian.Uint16(tcp[14:16])),
+		DF:     packet[6]&0x40 != 0,
+	}
+
+	dataOffset := int(tcp[12]>>4) * 4
+	if dataOffset > len(tcp) {
+		dataOffset = len(tcp)
+	}
+	if dataOffset > 20 {
+		signature.Options, signature.MSS, signature.WindowScale = parseTCPOptions(tcp[20:dataOffset])
+	}
+	return key, signature, true
+}
+
+// parseTCPOptions lists TCP options in order, in the notation of p0f ("mss,sok,ts,nop,ws"),
+// with the MSS and window scale they carry
+func parseTCPOptions(options []byte) (string, int, int) {
+	var (
+		names       []string
+		mss, wscale int
+	)
+	for i := 0; i < len(options); {
+		kind := options[i]
+		switch kind {
+		case 0:
+			names = append(names, "eol")
+			return strings.Join(names, ","), mss, wscale
+		case 1:
+			names = append(names, "nop")
+			i++
+			continue
+		}
+		if i+1 >= len(options) || options[i+1] < 2 || i+int(options[i+1]) > len(options) {
+			names = append(names, "?")
+			break
+		}
+		value := options[i+2 : i+int(options[i+1])]
+		switch {
+		case kind == 2 && len(value) == 2:
+			names = append(names, "mss")
+			mss = int(binary.BigEndian.Uint16(value))
+		case kind == 3 && len(value) == 1:
+			names = append(names, "ws")
+			wscale = int(value[0])
+		case kind == 4:
+			names = append(names, "sok")
+		case kind == 5:
+			names = append(names, "sack")
+		case kind == 8:
+			names = append(names, "ts")
+		default:
+			names = append(names, fmt.Sprintf("?%d", kind))
+		}
+		i += int(options[i+1])
+	}
+	return strings.Join(names, ","), mss, wscale
+}
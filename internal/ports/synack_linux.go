@@ -0,0 +1,33 @@
+package ports
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// start opens a raw TCP socket, which receives a copy of every TCP segment this host
+// receives, and records the SYN-ACKs among them for the lifetime of the process
+func (c *synACKCapture) start() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP)
+	if err != nil {
+		return fmt.Errorf("failed to open raw TCP socket: %v", err)
+	}
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if err != nil {
+				if errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN) {
+					continue
+				}
+				c.logger.Warnf("SYN-ACK capture stopped: %v", err)
+				syscall.Close(fd)
+				return
+			}
+			c.record(buf[:n])
+		}
+	}()
+	return nil
+}
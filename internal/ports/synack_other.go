@@ -0,0 +1,14 @@
+//go:build !linux
+
+package ports
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// start fails: capturing SYN-ACKs needs raw TCP sockets, which only Linux delivers
+// received segments to
+func (c *synACKCapture) start() error {
+	return fmt.Errorf("capturing SYN-ACKs is not supported on %s", runtime.GOOS)
+}
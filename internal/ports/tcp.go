@@ -0,0 +1,305 @@
+package ports
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"network-discovery/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultHostConcurrency is the number of simultaneous connects per host
+	DefaultHostConcurrency = 100
+	// DefaultDialTimeout is how long a single connect may take before the port counts as filtered
+	DefaultDialTimeout = time.Second
+	// DefaultConnectRate caps connection attempts per second across all TCP scans
+	DefaultConnectRate = 1000
+	// BannerTimeout is how long an open port of a server-first protocol may take to greet
+	BannerTimeout = 500 * time.Millisecond
+)
+
+// bannerPorts are the ports whose services greet first (FTP, SSH, Telnet, SMTP, POP3,
+// IMAP, MySQL, VNC); their greeting is kept as the port banner for fingerprinting
+var bannerPorts = map[int]bool{21: true, 22: true, 23: true, 25: true, 110: true, 143: true, 587: true, 3306: true, 5900: true}
+
+// globalLimiter paces connects process-wide so parallel host scans share one budget
+var globalLimiter = newRateLimiter(DefaultConnectRate)
+
+// maxHostConcurrency caps simultaneous probes per host for every timing template; 0 means no cap
+var maxHostConcurrency atomic.Int32
+
+// SetMaxHostConcurrency caps the simultaneous probes per host (scanning.max_host_probes); <= 0 removes the cap
+func SetMaxHostConcurrency(n int) {
+	if n < 0 {
+		n = 0
+	}
+	maxHostConcurrency.Store(int32(n))
+}
+
+// SetConnectRate changes the process-wide limit of TCP connects per second; <= 0 disables it
+func SetConnectRate(perSecond int) {
+	globalLimiter.setRate(perSecond)
+}
+
+// TCPScanner detects open ports with plain TCP connects (and UDP probes when
+// UDPPorts is set), without external tools
+type TCPScanner struct {
+	Ports          []int
+	UDPPorts       []int
+	Concurrency    int
+	DialTimeout    time.Duration
+	TimeoutPerHost time.Duration
+	logger         *logrus.Logger
+}
+
+func NewTCPScanner() *TCPScanner {
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+	return NewTCPScannerWithLogger(logger)
+}
+
+func NewTCPScannerWithLogger(logger *logrus.Logger) *TCPScanner {
+	return &TCPScanner{
+		Ports:          CommonPorts(),
+		Concurrency:    DefaultHostConcurrency,
+		DialTimeout:    DefaultDialTimeout,
+		TimeoutPerHost: DefaultHostTimeout,
+		logger:         logger,
+	}
+}
+
+// ApplySpec configures ports, protocols and timing from a port scan spec
+func (s *TCPScanner) ApplySpec(spec *Spec) {
+	if spec == nil {
+		return
+	}
+
+	s.Ports, s.UDPPorts = nil, nil
+	if spec.TCP {
+		s.Ports = spec.TCPPorts
+		if s.Ports == nil {
+			s.Ports = CommonPorts()
+		}
+	}
+	if spec.UDP {
+		s.UDPPorts = spec.UDPPorts
+		if s.UDPPorts == nil {
+			s.UDPPorts = CommonUDPPorts()
+		}
+	}
+
+	timing := timingProfiles[spec.Timing]
+	s.Concurrency = timing.concurrency
+	s.DialTimeout = timing.dialTimeout
+	s.TimeoutPerHost = spec.HostTimeout
+
+	if spec.ServiceDetection || spec.OSDetection {
+		s.logger.Warnf("Service and OS detection need the nmap port scanner; the built-in TCP scanner only reads banners")
+	}
+}
+
+// Name implements Backend
+func (s *TCPScanner) Name() string {
+	return BackendTCP
+}
+
+// probe is one port to check on a host
+type probe struct {
+	port     int
+	protocol string
+}
+
+// ScanHost probes every configured port of ip and returns the ones that answer.
+// Ports found before the per-host timeout are returned; cancelling ctx returns ctx.Err().
+func (s *TCPScanner) ScanHost(ctx context.Context, ip string) ([]models.PortInfo, error) {
+	if ip == "" {
+		return nil, errors.New("empty ip")
+	}
+
+	hostCtx, cancel := context.WithTimeout(ctx, s.TimeoutPerHost)
+	defer cancel()
+
+	probes := make([]probe, 0, len(s.Ports)+len(s.UDPPorts))
+	for _, port := range s.Ports {
+		probes = append(probes, probe{port: port, protocol: ProtocolTCP})
+	}
+	for _, port := range s.UDPPorts {
+		probes = append(probes, probe{port: port, protocol: ProtocolUDP})
+	}
+
+	// SYN-ACKs of the open ports, for passive OS fingerprinting
+	synACKs := sharedSYNACKCapture(s.logger)
+
+	probeChan := make(chan probe)
+	var (
+		mu   sync.Mutex
+		open []models.PortInfo
+		wg   sync.WaitGroup
+	)
+
+	workers := s.Concurrency
+	if workers <= 0 {
+		workers = DefaultHostConcurrency
+	}
+	if limit := int(maxHostConcurrency.Load()); limit > 0 && workers > limit {
+		workers = limit
+	}
+	if workers > len(probes) {
+		workers = len(probes)
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range probeChan {
+				if globalLimiter.wait(hostCtx) != nil {
+					continue
+				}
+
+				var info models.PortInfo
+				switch p.protocol {
+				case ProtocolUDP:
+					if !s.probeUDP(hostCtx, ip, p.port) {
+						continue
+					}
+					info = models.PortInfo{Port: p.port, Protocol: ProtocolUDP, Service: UDPServiceName(p.port), State: "open"}
+				default:
+					var ok bool
+					if info, ok = s.probeTCP(hostCtx, ip, p.port, synACKs); !ok {
+						continue
+					}
+				}
+
+				mu.Lock()
+				open = append(open, info)
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, p := range probes {
+		select {
+		case probeChan <- p:
+		case <-hostCtx.Done():
+			break dispatch
+		}
+	}
+	close(probeChan)
+	wg.Wait()
+
+	sort.Slice(open, func(a, b int) bool {
+		if open[a].Protocol != open[b].Protocol {
+			return open[a].Protocol < open[b].Protocol
+		}
+		return open[a].Port < open[b].Port
+	})
+
+	if err := ctx.Err(); err != nil {
+		return open, err
+	}
+	if hostCtx.Err() != nil {
+		s.logger.Debugf("Port scan of %s hit the %v host timeout", ip, s.TimeoutPerHost)
+	}
+	return open, nil
+}
+
+// probeTCP reports whether a TCP connect to ip:port succeeds, describing the open port
+// with its SYN-ACK when synACKs captures them and the banner of server-first services
+func (s *TCPScanner) probeTCP(ctx context.Context, ip string, port int, synACKs *synACKCapture) (models.PortInfo, bool) {
+	dialer := net.Dialer{Timeout: s.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
+	if err != nil {
+		return models.PortInfo{}, false
+	}
+	defer conn.Close()
+
+	info := models.PortInfo{Port: port, Protocol: ProtocolTCP, Service: ServiceName(port), State: "open"}
+	if synACKs != nil {
+		info.SYNACK = synACKs.lookup(conn)
+	}
+	if bannerPorts[port] {
+		info.Banner = readBanner(conn)
+	}
+	return info, true
+}
+
+// readBanner returns the first printable text a service sends after connecting, e.g.
+// the first line of an SSH greeting or the server version of a MySQL handshake, or ""
+// when it stays silent for BannerTimeout
+func readBanner(conn net.Conn) string {
+	conn.SetReadDeadline(time.Now().Add(BannerTimeout))
+	buf := make([]byte, 256)
+	n, _ := conn.Read(buf)
+	texts := strings.FieldsFunc(string(buf[:n]), func(r rune) bool {
+		return r < 0x20 || r > 0x7e
+	})
+	for _, text := range texts {
+		if text = strings.TrimSpace(text); len(text) >= 3 {
+			return text
+		}
+	}
+	return ""
+}
+
+// rateLimiter hands out evenly spaced connect slots without holding its lock while waiting
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond int) *rateLimiter {
+	l := &rateLimiter{}
+	l.setRate(perSecond)
+	return l
+}
+
+func (l *rateLimiter) setRate(perSecond int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if perSecond <= 0 {
+		l.interval = 0
+		return
+	}
+	l.interval = time.Second / time.Duration(perSecond)
+}
+
+// wait blocks until the caller's slot comes up or ctx is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	if l.interval == 0 {
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
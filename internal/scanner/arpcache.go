@@ -0,0 +1,69 @@
+package scanner
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	"network-discovery/internal/models"
+)
+
+// maxCachedIPsPerMAC bounds the addresses one MAC may answer for in a single ARP
+// cache. Beyond it the MAC belongs to a router doing proxy ARP, not to a host, and
+// its entries would merge unrelated hosts.
+const maxCachedIPsPerMAC = 4
+
+// harvestARPCaches resolves the MAC of devices without one from the ARP caches the
+// SNMP devices reported, which cover hosts in subnets behind routers. With addHosts,
+// it returns devices for the cached hosts of networkRange that no scan found.
+func harvestARPCaches(snmpDevices, arpDevices []*models.Device, networkRange string, addHosts bool) []*models.Device {
+	cached := make(map[string]models.ARPEntry)
+	for _, router := range snmpDevices {
+		perMAC := make(map[string]int)
+		for _, entry := range router.ARPCache {
+			perMAC[entry.MACAddress]++
+		}
+		for _, entry := range router.ARPCache {
+			if perMAC[entry.MACAddress] > maxCachedIPsPerMAC {
+				continue
+			}
+			if _, ok := cached[entry.IP]; !ok {
+				cached[entry.IP] = entry
+			}
+		}
+	}
+	if len(cached) == 0 {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	for _, devices := range [][]*models.Device{snmpDevices, arpDevices} {
+		for _, device := range devices {
+			known[device.IP] = true
+			if entry, ok := cached[device.IP]; ok && device.MACAddress == "" {
+				device.MACAddress = entry.MACAddress
+			}
+		}
+	}
+
+	_, ipNet, err := net.ParseCIDR(networkRange)
+	if !addHosts || err != nil {
+		return nil
+	}
+	var hosts []*models.Device
+	for ip, entry := range cached {
+		if known[ip] || !ipNet.Contains(net.ParseIP(ip)) {
+			continue
+		}
+		hosts = append(hosts, &models.Device{
+			IP:         ip,
+			MACAddress: entry.MACAddress,
+			LastSeen:   time.Now(),
+			ScanMethod: "ARP_CACHE",
+		})
+	}
+	sort.Slice(hosts, func(a, b int) bool {
+		return lessAddr(hosts[a].IP, hosts[b].IP)
+	})
+	return hosts
+}
@@ -2,26 +2,51 @@
 package scanner
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"strings"
 	"sync"
 	"time"
 
 	"network-discovery/internal/arp"
+	"network-discovery/internal/events"
+	"network-discovery/internal/fingerprint"
+	"network-discovery/internal/limits"
 	"network-discovery/internal/models"
+	"network-discovery/internal/pkg/utils"
 	"network-discovery/internal/ports"
 	"network-discovery/internal/snmp"
 
 	"github.com/sirupsen/logrus"
 )
 
+// Options are the per-request settings of a scan. They are passed by value and never stored
+// on the FullScanner, so concurrent scans with different options do not interfere.
+type Options struct {
+	SNMP           snmp.Options
+	EnablePortScan bool
+	// PortScanner enriches devices with open ports; nil uses the scanner's default backend
+	PortScanner ports.Backend
+	// IncludeIPv6 also runs neighbour discovery on the links of an IPv4 range.
+	// IPv6 ranges always use it.
+	IncludeIPv6 bool
+	// ARPCacheHosts adds the hosts of the range that only appear in the ARP caches of
+	// SNMP devices. Their MACs fill in scanned devices either way.
+	ARPCacheHosts bool
+}
+
 type FullScanner struct {
-	snmpScanner    *snmp.Scanner
-	arpScanner     *arp.Scanner
-	portScanner    *ports.Scanner
-	vendorMgr      *arp.VendorManager
-	logger         *logrus.Logger
-	maxWorkers     int
-	enablePortScan bool
+	snmpScanner *snmp.Scanner
+	arpScanner  *arp.Scanner
+	portScanner ports.Backend
+	vendorMgr   *arp.VendorManager
+	logger      *logrus.Logger
+	maxWorkers  int
+
+	// Identifies vendor, model, OS and version once ports and vendors are known; nil
+	// when fingerprinting is disabled
+	fingerprints *fingerprint.Engine
 }
 
 func NewFullScanner(snmpClient *snmp.Client, maxWorkers int) *FullScanner {
@@ -29,35 +54,60 @@ func NewFullScanner(snmpClient *snmp.Client, maxWorkers int) *FullScanner {
 	logger.SetLevel(logrus.InfoLevel)
 
 	return &FullScanner{
-		snmpScanner:    snmp.NewScanner(snmpClient, maxWorkers),
-		arpScanner:     arp.NewScanner(maxWorkers),
-		portScanner:    ports.NewScanner(maxWorkers),
-		vendorMgr:      arp.NewVendorManager("", logger),
-		logger:         logger,
-		maxWorkers:     maxWorkers,
-		enablePortScan: true,
+		snmpScanner: snmp.NewScanner(snmpClient, maxWorkers),
+		arpScanner:  arp.NewScanner(maxWorkers),
+		portScanner: ports.DefaultBackend(maxWorkers, logger),
+		vendorMgr:   arp.NewVendorManager("", logger),
+		logger:      logger,
+		maxWorkers:  maxWorkers,
 	}
 }
 
 func NewFullScannerWithLogger(snmpClient *snmp.Client, maxWorkers int, logger *logrus.Logger) *FullScanner {
 	return &FullScanner{
-		snmpScanner:    snmp.NewScannerWithLogger(snmpClient, maxWorkers, logger),
-		arpScanner:     arp.NewScannerWithLogger(maxWorkers, logger),
-		portScanner:    ports.NewScannerWithLogger(maxWorkers, logger),
-		vendorMgr:      arp.NewVendorManager("", logger),
-		logger:         logger,
-		maxWorkers:     maxWorkers,
-		enablePortScan: true,
+		snmpScanner: snmp.NewScannerWithLogger(snmpClient, maxWorkers, logger),
+		arpScanner:  arp.NewScannerWithLogger(maxWorkers, logger),
+		portScanner: ports.DefaultBackend(maxWorkers, logger),
+		vendorMgr:   arp.NewVendorManager("", logger),
+		logger:      logger,
+		maxWorkers:  maxWorkers,
+	}
+}
+
+// NewFullScannerWithConfig creates a full scanner that loads the OUI vendor database from vendorConfigPath
+func NewFullScannerWithConfig(snmpClient *snmp.Client, maxWorkers int, logger *logrus.Logger, vendorConfigPath string) *FullScanner {
+	return &FullScanner{
+		snmpScanner: snmp.NewScannerWithLogger(snmpClient, maxWorkers, logger),
+		arpScanner:  arp.NewScannerWithConfig(maxWorkers, logger, vendorConfigPath),
+		portScanner: ports.DefaultBackend(maxWorkers, logger),
+		vendorMgr:   arp.NewVendorManager(vendorConfigPath, logger),
+		logger:      logger,
+		maxWorkers:  maxWorkers,
 	}
 }
 
-// SetPortScanEnabled enables/disables port scanning enrichment
-func (fs *FullScanner) SetPortScanEnabled(enabled bool) {
-	fs.enablePortScan = enabled
+// PortScanner returns the default backend used when Options.PortScanner is nil
+func (fs *FullScanner) PortScanner() ports.Backend {
+	return fs.portScanner
+}
+
+// SetFingerprints sets the fingerprint engine run on every scanned device; nil disables
+// fingerprinting. It must be called before scans start.
+func (fs *FullScanner) SetFingerprints(engine *fingerprint.Engine) {
+	fs.fingerprints = engine
+}
+
+// SetActiveARPEnabled enables/disables raw ARP requests during the ARP sweep.
+// It is a service-wide setting and must be called before scans start.
+func (fs *FullScanner) SetActiveARPEnabled(enabled bool) {
+	fs.arpScanner.SetActiveARP(enabled)
 }
 
-// PerformFullScan performs both SNMP and ARP scans and merges the results
-func (fs *FullScanner) PerformFullScan(networkRange string, communities []string) (*models.NetworkTopology, error) {
+// PerformFullScan performs both SNMP and ARP scans and merges the results. IPv6 ranges
+// (and IPv4 ranges with Options.IncludeIPv6) use neighbour discovery, and the IPv6 hosts
+// it finds are queried over SNMP.
+// If ctx is cancelled the devices found so far are returned in a topology marked as cancelled.
+func (fs *FullScanner) PerformFullScan(ctx context.Context, networkRange string, opts Options) (*models.NetworkTopology, error) {
 	start := time.Now()
 	fs.logger.Infof("Starting full scan (SNMP + ARP) for range: %s", networkRange)
 
@@ -74,8 +124,8 @@ func (fs *FullScanner) PerformFullScan(networkRange string, communities []string
 		defer wg.Done()
 		fs.logger.Info("Starting SNMP scan...")
 
-		topology, err := fs.snmpScanner.ScanNetwork(networkRange, communities)
-		if err != nil {
+		topology, err := fs.scanSNMPRange(ctx, networkRange, opts)
+		if topology == nil {
 			fs.logger.Errorf("SNMP scan failed: %v", err)
 			errorChan <- fmt.Errorf("SNMP scan failed: %v", err)
 			snmpChan <- []*models.Device{}
@@ -99,8 +149,8 @@ func (fs *FullScanner) PerformFullScan(networkRange string, communities []string
 		defer wg.Done()
 		fs.logger.Info("Starting ARP scan...")
 
-		devices, err := fs.arpScanner.ScanNetwork(networkRange)
-		if err != nil {
+		devices, err := fs.scanNeighbors(ctx, networkRange, opts)
+		if err != nil && ctx.Err() == nil {
 			fs.logger.Errorf("ARP scan failed: %v", err)
 			errorChan <- fmt.Errorf("ARP scan failed: %v", err)
 			arpChan <- []*models.Device{}
@@ -146,40 +196,59 @@ func (fs *FullScanner) PerformFullScan(networkRange string, communities []string
 		}
 	}
 
+	// Query the IPv6 neighbours the SNMP sweep could not enumerate
+	snmpDevices = append(snmpDevices, fs.scanSNMPNeighbors(ctx, networkRange, arpDevices, opts)...)
+
+	// Resolve MACs of hosts behind routers from their ARP caches
+	arpDevices = append(arpDevices, harvestARPCaches(snmpDevices, arpDevices, networkRange, opts.ARPCacheHosts)...)
+
 	// Merge results
-	mergedDevices := fs.mergeDevices(snmpDevices, arpDevices)
+	mergedDevices := fs.mergeDevices(ctx, snmpDevices, arpDevices)
+	events.PhaseComplete(ctx, models.PhaseMerge, len(snmpDevices)+len(arpDevices), len(mergedDevices))
 
 	// Enrich with open ports (best-effort)
-	fs.addOpenPorts(mergedDevices)
+	fs.addOpenPorts(ctx, mergedDevices, opts)
+	// Connect devices by their LLDP/CDP neighbours, adding the neighbours not scanned
+	mergedDevices, links := BuildLinks(mergedDevices)
+	// Locate devices on the switch ports that learned their MAC
+	MapSwitchPorts(mergedDevices, links)
 	// Enrich vendors based on MAC
 	fs.addVendors(mergedDevices)
+	// Identify vendor, model, OS, version and device type from all that is known
+	fs.addFingerprints(mergedDevices)
 
 	scanDuration := time.Since(start)
 
 	// Count different types of devices
 	snmpCount := 0
 	arpOnlyCount := 0
+	reachableCount := 0
 	for _, device := range mergedDevices {
+		if device.IsReachable {
+			reachableCount++
+		}
 		if device.ScanMethod == "SNMP" || device.ScanMethod == "COMBINED" {
 			snmpCount++
 		}
-		if device.ScanMethod == "ARP" {
+		if device.ScanMethod == "ARP" || device.ScanMethod == "NDP" {
 			arpOnlyCount++
 		}
 	}
 
 	topology := &models.NetworkTopology{
 		Devices:        mergedDevices,
+		Links:          links,
 		TotalCount:     len(mergedDevices),
-		ReachableCount: len(mergedDevices), // All devices in full scan are reachable
+		ReachableCount: reachableCount, // Placeholders and ARP cache hosts were not reached
 		SNMPCount:      snmpCount,
 		ARPCount:       arpOnlyCount,
 		ScanTime:       start,
 		ScanDuration:   scanDuration.Milliseconds(),
 		ScanMethod:     "FULL",
 	}
+	markCancelled(ctx, topology)
 
-	fs.logger.Infof("Full scan completed in %v. Found %d total devices (%d SNMP, %d ARP-only)",
+	fs.logger.Infof("Full scan completed in %v. Found %d total devices (%d SNMP, %d ARP/NDP-only)",
 		scanDuration, len(mergedDevices), snmpCount, arpOnlyCount)
 
 	// If there were errors but we still got some results, log warnings
@@ -192,8 +261,85 @@ func (fs *FullScanner) PerformFullScan(networkRange string, communities []string
 	return topology, nil
 }
 
-// mergeDevices merges SNMP and ARP scan results, combining devices found by both methods
-func (fs *FullScanner) mergeDevices(snmpDevices, arpDevices []*models.Device) []models.Device {
+// scanSNMPRange runs the SNMP sweep of networkRange. IPv6 prefixes too large to enumerate
+// return an empty topology; their hosts are queried once neighbour discovery found them.
+func (fs *FullScanner) scanSNMPRange(ctx context.Context, networkRange string, opts Options) (*models.NetworkTopology, error) {
+	if !snmpEnumerable(networkRange) {
+		fs.logger.Infof("Range %s is too large to sweep over SNMP; querying the hosts found by neighbour discovery", networkRange)
+		return &models.NetworkTopology{ScanTime: time.Now()}, nil
+	}
+	return fs.snmpScanner.ScanNetwork(ctx, networkRange, opts.SNMP)
+}
+
+// scanNeighbors runs the ARP sweep of an IPv4 range, followed by IPv6 neighbour discovery
+// on its links when opts.IncludeIPv6 is set, and neighbour discovery alone for an IPv6 range
+func (fs *FullScanner) scanNeighbors(ctx context.Context, networkRange string, opts Options) ([]*models.Device, error) {
+	if utils.IsIPv6CIDR(networkRange) {
+		return fs.arpScanner.ScanIPv6(ctx, networkRange)
+	}
+
+	devices, err := fs.arpScanner.ScanNetwork(ctx, networkRange)
+	if err != nil || !opts.IncludeIPv6 {
+		return devices, err
+	}
+
+	neighbors, err := fs.arpScanner.ScanIPv6(ctx, networkRange)
+	if err != nil && ctx.Err() == nil {
+		// IPv6 is an extra on an IPv4 scan, so its failure does not fail the scan
+		fs.logger.Warnf("IPv6 neighbour discovery failed: %v", err)
+		return devices, nil
+	}
+	return append(devices, neighbors...), err
+}
+
+// scanSNMPNeighbors queries the IPv6 neighbours the SNMP sweep of networkRange did not
+// cover: all of them for an IPv4 range or an IPv6 prefix too large to enumerate. The
+// returned devices carry the neighbour's MAC address.
+func (fs *FullScanner) scanSNMPNeighbors(ctx context.Context, networkRange string, neighbors []*models.Device, opts Options) []*models.Device {
+	if utils.IsIPv6CIDR(networkRange) && snmpEnumerable(networkRange) {
+		return nil
+	}
+
+	macs := make(map[string]string)
+	var ips []string
+	for _, device := range neighbors {
+		if device.ScanMethod == "NDP" {
+			macs[device.IP] = device.MACAddress
+			ips = append(ips, device.IP)
+		}
+	}
+	if len(ips) == 0 || ctx.Err() != nil {
+		return nil
+	}
+
+	topology, err := fs.snmpScanner.ScanHosts(ctx, ips, opts.SNMP)
+	if topology == nil {
+		fs.logger.Errorf("SNMP scan of IPv6 neighbours failed: %v", err)
+		return nil
+	}
+
+	var devices []*models.Device
+	for i := range topology.Devices {
+		device := &topology.Devices[i]
+		device.ScanMethod = "SNMP"
+		if device.MACAddress == "" {
+			device.MACAddress = macs[device.IP]
+		}
+		devices = append(devices, device)
+	}
+	fs.logger.Infof("SNMP scan of IPv6 neighbours completed: found %d devices", len(devices))
+	return devices
+}
+
+// snmpEnumerable reports whether the SNMP sweep can query networkRange address by address
+func snmpEnumerable(networkRange string) bool {
+	_, ipNet, err := net.ParseCIDR(networkRange)
+	return err != nil || !utils.IsEnumerationTooLarge(ipNet)
+}
+
+// mergeDevices merges SNMP and ARP scan results, combining devices found by both methods,
+// then unifies the records of each physical device (see MergeIdentities)
+func (fs *FullScanner) mergeDevices(ctx context.Context, snmpDevices, arpDevices []*models.Device) []models.Device {
 	deviceMap := make(map[string]*models.Device)
 
 	// Add SNMP devices first
@@ -229,7 +375,7 @@ func (fs *FullScanner) mergeDevices(snmpDevices, arpDevices []*models.Device) []
 	}
 
 	// Try to get MAC addresses for SNMP devices that don't have them
-	fs.enhanceSNMPDevicesWithMAC(deviceMap)
+	fs.enhanceSNMPDevicesWithMAC(ctx, deviceMap)
 
 	// Convert map to slice
 	var result []models.Device
@@ -237,12 +383,16 @@ func (fs *FullScanner) mergeDevices(snmpDevices, arpDevices []*models.Device) []
 		result = append(result, *device)
 	}
 
-	return result
+	return MergeIdentities(result)
 }
 
 // addOpenPorts enriches devices with open port information using the ports scanner (non-fatal on errors)
-func (fs *FullScanner) addOpenPorts(devices []models.Device) {
-	if !fs.enablePortScan || len(devices) == 0 || fs.portScanner == nil {
+func (fs *FullScanner) addOpenPorts(ctx context.Context, devices []models.Device, opts Options) {
+	backend := opts.PortScanner
+	if backend == nil {
+		backend = fs.portScanner
+	}
+	if !opts.EnablePortScan || len(devices) == 0 || backend == nil || ctx.Err() != nil {
 		return
 	}
 
@@ -250,11 +400,12 @@ func (fs *FullScanner) addOpenPorts(devices []models.Device) {
 		idx int
 		ip  string
 	}
+	type result struct {
+		idx  int
+		host *ports.HostResult
+	}
 	jobs := make(chan job)
-	results := make(chan struct {
-		idx   int
-		ports []models.PortInfo
-	})
+	results := make(chan result)
 
 	workerCount := fs.maxWorkers
 	if workerCount <= 0 {
@@ -267,26 +418,31 @@ func (fs *FullScanner) addOpenPorts(devices []models.Device) {
 		go func() {
 			defer wg.Done()
 			for j := range jobs {
-				ports, err := fs.portScanner.ScanHost(j.ip)
+				release, err := limits.Acquire(ctx)
+				if err != nil {
+					results <- result{idx: j.idx}
+					continue
+				}
+				host, err := ports.ScanHostDetails(ctx, backend, j.ip)
+				release()
 				if err != nil {
 					fs.logger.Debugf("Port scan failed for %s: %v", j.ip, err)
-					results <- struct {
-						idx   int
-						ports []models.PortInfo
-					}{idx: j.idx, ports: nil}
+					results <- result{idx: j.idx}
 					continue
 				}
-				results <- struct {
-					idx   int
-					ports []models.PortInfo
-				}{idx: j.idx, ports: ports}
+				results <- result{idx: j.idx, host: host}
 			}
 		}()
 	}
 
 	go func() {
+	dispatch:
 		for i := range devices {
-			jobs <- job{idx: i, ip: devices[i].IP}
+			select {
+			case jobs <- job{idx: i, ip: devices[i].IP}:
+			case <-ctx.Done():
+				break dispatch
+			}
 		}
 		close(jobs)
 		wg.Wait()
@@ -294,9 +450,15 @@ func (fs *FullScanner) addOpenPorts(devices []models.Device) {
 	}()
 
 	// Collect
+	tracker := events.NewTracker(ctx, models.PhasePorts, len(devices))
 	for r := range results {
-		if r.idx >= 0 && r.idx < len(devices) {
-			devices[r.idx].OpenPorts = r.ports
+		found := r.host != nil && len(r.host.Ports) > 0
+		tracker.Step(found)
+		if r.idx >= 0 && r.idx < len(devices) && r.host != nil {
+			MergeHostResult(&devices[r.idx], r.host)
+			if found {
+				events.DeviceFound(ctx, models.PhasePorts, &devices[r.idx])
+			}
 		}
 	}
 }
@@ -316,14 +478,54 @@ func (fs *FullScanner) addVendors(devices []models.Device) {
 	}
 }
 
+// addFingerprints fingerprints and classifies every device
+func (fs *FullScanner) addFingerprints(devices []models.Device) {
+	for i := range devices {
+		fs.Fingerprint(&devices[i])
+	}
+}
+
+// Fingerprint sets the vendor, model and version of an SNMP device from its sysObjectID
+// and sysDescr, lets the fingerprint rules (when enabled) override them and the OS,
+// guesses its OS from TTL and SYN-ACK, then classifies its device type, once its open
+// ports are known
+func (fs *FullScanner) Fingerprint(device *models.Device) {
+	fingerprint.Identify(device)
+	if fs.fingerprints != nil {
+		macVendor := ""
+		if fs.vendorMgr != nil && device.MACAddress != "" {
+			macVendor = fs.vendorMgr.GetVendor(device.MACAddress)
+		}
+		fs.fingerprints.Apply(device, macVendor)
+	}
+	fingerprint.GuessOS(device)
+	fingerprint.Classify(device)
+}
+
 // enhanceSNMPDevicesWithMAC attempts to get MAC addresses for SNMP devices
-func (fs *FullScanner) enhanceSNMPDevicesWithMAC(deviceMap map[string]*models.Device) {
+func (fs *FullScanner) enhanceSNMPDevicesWithMAC(ctx context.Context, deviceMap map[string]*models.Device) {
+	var table, table6 arp.NeighborTable
 	for ip, device := range deviceMap {
+		if ctx.Err() != nil {
+			return
+		}
 		if device.ScanMethod == "SNMP" && device.MACAddress == "" {
 			fs.logger.Debugf("Attempting to get MAC address for SNMP device: %s", ip)
 
-			// Try to get MAC via ARP for this specific IP
-			if macAddr := fs.getMACForIP(ip); macAddr != "" {
+			// Read each neighbour table once, on the first device that needs it
+			read, current := arp.ReadNeighborTable, &table
+			if strings.Contains(ip, ":") {
+				read, current = arp.ReadNeighborTable6, &table6
+			}
+			if *current == nil {
+				var err error
+				if *current, err = read(ctx); err != nil {
+					fs.logger.Warnf("Cannot resolve MAC addresses for SNMP devices: %v", err)
+					return
+				}
+			}
+
+			if macAddr := fs.getMACForIP(*current, ip); macAddr != "" {
 				device.MACAddress = macAddr
 				device.ScanMethod = "COMBINED"
 				fs.logger.Debugf("Added MAC address %s to SNMP device %s", macAddr, ip)
@@ -332,67 +534,104 @@ func (fs *FullScanner) enhanceSNMPDevicesWithMAC(deviceMap map[string]*models.De
 	}
 }
 
-// getMACForIP attempts to get MAC address for a specific IP using ARP
-func (fs *FullScanner) getMACForIP(ip string) string {
-	// Create a temporary ARP scanner for single IP lookup
-	tempScanner := arp.NewScannerWithLogger(1, fs.logger)
-
-	// This is a simplified approach - in a full implementation,
-	// you might want to use a more direct ARP lookup method
-	devices, err := tempScanner.ScanNetwork(ip + "/32")
-	if err != nil || len(devices) == 0 {
-		return ""
-	}
-
-	return devices[0].MACAddress
+// getMACForIP looks up the MAC address of an IP in the OS neighbour table. SNMP has
+// already exchanged packets with the device, so on-link hosts are resolved by the kernel.
+func (fs *FullScanner) getMACForIP(table arp.NeighborTable, ip string) string {
+	return table.Lookup(ip)
 }
 
 // PerformSNMPScan performs only SNMP scan
-func (fs *FullScanner) PerformSNMPScan(networkRange string, communities []string) (*models.NetworkTopology, error) {
+func (fs *FullScanner) PerformSNMPScan(ctx context.Context, networkRange string, opts Options) (*models.NetworkTopology, error) {
 	fs.logger.Infof("Starting SNMP-only scan for range: %s", networkRange)
 
-	topology, err := fs.snmpScanner.ScanNetwork(networkRange, communities)
-	if err != nil {
+	topology, err := fs.scanSNMPRange(ctx, networkRange, opts)
+	if topology == nil {
 		return nil, err
 	}
 
+	// Hosts of a large IPv6 prefix (or the links of an IPv4 range) are found by
+	// neighbour discovery before they can be queried
+	if !snmpEnumerable(networkRange) || (opts.IncludeIPv6 && !utils.IsIPv6CIDR(networkRange)) {
+		neighbors, err := fs.arpScanner.ScanIPv6(ctx, networkRange)
+		if err != nil && ctx.Err() == nil {
+			if utils.IsIPv6CIDR(networkRange) {
+				return nil, err
+			}
+			fs.logger.Warnf("IPv6 neighbour discovery failed: %v", err)
+		}
+		for _, device := range fs.scanSNMPNeighbors(ctx, networkRange, neighbors, opts) {
+			topology.Devices = append(topology.Devices, *device)
+			topology.TotalCount++
+			if device.IsReachable {
+				topology.ReachableCount++
+			}
+		}
+		topology.ScanDuration = time.Since(topology.ScanTime).Milliseconds()
+	}
+
 	// Update scan method for all devices
 	for i := range topology.Devices {
 		topology.Devices[i].ScanMethod = "SNMP"
 	}
+	// Resolve MACs of hosts behind routers from their ARP caches
+	snmpDevices := make([]*models.Device, len(topology.Devices))
+	for i := range topology.Devices {
+		snmpDevices[i] = &topology.Devices[i]
+	}
+	for _, host := range harvestARPCaches(snmpDevices, nil, networkRange, opts.ARPCacheHosts) {
+		topology.Devices = append(topology.Devices, *host)
+	}
+
+	// Devices answering on several addresses are reported once
+	topology.Devices = MergeIdentities(topology.Devices)
+	topology.TotalCount = len(topology.Devices)
+	topology.ReachableCount = 0
+	for _, device := range topology.Devices {
+		if device.IsReachable {
+			topology.ReachableCount++
+		}
+	}
 
 	// Enrich with open ports
-	fs.addOpenPorts(topology.Devices)
+	fs.addOpenPorts(ctx, topology.Devices, opts)
+	// Connect devices by their LLDP/CDP neighbours, adding the neighbours not scanned
+	topology.Devices, topology.Links = BuildLinks(topology.Devices)
+	MapSwitchPorts(topology.Devices, topology.Links)
+	topology.TotalCount = len(topology.Devices)
 	// Enrich vendors if MACs are available
 	fs.addVendors(topology.Devices)
+	fs.addFingerprints(topology.Devices)
 
 	topology.ScanMethod = "SNMP"
 	topology.SNMPCount = topology.ReachableCount
 	topology.ARPCount = 0
+	markCancelled(ctx, topology)
 
 	return topology, nil
 }
 
-// PerformARPScan performs only ARP scan
-func (fs *FullScanner) PerformARPScan(networkRange string) (*models.NetworkTopology, error) {
+// PerformARPScan performs only ARP scan, or neighbour discovery for an IPv6 range
+func (fs *FullScanner) PerformARPScan(ctx context.Context, networkRange string, opts Options) (*models.NetworkTopology, error) {
 	start := time.Now()
 	fs.logger.Infof("Starting ARP-only scan for range: %s", networkRange)
 
-	devices, err := fs.arpScanner.ScanNetwork(networkRange)
-	if err != nil {
+	devices, err := fs.scanNeighbors(ctx, networkRange, opts)
+	if err != nil && ctx.Err() == nil {
 		return nil, err
 	}
 
-	// Convert to regular devices slice
+	// Convert to regular devices slice, one device per MAC across IPv4 and IPv6
 	var deviceSlice []models.Device
 	for _, device := range devices {
 		deviceSlice = append(deviceSlice, *device)
 	}
+	deviceSlice = MergeIdentities(deviceSlice)
 
 	// Enrich with open ports
-	fs.addOpenPorts(deviceSlice)
+	fs.addOpenPorts(ctx, deviceSlice, opts)
 	// Ensure vendors are filled based on MAC
 	fs.addVendors(deviceSlice)
+	fs.addFingerprints(deviceSlice)
 
 	scanDuration := time.Since(start)
 
@@ -406,6 +645,15 @@ func (fs *FullScanner) PerformARPScan(networkRange string) (*models.NetworkTopol
 		ScanDuration:   scanDuration.Milliseconds(),
 		ScanMethod:     "ARP",
 	}
+	markCancelled(ctx, topology)
 
 	return topology, nil
 }
+
+// markCancelled flags a topology as partial when its scan context was cancelled or timed out
+func markCancelled(ctx context.Context, topology *models.NetworkTopology) {
+	if err := ctx.Err(); err != nil {
+		topology.Cancelled = true
+		topology.CancelReason = err.Error()
+	}
+}
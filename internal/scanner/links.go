@@ -0,0 +1,169 @@
+package scanner
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"network-discovery/internal/models"
+)
+
+// BuildLinks turns the LLDP and CDP neighbours reported by devices into links between
+// them. A neighbour is matched to a device by chassis MAC, management address or
+// sysName; neighbours that match none are appended as placeholder devices, so every
+// link ends at a device of the topology. A link reported from both ends, or over both
+// protocols, is listed once.
+func BuildLinks(devices []models.Device) ([]models.Device, []models.Link) {
+	byMAC := make(map[string]int)
+	byIP := make(map[string]int)
+	byName := make(map[string]int)
+	index := func(i int) {
+		for _, mac := range identityMACs(&devices[i]) {
+			if _, ok := byMAC[mac]; !ok {
+				byMAC[mac] = i
+			}
+		}
+		for _, ip := range devices[i].IPs() {
+			if _, ok := byIP[ip]; !ok {
+				byIP[ip] = i
+			}
+		}
+		for _, name := range hostNames(devices[i].Hostname) {
+			if _, ok := byName[name]; !ok {
+				byName[name] = i
+			}
+		}
+	}
+	find := func(n models.Neighbor) (int, bool) {
+		if mac, err := net.ParseMAC(n.ChassisID); err == nil && len(mac) == 6 {
+			if i, ok := byMAC[strings.ToUpper(mac.String())]; ok {
+				return i, true
+			}
+		}
+		if i, ok := byIP[n.ManagementAddress]; ok && n.ManagementAddress != "" {
+			return i, true
+		}
+		for _, name := range hostNames(n.SysName) {
+			if i, ok := byName[name]; ok {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	scanned := len(devices)
+	for i := 0; i < scanned; i++ {
+		index(i)
+	}
+
+	var links []models.Link
+	// Keys of both ends ("device|port>remote device") to the link they belong to
+	seen := make(map[string]int)
+
+	for i := 0; i < scanned; i++ {
+		for _, n := range devices[i].Neighbors {
+			j, ok := find(n)
+			if !ok {
+				devices = append(devices, placeholderDevice(n, devices[i]))
+				j = len(devices) - 1
+				index(j)
+			}
+			if j == i {
+				continue
+			}
+
+			local, remote := deviceRef(&devices[i]), deviceRef(&devices[j])
+			localKey := local + "|" + portKey(&devices[i], n.LocalPort, "") + ">" + remote
+			if k, ok := seen[localKey]; ok {
+				if !contains(links[k].Protocols, n.Protocol) {
+					links[k].Protocols = append(links[k].Protocols, n.Protocol)
+				}
+				continue
+			}
+
+			links = append(links, models.Link{
+				LocalDevice:  local,
+				LocalPort:    n.LocalPort,
+				RemoteDevice: remote,
+				RemotePort:   n.RemotePort,
+				Protocols:    []string{n.Protocol},
+			})
+			seen[localKey] = len(links) - 1
+			if n.RemotePort != "" {
+				// The report of the other end names this link from its side
+				seen[remote+"|"+portKey(&devices[j], n.RemotePort, n.RemotePortDescr)+">"+local] = len(links) - 1
+			}
+		}
+	}
+	return devices, links
+}
+
+// placeholderDevice describes a neighbour that was not scanned from what its LLDP or
+// CDP advertisement tells
+func placeholderDevice(n models.Neighbor, reporter models.Device) models.Device {
+	device := models.Device{
+		IP:           n.ManagementAddress,
+		Hostname:     n.SysName,
+		Description:  n.SysDescription,
+		Model:        n.Platform,
+		Capabilities: n.Capabilities,
+		LastSeen:     reporter.LastSeen,
+		ScanMethod:   n.Protocol,
+		Placeholder:  true,
+	}
+	if mac, err := net.ParseMAC(n.ChassisID); err == nil && len(mac) == 6 {
+		device.MACAddress = strings.ToUpper(mac.String())
+	}
+	if device.IP != "" {
+		device.Addresses = []models.DeviceAddress{{IP: device.IP, MACAddress: device.MACAddress, Source: n.Protocol}}
+	}
+	return device
+}
+
+// deviceRef is how links refer to a device: its primary IP, or its name or MAC when
+// a placeholder has no known address
+func deviceRef(d *models.Device) string {
+	switch {
+	case d.IP != "":
+		return d.IP
+	case d.Hostname != "":
+		return d.Hostname
+	}
+	return d.MACAddress
+}
+
+// portKey identifies a port of d: the ifIndex of the interface it names, so that ifName
+// (LLDP) and ifDescr (CDP) spellings of one port match, or the lower-cased name
+func portKey(d *models.Device, port, description string) string {
+	for _, iface := range d.Interfaces {
+		for _, label := range []string{port, description} {
+			if label != "" && (strings.EqualFold(label, iface.Name) || strings.EqualFold(label, iface.Description) || strings.EqualFold(label, iface.Alias)) {
+				return "if" + strconv.Itoa(iface.Index)
+			}
+		}
+	}
+	return strings.ToLower(port)
+}
+
+// hostNames returns the names a sysName is matched by: the full name and, for a
+// domain name, its first label. Factory default names are not matched.
+func hostNames(name string) []string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || genericSysNames[name] {
+		return nil
+	}
+	names := []string{name}
+	if short, _, ok := strings.Cut(name, "."); ok && short != "" && net.ParseIP(name) == nil && !genericSysNames[short] {
+		names = append(names, short)
+	}
+	return names
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,301 @@
+package scanner
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+
+	"network-discovery/internal/models"
+)
+
+// sharedMACPrefixes are virtual router MACs (VRRP, HSRP) that several physical routers
+// answer with, so they never identify a single device
+var sharedMACPrefixes = []string{"00:00:5E:00:01:", "00:00:5E:00:02:", "00:00:0C:07:AC:", "00:00:0C:9F:F"}
+
+// genericSysNames are factory default sysName values shared by unrelated devices
+var genericSysNames = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"(none)":                true,
+	"router":                true,
+	"switch":                true,
+}
+
+// MergeIdentities unifies records that describe the same physical device: records
+// sharing a MAC address (including SNMP interface MACs), records whose address appears
+// in another device's SNMP address table, and SNMP devices with the same sysName and
+// sysDescr. Addresses found in the tables of several devices (see SharedAddresses)
+// link nothing. A router answering on ten interfaces, or a host found over both IPv4 and
+// IPv6, becomes one device listing every address in Addresses. The primary IP is an
+// SNMP-reachable address when there is one, IPv4 preferred.
+func MergeIdentities(devices []models.Device) []models.Device {
+	if len(devices) == 0 {
+		return devices
+	}
+
+	parent := make([]int, len(devices))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	// Link every record to the first record sharing one of its identity keys
+	owners := make(map[string]int)
+	link := func(key string, i int) {
+		if j, ok := owners[key]; ok {
+			parent[find(i)] = find(j)
+		} else {
+			owners[key] = i
+		}
+	}
+	for i := range devices {
+		for _, mac := range identityMACs(&devices[i]) {
+			link("mac:"+mac, i)
+		}
+		if key := sysNameKey(&devices[i]); key != "" {
+			link("sys:"+key, i)
+		}
+	}
+	// Addresses are linked once the records of each agent are grouped, so an address
+	// that several agents report is recognised as shared
+	shared := SharedAddresses(devices, find)
+	for i := range devices {
+		for _, ip := range devices[i].IPs() {
+			if ip == devices[i].IP || !shared[ip] {
+				link("ip:"+ip, i)
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	var roots []int
+	for i := range devices {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], i)
+	}
+
+	merged := make([]models.Device, 0, len(roots))
+	for _, root := range roots {
+		merged = append(merged, mergeGroup(devices, groups[root]))
+	}
+	return merged
+}
+
+// mergeGroup combines the records of one physical device
+func mergeGroup(devices []models.Device, members []int) models.Device {
+	sort.SliceStable(members, func(a, b int) bool {
+		return primaryBefore(&devices[members[a]], &devices[members[b]])
+	})
+
+	result := devices[members[0]]
+	result.Addresses = nil
+	result.OpenPorts = nil
+	hasSNMP, hasNeighbor := false, false
+
+	for _, i := range members {
+		d := &devices[i]
+		switch d.ScanMethod {
+		case "SNMP":
+			hasSNMP = true
+		case "ARP", "NDP":
+			hasNeighbor = true
+		case "COMBINED":
+			hasSNMP, hasNeighbor = true, true
+		}
+
+		fill(&result.MACAddress, d.MACAddress)
+		fill(&result.Hostname, d.Hostname)
+		fill(&result.Description, d.Description)
+		fill(&result.Contact, d.Contact)
+		fill(&result.Location, d.Location)
+		fill(&result.Uptime, d.Uptime)
+		fill(&result.Model, d.Model)
+		fill(&result.Version, d.Version)
+		fill(&result.ObjectID, d.ObjectID)
+		fill(&result.OS, d.OS)
+		if result.Services == 0 {
+			result.Services = d.Services
+		}
+		if result.TTL == 0 {
+			result.TTL = d.TTL
+		}
+		if len(result.Capabilities) == 0 {
+			result.Capabilities = d.Capabilities
+		}
+		if (result.Vendor == "" || result.Vendor == "Unknown") && d.Vendor != "" {
+			result.Vendor = d.Vendor
+		}
+		if len(result.Interfaces) == 0 {
+			result.Interfaces = d.Interfaces
+		}
+		if len(result.Neighbors) == 0 {
+			result.Neighbors = d.Neighbors
+		}
+		if len(result.ForwardingTable) == 0 {
+			result.ForwardingTable = d.ForwardingTable
+		}
+		if d.LastSeen.After(result.LastSeen) {
+			result.LastSeen = d.LastSeen
+		}
+		if d.IsReachable {
+			result.IsReachable = true
+		}
+		if d.ResponseTime > 0 && (result.ResponseTime == 0 || d.ResponseTime < result.ResponseTime) {
+			result.ResponseTime = d.ResponseTime
+		}
+
+		result.Addresses = addAddress(result.Addresses, models.DeviceAddress{IP: d.IP, MACAddress: d.MACAddress, Source: d.ScanMethod})
+		for _, addr := range d.Addresses {
+			result.Addresses = addAddress(result.Addresses, addr)
+		}
+		result.OpenPorts = addPorts(result.OpenPorts, d.OpenPorts)
+	}
+
+	if hasSNMP && hasNeighbor {
+		result.ScanMethod = "COMBINED"
+	}
+	sort.SliceStable(result.Addresses, func(a, b int) bool {
+		if (result.Addresses[a].IP == result.IP) != (result.Addresses[b].IP == result.IP) {
+			return result.Addresses[a].IP == result.IP
+		}
+		return lessAddr(result.Addresses[a].IP, result.Addresses[b].IP)
+	})
+	return result
+}
+
+// primaryBefore orders the records of a device by their fitness as its primary record:
+// SNMP data first, then IPv4, then global over link-local addresses
+func primaryBefore(a, b *models.Device) bool {
+	if snmpA, snmpB := a.SNMPVersion != "", b.SNMPVersion != ""; snmpA != snmpB {
+		return snmpA
+	}
+	if v4A, v4B := !strings.Contains(a.IP, ":"), !strings.Contains(b.IP, ":"); v4A != v4B {
+		return v4A
+	}
+	if zonedA, zonedB := strings.Contains(a.IP, "%"), strings.Contains(b.IP, "%"); zonedA != zonedB {
+		return zonedB
+	}
+	return false
+}
+
+// SharedAddresses returns the addresses that the address tables of more than one device
+// list, such as docker0 (172.17.0.1), libvirt (192.168.122.1) or a VRRP/HSRP/anycast
+// virtual IP; they do not identify a device. device maps a record to the device it
+// belongs to. A record's own IP is not counted.
+func SharedAddresses(devices []models.Device, device func(int) int) map[string]bool {
+	reporters := make(map[string]int)
+	shared := make(map[string]bool)
+	for i := range devices {
+		for _, addr := range devices[i].Addresses {
+			if addr.IP == "" || addr.IP == devices[i].IP {
+				continue
+			}
+			if j, ok := reporters[addr.IP]; !ok {
+				reporters[addr.IP] = device(i)
+			} else if j != device(i) {
+				shared[addr.IP] = true
+			}
+		}
+	}
+	return shared
+}
+
+// identityMACs returns the MAC addresses that identify a device: its own and those of
+// its SNMP interfaces and addresses, without shared virtual router MACs
+func identityMACs(d *models.Device) []string {
+	var macs []string
+	add := func(mac string) {
+		mac = strings.ToUpper(mac)
+		if mac == "" || mac == "00:00:00:00:00:00" {
+			return
+		}
+		for _, prefix := range sharedMACPrefixes {
+			if strings.HasPrefix(mac, prefix) {
+				return
+			}
+		}
+		macs = append(macs, mac)
+	}
+
+	add(d.MACAddress)
+	for _, iface := range d.Interfaces {
+		add(iface.MACAddress)
+	}
+	for _, addr := range d.Addresses {
+		add(addr.MACAddress)
+	}
+	return macs
+}
+
+// sysNameKey identifies SNMP devices by sysName and sysDescr; devices left with a
+// factory default name are not matched
+func sysNameKey(d *models.Device) string {
+	name := strings.ToLower(strings.TrimSpace(d.Hostname))
+	if d.SNMPVersion == "" || name == "" || genericSysNames[name] {
+		return ""
+	}
+	return name + "\x00" + d.Description
+}
+
+// addAddress appends addr unless its IP is already listed, in which case missing
+// details are filled in
+func addAddress(addresses []models.DeviceAddress, addr models.DeviceAddress) []models.DeviceAddress {
+	if addr.IP == "" {
+		return addresses
+	}
+	for i := range addresses {
+		if addresses[i].IP == addr.IP {
+			fill(&addresses[i].MACAddress, addr.MACAddress)
+			if addresses[i].IfIndex == 0 {
+				addresses[i].IfIndex = addr.IfIndex
+			}
+			if addresses[i].PrefixLength == 0 {
+				addresses[i].PrefixLength = addr.PrefixLength
+			}
+			return addresses
+		}
+	}
+	return append(addresses, addr)
+}
+
+// addPorts appends the ports not yet listed
+func addPorts(list, ports []models.PortInfo) []models.PortInfo {
+	for _, port := range ports {
+		found := false
+		for _, existing := range list {
+			if existing.Port == port.Port && strings.EqualFold(existing.Protocol, port.Protocol) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, port)
+		}
+	}
+	return list
+}
+
+func fill(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+// lessAddr orders IPv4 before IPv6 and numerically within a family
+func lessAddr(a, b string) bool {
+	ipA, errA := netip.ParseAddr(a)
+	ipB, errB := netip.ParseAddr(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return ipA.Less(ipB)
+}
@@ -0,0 +1,163 @@
+package scanner
+
+import (
+	"strings"
+	"time"
+
+	"network-discovery/internal/models"
+	"network-discovery/internal/ports"
+)
+
+// NmapImport counts what merging an nmap report into a topology did
+type NmapImport struct {
+	Hosts   int `json:"hosts"`   // Hosts in the report
+	Matched int `json:"matched"` // Hosts merged into a device of the topology
+	Added   int `json:"added"`   // Hosts added as new devices
+	Skipped int `json:"skipped"` // Hosts nmap reported down or without an address
+}
+
+// ImportNmap merges the hosts of an nmap report into a copy of topology and returns
+// it. A host is matched to a device by its IP or an address no other device shares,
+// then by MAC address; unmatched hosts that are up become devices with scan_method
+// "NMAP". Every device the report touched is fingerprinted again.
+func (fs *FullScanner) ImportNmap(topology *models.NetworkTopology, hosts []ports.HostResult) (*models.NetworkTopology, NmapImport) {
+	merged := *topology
+	merged.Devices = make([]models.Device, len(topology.Devices))
+	for i, d := range topology.Devices {
+		// Ports and addresses are updated in place; keep the original topology intact
+		d.OpenPorts = append([]models.PortInfo(nil), d.OpenPorts...)
+		d.Addresses = append([]models.DeviceAddress(nil), d.Addresses...)
+		merged.Devices[i] = d
+	}
+
+	owners := make(map[string]int)
+	shared := SharedAddresses(merged.Devices, func(i int) int { return i })
+	for i := range merged.Devices {
+		for _, ip := range merged.Devices[i].IPs() {
+			if !shared[ip] {
+				owners["ip:"+ip] = i
+			}
+		}
+	}
+	for i := range merged.Devices {
+		// A device's own IP wins over another device's address table
+		if ip := merged.Devices[i].IP; ip != "" {
+			owners["ip:"+ip] = i
+		}
+		for _, mac := range identityMACs(&merged.Devices[i]) {
+			if _, ok := owners["mac:"+mac]; !ok {
+				owners["mac:"+mac] = i
+			}
+		}
+	}
+
+	summary := NmapImport{Hosts: len(hosts)}
+	touched := make(map[int]bool)
+	for i := range hosts {
+		host := &hosts[i]
+		if !host.Up || host.IP == "" {
+			summary.Skipped++
+			continue
+		}
+
+		idx, ok := owners["ip:"+host.IP]
+		if !ok && host.MACAddress != "" {
+			idx, ok = owners["mac:"+strings.ToUpper(host.MACAddress)]
+		}
+		if ok {
+			summary.Matched++
+		} else {
+			idx = len(merged.Devices)
+			merged.Devices = append(merged.Devices, models.Device{
+				IP:         host.IP,
+				ScanMethod: "NMAP",
+			})
+			owners["ip:"+host.IP] = idx
+			if host.MACAddress != "" {
+				owners["mac:"+strings.ToUpper(host.MACAddress)] = idx
+			}
+			summary.Added++
+		}
+
+		MergeHostResult(&merged.Devices[idx], host)
+		touched[idx] = true
+	}
+
+	for idx := range touched {
+		fs.Fingerprint(&merged.Devices[idx])
+	}
+
+	merged.TotalCount = len(merged.Devices)
+	merged.ReachableCount = 0
+	for _, d := range merged.Devices {
+		if d.IsReachable {
+			merged.ReachableCount++
+		}
+	}
+	return &merged, summary
+}
+
+// MergeHostResult adds what a port scan found out about a host to its device: open
+// ports, the service versions of ports already known, nmap's OS matches, and the
+// hostname, MAC address and vendor when the device has none
+func MergeHostResult(device *models.Device, host *ports.HostResult) {
+	device.OpenPorts = mergePorts(device.OpenPorts, host.Ports)
+	if len(host.OSMatches) > 0 {
+		device.OSMatches = host.OSMatches
+	}
+
+	if len(host.Hostnames) > 0 {
+		fill(&device.Hostname, host.Hostnames[0])
+	}
+	fill(&device.MACAddress, host.MACAddress)
+	if (device.Vendor == "" || device.Vendor == "Unknown") && host.Vendor != "" {
+		device.Vendor = host.Vendor
+	}
+	if host.IP != "" && host.IP != device.IP {
+		device.Addresses = addAddress(device.Addresses, models.DeviceAddress{IP: host.IP, MACAddress: host.MACAddress, Source: "NMAP"})
+	}
+
+	if host.Up {
+		device.IsReachable = true
+		seen := host.Seen
+		if seen.IsZero() {
+			seen = time.Now()
+		}
+		if seen.After(device.LastSeen) {
+			device.LastSeen = seen
+		}
+	}
+}
+
+// mergePorts appends the ports not yet listed and fills in the service details of
+// those that are, keeping their banner and SYN-ACK
+func mergePorts(list, found []models.PortInfo) []models.PortInfo {
+	for _, port := range found {
+		existing := -1
+		for i := range list {
+			if list[i].Port == port.Port && strings.EqualFold(list[i].Protocol, port.Protocol) {
+				existing = i
+				break
+			}
+		}
+		if existing < 0 {
+			list = append(list, port)
+			continue
+		}
+
+		p := &list[existing]
+		fill(&p.Service, port.Service)
+		fill(&p.Banner, port.Banner)
+		fill(&p.Product, port.Product)
+		fill(&p.Version, port.Version)
+		fill(&p.ExtraInfo, port.ExtraInfo)
+		fill(&p.Tunnel, port.Tunnel)
+		if len(p.CPE) == 0 {
+			p.CPE = port.CPE
+		}
+		if p.SYNACK == nil {
+			p.SYNACK = port.SYNACK
+		}
+	}
+	return list
+}
@@ -0,0 +1,123 @@
+package scanner
+
+import (
+	"strconv"
+	"strings"
+
+	"network-discovery/internal/models"
+)
+
+// MaxAccessPortMACs is the number of MAC addresses above which a switch port is taken
+// for an uplink (to an unscanned switch, for example) rather than the port a device
+// is plugged into
+const MaxAccessPortMACs = 16
+
+// MapSwitchPorts sets SwitchPort on the devices whose MAC address a scanned switch
+// learned. Ports leading to other switches are left out: ports with an LLDP/CDP link to
+// a bridge or router, and ports with more than MaxAccessPortMACs addresses. When
+// several switches learned the address, the port with the fewest addresses is the
+// one at the edge of the network.
+func MapSwitchPorts(devices []models.Device, links []models.Link) {
+	// MAC address to the port it is best located on
+	best := make(map[string]models.SwitchPort)
+
+	for s := range devices {
+		sw := &devices[s]
+		if len(sw.ForwardingTable) == 0 {
+			continue
+		}
+		uplinks := uplinkPorts(devices, s, links)
+
+		counts := make(map[string]int)
+		for _, entry := range sw.ForwardingTable {
+			counts[entry.Port]++
+		}
+		for _, entry := range sw.ForwardingTable {
+			count := counts[entry.Port]
+			if count > MaxAccessPortMACs || uplinks[fdbPortKey(sw, entry)] {
+				continue
+			}
+			if current, ok := best[entry.MACAddress]; ok && current.MACCount <= count {
+				continue
+			}
+			best[entry.MACAddress] = models.SwitchPort{
+				Switch:     sw.IP,
+				SwitchName: sw.Hostname,
+				Port:       entry.Port,
+				IfIndex:    entry.IfIndex,
+				VLAN:       entry.VLAN,
+				FDBID:      entry.FDBID,
+				MACCount:   count,
+			}
+		}
+	}
+
+	for i := range devices {
+		devices[i].SwitchPort = nil
+		for _, mac := range identityMACs(&devices[i]) {
+			port, ok := best[mac]
+			if !ok || port.Switch == devices[i].IP {
+				continue
+			}
+			devices[i].SwitchPort = &port
+			break
+		}
+	}
+}
+
+// uplinkPorts returns the keys of the ports of devices[s] that lead to other network
+// devices: LLDP/CDP neighbours advertising bridge or router capabilities (but not
+// phones, which bridge the PC behind them), and links to scanned switches
+func uplinkPorts(devices []models.Device, s int, links []models.Link) map[string]bool {
+	sw := &devices[s]
+	uplinks := make(map[string]bool)
+
+	for _, n := range sw.Neighbors {
+		if infrastructureNeighbor(n.Capabilities) {
+			uplinks[portKey(sw, n.LocalPort, "")] = true
+		}
+	}
+
+	bridges := make(map[string]bool)
+	for i := range devices {
+		if i != s && len(devices[i].ForwardingTable) > 0 {
+			bridges[deviceRef(&devices[i])] = true
+		}
+	}
+	self := deviceRef(sw)
+	for _, link := range links {
+		switch {
+		case link.LocalDevice == self && bridges[link.RemoteDevice]:
+			uplinks[portKey(sw, link.LocalPort, "")] = true
+		case link.RemoteDevice == self && bridges[link.LocalDevice]:
+			uplinks[portKey(sw, link.RemotePort, "")] = true
+		}
+	}
+	return uplinks
+}
+
+// infrastructureNeighbor reports whether LLDP/CDP capabilities describe a switch or router
+func infrastructureNeighbor(capabilities []string) bool {
+	infrastructure := false
+	for _, capability := range capabilities {
+		switch capability {
+		case "telephone", "host", "stationOnly":
+			return false
+		case "bridge", "switch", "router":
+			infrastructure = true
+		}
+	}
+	return infrastructure
+}
+
+// fdbPortKey is the portKey of the port a forwarding entry was learned on
+func fdbPortKey(sw *models.Device, entry models.ForwardingEntry) string {
+	if entry.IfIndex != 0 {
+		for _, iface := range sw.Interfaces {
+			if iface.Index == entry.IfIndex {
+				return "if" + strconv.Itoa(entry.IfIndex)
+			}
+		}
+	}
+	return strings.ToLower(entry.Port)
+}
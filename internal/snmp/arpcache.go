@@ -0,0 +1,90 @@
+package snmp
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"network-discovery/internal/models"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// IP-MIB address translation tables
+const (
+	OIDIpNetToPhysicalPhysAddress = "1.3.6.1.2.1.4.35.1.4" // ipNetToPhysicalTable: ifIndex.InetAddress -> MAC
+	OIDIpNetToPhysicalType        = "1.3.6.1.2.1.4.35.1.6" // ipNetToPhysicalTable: entry type
+	OIDIpNetToMediaPhysAddress    = "1.3.6.1.2.1.4.22.1.2" // ipNetToMediaTable: ifIndex.IPv4 address -> MAC
+	OIDIpNetToMediaType           = "1.3.6.1.2.1.4.22.1.4" // ipNetToMediaTable: entry type
+)
+
+// Address translation entry types that do not map a neighbour: invalid(2), and
+// local(5), the device's own addresses
+const (
+	arpTypeInvalid = 2
+	arpTypeLocal   = 5
+)
+
+// getARPCache reads the ARP and IPv6 neighbour cache of the device into ARPCache, from
+// ipNetToPhysicalTable or, on agents without IPv4 entries there, ipNetToMediaTable.
+// Routers learn the MACs of hosts in subnets the local ARP scan cannot reach.
+func (c *Client) getARPCache(client *gosnmp.GoSNMP, device *models.Device) {
+	entries := make(map[string]*models.ARPEntry)
+	skip := make(map[string]bool)
+	add := func(key string, ip net.IP, ifIndex int, value interface{}) {
+		if ip == nil || ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() || (ip.To4() == nil && ip.IsLinkLocalUnicast()) {
+			return
+		}
+		mac := formatMAC(value)
+		if mac == "" || mac == "FF:FF:FF:FF:FF:FF" {
+			return
+		}
+		entries[key] = &models.ARPEntry{IP: ip.String(), MACAddress: mac, IfIndex: ifIndex}
+	}
+	markType := func(column string) func(pdu gosnmp.SnmpPDU) {
+		return func(pdu gosnmp.SnmpPDU) {
+			if index, ok := tableIndex(pdu.Name, column); ok {
+				entryType := gosnmp.ToBigInt(pdu.Value).Int64()
+				skip[fmt.Sprint(index)] = entryType == arpTypeInvalid || entryType == arpTypeLocal
+			}
+		}
+	}
+
+	hasIPv4 := false
+	c.walk(client, OIDIpNetToPhysicalPhysAddress, func(pdu gosnmp.SnmpPDU) {
+		index, ok := tableIndex(pdu.Name, OIDIpNetToPhysicalPhysAddress)
+		if !ok || len(index) < 3 {
+			return
+		}
+		ip := inetAddressFromIndex(index[1:])
+		if ip != nil && ip.To4() != nil {
+			hasIPv4 = true
+		}
+		add(fmt.Sprint(index), ip, index[0], pdu.Value)
+	})
+	if len(entries) > 0 {
+		c.walk(client, OIDIpNetToPhysicalType, markType(OIDIpNetToPhysicalType))
+	}
+	if !hasIPv4 {
+		c.walk(client, OIDIpNetToMediaPhysAddress, func(pdu gosnmp.SnmpPDU) {
+			if index, ok := tableIndex(pdu.Name, OIDIpNetToMediaPhysAddress); ok && len(index) == 5 {
+				add(fmt.Sprint(index), ipv4FromIndex(index[1:]), index[0], pdu.Value)
+			}
+		})
+		c.walk(client, OIDIpNetToMediaType, markType(OIDIpNetToMediaType))
+	}
+
+	device.ARPCache = nil
+	for key, entry := range entries {
+		if !skip[key] {
+			device.ARPCache = append(device.ARPCache, *entry)
+		}
+	}
+	sort.Slice(device.ARPCache, func(a, b int) bool {
+		return lessAddr(device.ARPCache[a].IP, device.ARPCache[b].IP)
+	})
+
+	if len(device.ARPCache) > 0 {
+		c.logger.Debugf("Device %s has %d ARP/neighbour cache entries", device.IP, len(device.ARPCache))
+	}
+}
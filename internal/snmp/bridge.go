@@ -0,0 +1,152 @@
+package snmp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"network-discovery/internal/models"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// BRIDGE-MIB and Q-BRIDGE-MIB forwarding tables
+const (
+	OIDDot1dBasePortIfIndex = "1.3.6.1.2.1.17.1.4.1.2"     // dot1dBasePortTable: bridge port -> ifIndex
+	OIDDot1dTpFdbPort       = "1.3.6.1.2.1.17.4.3.1.2"     // dot1dTpFdbTable: MAC -> bridge port
+	OIDDot1dTpFdbStatus     = "1.3.6.1.2.1.17.4.3.1.3"     // dot1dTpFdbTable: MAC -> entry status
+	OIDDot1qTpFdbPort       = "1.3.6.1.2.1.17.7.1.2.2.1.2" // dot1qTpFdbTable: FDB ID.MAC -> bridge port
+	OIDDot1qTpFdbStatus     = "1.3.6.1.2.1.17.7.1.2.2.1.3" // dot1qTpFdbTable: FDB ID.MAC -> entry status
+	OIDDot1qVlanFdbID       = "1.3.6.1.2.1.17.7.1.4.2.1.3" // dot1qVlanCurrentTable: time mark.VLAN -> FDB ID
+)
+
+// Forwarding entry status values that do not locate a host: invalid(2) and self(4),
+// the bridge's own addresses
+const (
+	fdbStatusInvalid = 2
+	fdbStatusSelf    = 4
+)
+
+// getForwardingTable reads the MAC addresses a bridge learned per port into
+// ForwardingTable, from the VLAN-aware dot1qTpFdbTable or else dot1dTpFdbTable.
+// dot1qTpFdbTable is indexed by filtering database; its VLAN comes from
+// dot1qVlanCurrentTable. Devices without a dot1dBasePortTable are not bridges and are
+// skipped.
+// Interfaces must already be collected: ports are reported by interface name.
+func (c *Client) getForwardingTable(client *gosnmp.GoSNMP, device *models.Device) {
+	basePorts := make(map[int]int)
+	c.walk(client, OIDDot1dBasePortIfIndex, func(pdu gosnmp.SnmpPDU) {
+		if index, ok := tableIndex(pdu.Name, OIDDot1dBasePortIfIndex); ok && len(index) == 1 {
+			basePorts[index[0]] = int(gosnmp.ToBigInt(pdu.Value).Int64())
+		}
+	})
+	if len(basePorts) == 0 {
+		return
+	}
+
+	// Row key (FDB ID and MAC) to its entry and bridge port
+	entries := make(map[string]*models.ForwardingEntry)
+	ports := make(map[string]int)
+	skip := make(map[string]bool)
+	read := func(portOID, statusOID string, fdbIndexed bool) {
+		c.walk(client, portOID, func(pdu gosnmp.SnmpPDU) {
+			index, ok := tableIndex(pdu.Name, portOID)
+			fdbID, mac, ok := fdbIndex(index, ok, fdbIndexed)
+			if !ok {
+				return
+			}
+			key := fmt.Sprint(index)
+			entries[key] = &models.ForwardingEntry{MACAddress: mac, FDBID: fdbID}
+			ports[key] = int(gosnmp.ToBigInt(pdu.Value).Int64())
+		})
+		c.walk(client, statusOID, func(pdu gosnmp.SnmpPDU) {
+			if index, ok := tableIndex(pdu.Name, statusOID); ok {
+				status := gosnmp.ToBigInt(pdu.Value).Int64()
+				skip[fmt.Sprint(index)] = status == fdbStatusInvalid || status == fdbStatusSelf
+			}
+		})
+	}
+	read(OIDDot1qTpFdbPort, OIDDot1qTpFdbStatus, true)
+	if len(entries) > 0 {
+		vlans := c.fdbVLANs(client)
+		for _, entry := range entries {
+			entry.VLAN = vlans[entry.FDBID]
+		}
+	} else {
+		read(OIDDot1dTpFdbPort, OIDDot1dTpFdbStatus, false)
+	}
+
+	labels := make(map[int]string)
+	for _, iface := range device.Interfaces {
+		labels[iface.Index] = interfaceLabel(iface)
+	}
+
+	device.ForwardingTable = nil
+	for key, entry := range entries {
+		// Port 0 means the port the address was learned on is unknown
+		port := ports[key]
+		if skip[key] || port == 0 {
+			continue
+		}
+		if ifIndex, ok := basePorts[port]; ok {
+			entry.IfIndex = ifIndex
+			entry.Port = labels[ifIndex]
+		}
+		if entry.Port == "" {
+			entry.Port = "port " + strconv.Itoa(port)
+		}
+		device.ForwardingTable = append(device.ForwardingTable, *entry)
+	}
+	sort.Slice(device.ForwardingTable, func(a, b int) bool {
+		ea, eb := device.ForwardingTable[a], device.ForwardingTable[b]
+		if ea.FDBID != eb.FDBID {
+			return ea.FDBID < eb.FDBID
+		}
+		return ea.MACAddress < eb.MACAddress
+	})
+
+	if len(device.ForwardingTable) > 0 {
+		c.logger.Debugf("Bridge %s learned %d MAC addresses", device.IP, len(device.ForwardingTable))
+	}
+}
+
+// fdbVLANs maps filtering database IDs to the VLAN using them, from dot1qVlanFdbId.
+// Databases shared by several VLANs (shared VLAN learning) name no single VLAN and are
+// left out.
+func (c *Client) fdbVLANs(client *gosnmp.GoSNMP) map[int]int {
+	vlans := make(map[int]int)
+	shared := make(map[int]bool)
+	c.walk(client, OIDDot1qVlanFdbID, func(pdu gosnmp.SnmpPDU) {
+		// Index: dot1qVlanTimeMark, dot1qVlanIndex
+		index, ok := tableIndex(pdu.Name, OIDDot1qVlanFdbID)
+		if !ok || len(index) != 2 {
+			return
+		}
+		fdbID, vlan := int(gosnmp.ToBigInt(pdu.Value).Int64()), index[1]
+		if current, ok := vlans[fdbID]; ok && current != vlan {
+			shared[fdbID] = true
+		}
+		vlans[fdbID] = vlan
+	})
+	for fdbID := range shared {
+		delete(vlans, fdbID)
+	}
+	return vlans
+}
+
+// fdbIndex decodes a forwarding table row index: the MAC address, preceded by the
+// filtering database ID in dot1qTpFdbTable
+func fdbIndex(index []int, ok, fdbIndexed bool) (int, string, bool) {
+	fdbID := 0
+	if fdbIndexed {
+		if !ok || len(index) != 7 {
+			return 0, "", false
+		}
+		fdbID, index = index[0], index[1:]
+	}
+	if !ok || len(index) != 6 {
+		return 0, "", false
+	}
+	mac := fmt.Sprintf("%02X:%02X:%02X:%02X:%02X:%02X", index[0], index[1], index[2], index[3], index[4], index[5])
+	return fdbID, mac, true
+}
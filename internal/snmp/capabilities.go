@@ -0,0 +1,66 @@
+package snmp
+
+import (
+	"strings"
+
+	"network-discovery/internal/models"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// MIB objects whose presence tells what a device does
+const (
+	OIDIPForwarding      = "1.3.6.1.2.1.4.1.0"    // IP-MIB ipForwarding: forwarding(1) or notForwarding(2)
+	OIDDot1dBaseNumPorts = "1.3.6.1.2.1.17.1.2.0" // BRIDGE-MIB: number of bridge ports
+	OIDPrinterMIB        = "1.3.6.1.2.1.43"       // Printer-MIB subtree
+)
+
+// ipForwarding value of routers
+const ipForwardingEnabled = 1
+
+// getCapabilities records in Capabilities which of the router, bridge and printer MIBs
+// the device implements: "router" when it forwards IP, "bridge" when it has bridge
+// ports or learned MACs, "printer" when it has a Printer-MIB
+func (c *Client) getCapabilities(client *gosnmp.GoSNMP, device *models.Device) {
+	addCapability := func(name string) {
+		if !contains(device.Capabilities, name) {
+			device.Capabilities = append(device.Capabilities, name)
+		}
+	}
+
+	if value, ok := c.getInt(client, OIDIPForwarding); ok && value == ipForwardingEnabled {
+		addCapability("router")
+	}
+	if value, ok := c.getInt(client, OIDDot1dBaseNumPorts); (ok && value > 0) || len(device.ForwardingTable) > 0 {
+		addCapability("bridge")
+	}
+
+	result, err := client.GetNext([]string{OIDPrinterMIB})
+	if err != nil {
+		c.logger.Debugf("Failed to query the Printer-MIB of %s: %v", client.Target, err)
+	} else if len(result.Variables) > 0 {
+		pdu := result.Variables[0]
+		if pdu.Type != gosnmp.EndOfMibView && strings.HasPrefix(strings.TrimPrefix(pdu.Name, "."), OIDPrinterMIB+".") {
+			addCapability("printer")
+		}
+	}
+
+	c.logger.Debugf("Capabilities of %s: %v", client.Target, device.Capabilities)
+}
+
+// getInt reads a single integer object, reporting false when the agent does not have it
+func (c *Client) getInt(client *gosnmp.GoSNMP, oid string) (int64, bool) {
+	result, err := client.Get([]string{oid})
+	if err != nil {
+		c.logger.Debugf("Failed to query %s of %s: %v", oid, client.Target, err)
+		return 0, false
+	}
+	if len(result.Variables) == 0 {
+		return 0, false
+	}
+	pdu := result.Variables[0]
+	if pdu.Type == gosnmp.NoSuchObject || pdu.Type == gosnmp.NoSuchInstance || pdu.Type == gosnmp.Null {
+		return 0, false
+	}
+	return gosnmp.ToBigInt(pdu.Value).Int64(), true
+}
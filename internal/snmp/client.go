@@ -1,6 +1,7 @@
 package snmp
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -14,46 +15,122 @@ import (
 // Standard SNMP OIDs
 const (
 	OIDSysDescr    = "1.3.6.1.2.1.1.1.0" // System description
+	OIDSysObjectID = "1.3.6.1.2.1.1.2.0" // System object ID (vendor product OID)
 	OIDSysName     = "1.3.6.1.2.1.1.5.0" // System name
 	OIDSysContact  = "1.3.6.1.2.1.1.4.0" // System contact
 	OIDSysLocation = "1.3.6.1.2.1.1.6.0" // System location
 	OIDSysUptime   = "1.3.6.1.2.1.1.3.0" // System uptime
+	OIDSysServices = "1.3.6.1.2.1.1.7.0" // System services (OSI layers offered, bit mask)
 
-	// Interface OIDs for MAC address detection
+	// Interface table OIDs
 	OIDIfPhysAddress = "1.3.6.1.2.1.2.2.1.6" // Interface physical address (MAC)
 	OIDIfDescr       = "1.3.6.1.2.1.2.2.1.2" // Interface description
 	OIDIfType        = "1.3.6.1.2.1.2.2.1.3" // Interface type
+	OIDIfMtu         = "1.3.6.1.2.1.2.2.1.4" // Interface MTU
+	OIDIfSpeed       = "1.3.6.1.2.1.2.2.1.5" // Interface speed in bit/s (saturates at 4.29 Gbit/s)
 	OIDIfAdminStatus = "1.3.6.1.2.1.2.2.1.7" // Interface admin status
 	OIDIfOperStatus  = "1.3.6.1.2.1.2.2.1.8" // Interface operational status
+	OIDIfLastChange  = "1.3.6.1.2.1.2.2.1.9" // sysUpTime at the last oper status change
+
+	// Interface extension table OIDs (IF-MIB ifXTable)
+	OIDIfName      = "1.3.6.1.2.1.31.1.1.1.1"  // Interface name
+	OIDIfHighSpeed = "1.3.6.1.2.1.31.1.1.1.15" // Interface speed in Mbit/s
+	OIDIfAlias     = "1.3.6.1.2.1.31.1.1.1.18" // Interface alias
+)
+
+const (
+	// DefaultPort is the standard SNMP agent port
+	DefaultPort = 161
+	// DefaultQuickTimeout bounds the reachability checks of quick scans
+	DefaultQuickTimeout = 2 * time.Second
 )
 
+// Options are the per-request settings of an SNMP query. They are passed by value, so one
+// Client can serve any number of concurrent scans with different settings. A zero Timeout
+// or Retries uses the client's defaults.
+type Options struct {
+	Communities   []string
+	V3Credentials []models.SNMPv3Credential
+	Timeout       time.Duration
+	Retries       int
+}
+
+// Client holds settings shared by all queries; it is not modified once scans start
 type Client struct {
 	timeout time.Duration
 	retries int
 	logger  *logrus.Logger
+
+	port             uint16
+	quickTimeout     time.Duration
+	communityVersion gosnmp.SnmpVersion
+	topology         bool
 }
 
 func NewClient(timeout time.Duration, retries int) *Client {
 	logger := logrus.New()
 	logger.SetLevel(logrus.InfoLevel)
 
-	return &Client{
-		timeout: timeout,
-		retries: retries,
-		logger:  logger,
-	}
+	return NewClientWithLogger(timeout, retries, logger)
 }
 
 func NewClientWithLogger(timeout time.Duration, retries int, logger *logrus.Logger) *Client {
 	return &Client{
-		timeout: timeout,
-		retries: retries,
-		logger:  logger,
+		timeout:          timeout,
+		retries:          retries,
+		logger:           logger,
+		port:             DefaultPort,
+		quickTimeout:     DefaultQuickTimeout,
+		communityVersion: gosnmp.Version2c,
+		topology:         true,
 	}
 }
 
-// QueryDevice queries a single device using SNMP
-func (c *Client) QueryDevice(ip string, communities []string) (*models.Device, error) {
+// SetPort changes the UDP port agents are queried on
+func (c *Client) SetPort(port int) {
+	c.port = uint16(port)
+}
+
+// SetQuickTimeout changes the timeout of IsDeviceReachable checks
+func (c *Client) SetQuickTimeout(timeout time.Duration) {
+	c.quickTimeout = timeout
+}
+
+// SetCommunityVersion selects SNMP "1" or "2c" for community based queries
+func (c *Client) SetCommunityVersion(version string) error {
+	switch version {
+	case "1":
+		c.communityVersion = gosnmp.Version1
+	case "2c", "":
+		c.communityVersion = gosnmp.Version2c
+	default:
+		return fmt.Errorf("unsupported SNMP community version %q", version)
+	}
+	return nil
+}
+
+// SetTopology enables or disables walking the LLDP and CDP neighbour tables and the
+// bridge forwarding tables
+func (c *Client) SetTopology(enabled bool) {
+	c.topology = enabled
+}
+
+// withDefaults fills unset timeout and retries from the client defaults
+func (c *Client) withDefaults(opts Options) Options {
+	if opts.Timeout <= 0 {
+		opts.Timeout = c.timeout
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = c.retries
+	}
+	return opts
+}
+
+// QueryDevice queries a single device using SNMP, trying v2c communities first and then SNMPv3 credentials
+func (c *Client) QueryDevice(ctx context.Context, ip string, opts Options) (*models.Device, error) {
+	opts = c.withDefaults(opts)
+	communities, v3Credentials := opts.Communities, opts.V3Credentials
+
 	device := &models.Device{
 		IP:          ip,
 		LastSeen:    time.Now(),
@@ -62,14 +139,19 @@ func (c *Client) QueryDevice(ip string, communities []string) (*models.Device, e
 
 	start := time.Now()
 
-	c.logger.Debugf("Starting SNMP query for %s with communities: %v", ip, communities)
+	c.logger.Debugf("Starting SNMP query for %s with communities: %v (%d SNMPv3 credentials)", ip, communities, len(v3Credentials))
 
 	for i, community := range communities {
+		if err := ctx.Err(); err != nil {
+			return device, err
+		}
+
 		c.logger.Debugf("Trying community %d/%d: '%s' for %s", i+1, len(communities), community, ip)
 
-		if err := c.queryWithCommunity(ip, community, device); err == nil {
+		if err := c.queryWithCommunity(ctx, ip, community, opts, device); err == nil {
 			device.IsReachable = true
 			device.Community = community
+			device.SNMPVersion = c.communityVersion.String()
 			device.ResponseTime = time.Since(start).Milliseconds()
 			device.ScanMethod = "SNMP"
 
@@ -83,26 +165,80 @@ func (c *Client) QueryDevice(ip string, communities []string) (*models.Device, e
 		}
 	}
 
+	for i := range v3Credentials {
+		if err := ctx.Err(); err != nil {
+			return device, err
+		}
+
+		cred := v3Credentials[i]
+		c.logger.Debugf("Trying SNMPv3 user %d/%d: '%s' for %s", i+1, len(v3Credentials), cred.Username, ip)
+
+		if err := c.queryWithV3(ctx, ip, cred, opts, device); err == nil {
+			device.IsReachable = true
+			device.SNMPVersion = "3"
+			device.SNMPUsername = cred.Username
+			device.ResponseTime = time.Since(start).Milliseconds()
+			device.ScanMethod = "SNMP"
+
+			c.logger.Infof("Successfully queried device %s with SNMPv3 user '%s'", ip, cred.Username)
+			c.logger.Debugf("Device details: hostname='%s', description='%s', vendor='%s', mac='%s'",
+				device.Hostname, device.Description, device.Vendor, device.MACAddress)
+
+			return device, nil
+		} else {
+			c.logger.Debugf("Failed with SNMPv3 user '%s': %v", cred.Username, err)
+		}
+	}
+
 	device.ResponseTime = time.Since(start).Milliseconds()
-	c.logger.Debugf("Failed to query device %s with any community", ip)
-	return device, fmt.Errorf("failed to query device %s with any community", ip)
+	c.logger.Debugf("Failed to query device %s with any credential", ip)
+	return device, fmt.Errorf("failed to query device %s with any community or SNMPv3 credential", ip)
 }
 
-func (c *Client) queryWithCommunity(ip, community string, device *models.Device) error {
+func (c *Client) queryWithCommunity(ctx context.Context, ip, community string, opts Options, device *models.Device) error {
 	c.logger.Debugf("Attempting SNMP connection to %s with community '%s'", ip, community)
 
 	// Create SNMP client
 	client := &gosnmp.GoSNMP{
 		Target:    ip,
-		Port:      161,
+		Port:      c.port,
 		Community: community,
-		Version:   gosnmp.Version2c,
-		Timeout:   c.timeout,
-		Retries:   c.retries,
+		Version:   c.communityVersion,
+		Timeout:   opts.Timeout,
+		Retries:   opts.Retries,
+		Context:   ctx,
 	}
 
-	c.logger.Debugf("SNMP client config: Target=%s, Port=161, Community=%s, Timeout=%v, Retries=%d",
-		ip, community, c.timeout, c.retries)
+	c.logger.Debugf("SNMP client config: Target=%s, Port=%d, Community=%s, Timeout=%v, Retries=%d",
+		ip, c.port, community, opts.Timeout, opts.Retries)
+
+	return c.querySystem(client, device)
+}
+
+func (c *Client) queryWithV3(ctx context.Context, ip string, cred models.SNMPv3Credential, opts Options, device *models.Device) error {
+	c.logger.Debugf("Attempting SNMPv3 connection to %s with user '%s'", ip, cred.Username)
+
+	client := &gosnmp.GoSNMP{
+		Target:  ip,
+		Port:    c.port,
+		Timeout: opts.Timeout,
+		Retries: opts.Retries,
+		Context: ctx,
+	}
+	if err := applyV3Credential(client, cred); err != nil {
+		return err
+	}
+
+	// Never log passphrases, only the protocol selection
+	c.logger.Debugf("SNMPv3 client config: Target=%s, Port=%d, User=%s, Auth=%s, Priv=%s, Context=%s, Timeout=%v, Retries=%d",
+		ip, c.port, cred.Username, cred.AuthProtocol, cred.PrivProtocol, cred.ContextName, opts.Timeout, opts.Retries)
+
+	return c.querySystem(client, device)
+}
+
+// querySystem connects with a configured gosnmp client and fills device with system group data
+func (c *Client) querySystem(client *gosnmp.GoSNMP, device *models.Device) error {
+	ip := client.Target
 
 	err := client.Connect()
 	if err != nil {
@@ -113,13 +249,18 @@ func (c *Client) queryWithCommunity(ip, community string, device *models.Device)
 
 	c.logger.Debugf("SNMP connection established to %s", ip)
 
+	// sysUpTime anchors the interface last change times
+	var uptimeTicks uint32
+
 	// Query basic system OIDs
 	oidQueries := map[string]string{
 		OIDSysDescr:    "System Description",
+		OIDSysObjectID: "System Object ID",
 		OIDSysName:     "System Name",
 		OIDSysContact:  "System Contact",
 		OIDSysLocation: "System Location",
 		OIDSysUptime:   "System Uptime",
+		OIDSysServices: "System Services",
 	}
 
 	for oid, name := range oidQueries {
@@ -149,15 +290,11 @@ func (c *Client) queryWithCommunity(ip, community string, device *models.Device)
 		case OIDSysDescr:
 			device.Description = c.parseString(variable)
 			c.logger.Debugf("System Description parsed: '%s'", device.Description)
-			// Safely parse vendor and version
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						c.logger.Errorf("Error in parseVendorAndVersion: %v", r)
-					}
-				}()
-				c.parseVendorAndVersion(device)
-			}()
+		case OIDSysObjectID:
+			if objectID, ok := variable.Value.(string); ok {
+				device.ObjectID = strings.TrimPrefix(objectID, ".")
+			}
+			c.logger.Debugf("System Object ID parsed: '%s'", device.ObjectID)
 		case OIDSysName:
 			device.Hostname = c.parseString(variable)
 			c.logger.Debugf("System Name parsed: '%s'", device.Hostname)
@@ -169,53 +306,39 @@ func (c *Client) queryWithCommunity(ip, community string, device *models.Device)
 			c.logger.Debugf("System Location parsed: '%s'", device.Location)
 		case OIDSysUptime:
 			device.Uptime = c.parseUptime(variable)
+			uptimeTicks, _ = variable.Value.(uint32)
 			c.logger.Debugf("System Uptime parsed: '%s'", device.Uptime)
+		case OIDSysServices:
+			device.Services = int(gosnmp.ToBigInt(variable.Value).Int64())
+			c.logger.Debugf("System Services parsed: %d", device.Services)
 		}
 	}
 
-	// Try to get MAC address from interface table
-	c.getMACAddress(client, device)
-
-	// Check if we got at least some data
-	if device.Description == "" && device.Hostname == "" && device.Contact == "" && device.Location == "" && device.Uptime == "" {
+	// Only walk the tables of an agent that answered; a dead host or wrong community
+	// would otherwise wait out the timeout of every walk
+	if device.Description == "" && device.Hostname == "" && device.Contact == "" && device.Location == "" &&
+		device.Uptime == "" && device.ObjectID == "" && device.Services == 0 {
 		return fmt.Errorf("no SNMP data retrieved from %s", ip)
 	}
 
-	c.logger.Debugf("Successfully retrieved SNMP data from %s", ip)
-	return nil
-}
+	// Interfaces, the chassis MAC and the addresses the device owns
+	c.getInterfaces(client, device, uptimeTicks)
 
-// getMACAddress attempts to retrieve MAC address from SNMP interface table
-func (c *Client) getMACAddress(client *gosnmp.GoSNMP, device *models.Device) {
-	c.logger.Debugf("Attempting to get MAC address for device %s", device.IP)
-
-	// Walk the interface physical address table
-	err := client.Walk(OIDIfPhysAddress, func(pdu gosnmp.SnmpPDU) error {
-		if pdu.Type == gosnmp.OctetString && pdu.Value != nil {
-			if macBytes, ok := pdu.Value.([]byte); ok && len(macBytes) == 6 {
-				// Convert bytes to MAC address string
-				macAddr := fmt.Sprintf("%02X:%02X:%02X:%02X:%02X:%02X",
-					macBytes[0], macBytes[1], macBytes[2],
-					macBytes[3], macBytes[4], macBytes[5])
-
-				// Skip empty MAC addresses (00:00:00:00:00:00)
-				if macAddr != "00:00:00:00:00:00" {
-					device.MACAddress = macAddr
-					c.logger.Debugf("Found MAC address: %s", macAddr)
-					return fmt.Errorf("stop_walk") // Stop walking after finding first valid MAC
-				}
-			}
-		}
-		return nil
-	})
+	// ARP cache, the MACs of hosts in subnets behind the device
+	c.getARPCache(client, device)
 
-	if err != nil && err.Error() != "stop_walk" {
-		c.logger.Debugf("Failed to walk interface table for MAC address: %v", err)
+	// LLDP and CDP neighbours, the links of the topology, and the hosts learned per
+	// switch port
+	if c.topology {
+		c.getNeighbors(client, device)
+		c.getForwardingTable(client, device)
 	}
 
-	if device.MACAddress == "" {
-		c.logger.Debugf("No MAC address found via SNMP for device %s", device.IP)
-	}
+	// Router, bridge and printer MIBs, which tell the device type
+	c.getCapabilities(client, device)
+
+	c.logger.Debugf("Successfully retrieved SNMP data from %s", ip)
+	return nil
 }
 
 func (c *Client) parseString(variable gosnmp.SnmpPDU) string {
@@ -276,81 +399,25 @@ func (c *Client) parseUptime(variable gosnmp.SnmpPDU) string {
 	}
 }
 
-func (c *Client) parseVendorAndVersion(device *models.Device) {
-	if device.Description == "" {
-		c.logger.Debugf("No description to parse for vendor detection")
-		return
-	}
-
-	desc := strings.ToLower(device.Description)
-	c.logger.Debugf("Parsing description for vendor: %s", desc)
-
-	// Common vendor detection patterns
-	vendors := map[string]string{
-		"cisco":     "Cisco",
-		"juniper":   "Juniper",
-		"huawei":    "Huawei",
-		"hp":        "HP",
-		"dell":      "Dell",
-		"netgear":   "Netgear",
-		"d-link":    "D-Link",
-		"tp-link":   "TP-Link",
-		"mikrotik":  "MikroTik",
-		"ubiquiti":  "Ubiquiti",
-		"fortinet":  "Fortinet",
-		"palo alto": "Palo Alto",
-		"microsoft": "Microsoft",
-		"windows":   "Microsoft",
-		"linux":     "Linux",
-		"ubuntu":    "Ubuntu",
-		"centos":    "CentOS",
-		"redhat":    "Red Hat",
-	}
-
-	for pattern, vendor := range vendors {
-		if strings.Contains(desc, pattern) {
-			device.Vendor = vendor
-			c.logger.Debugf("Vendor detected: %s (pattern: %s)", vendor, pattern)
-			break
-		}
-	}
-
-	if device.Vendor == "" {
-		device.Vendor = "Unknown"
-		c.logger.Debugf("No vendor detected, set to Unknown")
-	}
-
-	// Try to extract version information
-	lines := strings.Split(device.Description, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		lineLower := strings.ToLower(line)
-		if strings.Contains(lineLower, "version") {
-			device.Version = line
-			c.logger.Debugf("Version found: %s", line)
-			break
-		}
-	}
-
-	if device.Version == "" {
-		c.logger.Debugf("No version information found")
-	}
-}
-
 // IsDeviceReachable checks if a device responds to SNMP
-func (c *Client) IsDeviceReachable(ip string, communities []string) bool {
+func (c *Client) IsDeviceReachable(ctx context.Context, ip string, communities []string) bool {
 	c.logger.Debugf("Checking if device %s is reachable with communities: %v", ip, communities)
 
 	for _, community := range communities {
+		if ctx.Err() != nil {
+			return false
+		}
+
 		c.logger.Debugf("Testing reachability with community: %s", community)
 
 		client := &gosnmp.GoSNMP{
 			Target:    ip,
-			Port:      161,
+			Port:      c.port,
 			Community: community,
-			Version:   gosnmp.Version2c,
-			Timeout:   time.Second * 2, // Quick check
+			Version:   c.communityVersion,
+			Timeout:   c.quickTimeout, // Quick check
 			Retries:   1,
+			Context:   ctx,
 		}
 
 		err := client.Connect()
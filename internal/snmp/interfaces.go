@@ -0,0 +1,336 @@
+package snmp
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"network-discovery/internal/models"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// IP-MIB address tables
+const (
+	OIDIpAdEntIfIndex   = "1.3.6.1.2.1.4.20.1.2" // ipAddrTable: IPv4 address -> ifIndex
+	OIDIpAdEntNetMask   = "1.3.6.1.2.1.4.20.1.3" // ipAddrTable: IPv4 address -> netmask
+	OIDIpAddressIfIndex = "1.3.6.1.2.1.4.34.1.3" // ipAddressTable: IPv4/IPv6 address -> ifIndex
+	OIDIpAddressPrefix  = "1.3.6.1.2.1.4.34.1.5" // ipAddressTable: address -> prefix row (ends with the length)
+)
+
+// InetAddressType values of the ipAddressTable index
+const (
+	inetAddressIPv4  = 1
+	inetAddressIPv6  = 2
+	inetAddressIPv4z = 3
+	inetAddressIPv6z = 4
+)
+
+// ifStatusNames are the ifAdminStatus/ifOperStatus values
+var ifStatusNames = map[int64]string{
+	1: "up",
+	2: "down",
+	3: "testing",
+	4: "unknown",
+	5: "dormant",
+	6: "notPresent",
+	7: "lowerLayerDown",
+}
+
+// ifTypeNames are the IANAifType names of the interface types seen on common devices
+var ifTypeNames = map[int]string{
+	1:   "other",
+	6:   "ethernetCsmacd",
+	22:  "propPointToPointSerial",
+	23:  "ppp",
+	24:  "softwareLoopback",
+	32:  "frameRelay",
+	37:  "atm",
+	53:  "propVirtual",
+	62:  "fastEther",
+	71:  "ieee80211",
+	117: "gigabitEthernet",
+	131: "tunnel",
+	135: "l2vlan",
+	136: "l3ipvlan",
+	150: "mplsTunnel",
+	161: "ieee8023adLag",
+	166: "mpls",
+	209: "bridge",
+}
+
+// getInterfaces walks the interface tables (ifTable, ifXTable) and address tables of
+// the device. It fills Interfaces, the addresses the device reports in Addresses, and
+// MACAddress with the chassis MAC: the first non-zero interface MAC by ifIndex.
+// uptimeTicks is the sysUpTime read with the system group, 0 when unknown.
+func (c *Client) getInterfaces(client *gosnmp.GoSNMP, device *models.Device, uptimeTicks uint32) {
+	c.logger.Debugf("Collecting interfaces and addresses for device %s", device.IP)
+
+	ifaces := make(map[int]*models.Interface)
+	now := time.Now()
+
+	columns := []struct {
+		oid   string
+		apply func(iface *models.Interface, pdu gosnmp.SnmpPDU)
+	}{
+		{OIDIfDescr, func(iface *models.Interface, pdu gosnmp.SnmpPDU) {
+			iface.Description = c.parseString(pdu)
+		}},
+		{OIDIfType, func(iface *models.Interface, pdu gosnmp.SnmpPDU) {
+			iface.Type = int(gosnmp.ToBigInt(pdu.Value).Int64())
+			iface.TypeName = ifTypeNames[iface.Type]
+		}},
+		{OIDIfMtu, func(iface *models.Interface, pdu gosnmp.SnmpPDU) {
+			iface.MTU = int(gosnmp.ToBigInt(pdu.Value).Int64())
+		}},
+		{OIDIfSpeed, func(iface *models.Interface, pdu gosnmp.SnmpPDU) {
+			iface.SpeedMbps = gosnmp.ToBigInt(pdu.Value).Uint64() / 1000000
+		}},
+		{OIDIfPhysAddress, func(iface *models.Interface, pdu gosnmp.SnmpPDU) {
+			iface.MACAddress = formatMAC(pdu.Value)
+		}},
+		{OIDIfAdminStatus, func(iface *models.Interface, pdu gosnmp.SnmpPDU) {
+			iface.AdminStatus = ifStatusNames[gosnmp.ToBigInt(pdu.Value).Int64()]
+		}},
+		{OIDIfOperStatus, func(iface *models.Interface, pdu gosnmp.SnmpPDU) {
+			iface.OperStatus = ifStatusNames[gosnmp.ToBigInt(pdu.Value).Int64()]
+		}},
+		{OIDIfLastChange, func(iface *models.Interface, pdu gosnmp.SnmpPDU) {
+			// 0 means the change predates the agent's start and carries no time
+			ticks, ok := pdu.Value.(uint32)
+			if ok && ticks > 0 && uptimeTicks >= ticks {
+				changed := now.Add(-time.Duration(uptimeTicks-ticks) * 10 * time.Millisecond).Truncate(time.Second)
+				iface.LastChange = &changed
+			}
+		}},
+		{OIDIfName, func(iface *models.Interface, pdu gosnmp.SnmpPDU) {
+			iface.Name = c.parseString(pdu)
+		}},
+		{OIDIfHighSpeed, func(iface *models.Interface, pdu gosnmp.SnmpPDU) {
+			// Walked after ifSpeed, which it replaces: it stays exact above 4.29 Gbit/s
+			iface.SpeedMbps = gosnmp.ToBigInt(pdu.Value).Uint64()
+		}},
+		{OIDIfAlias, func(iface *models.Interface, pdu gosnmp.SnmpPDU) {
+			iface.Alias = c.parseString(pdu)
+		}},
+	}
+	for _, column := range columns {
+		c.walk(client, column.oid, func(pdu gosnmp.SnmpPDU) {
+			index, ok := tableIndex(pdu.Name, column.oid)
+			if !ok || len(index) != 1 {
+				return
+			}
+			if _, ok := ifaces[index[0]]; !ok {
+				ifaces[index[0]] = &models.Interface{Index: index[0]}
+			}
+			column.apply(ifaces[index[0]], pdu)
+		})
+	}
+
+	addresses := c.getAddresses(client)
+
+	indexes := make([]int, 0, len(ifaces))
+	for index := range ifaces {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	device.Interfaces = nil
+	for _, index := range indexes {
+		iface := ifaces[index]
+		for _, addr := range addresses {
+			if addr.IfIndex == index {
+				iface.IPAddresses = append(iface.IPAddresses, addr.IP)
+			}
+		}
+		if device.MACAddress == "" && iface.MACAddress != "" {
+			device.MACAddress = iface.MACAddress
+			c.logger.Debugf("Found MAC address: %s", iface.MACAddress)
+		}
+		device.Interfaces = append(device.Interfaces, *iface)
+	}
+
+	// The queried address leads the list, whether or not the agent reports it
+	device.Addresses = []models.DeviceAddress{{IP: device.IP, Source: "SNMP"}}
+	for _, addr := range addresses {
+		if iface, ok := ifaces[addr.IfIndex]; ok {
+			addr.MACAddress = iface.MACAddress
+		}
+		if addr.IP == device.IP {
+			device.Addresses[0] = addr
+		} else {
+			device.Addresses = append(device.Addresses, addr)
+		}
+	}
+
+	if device.MACAddress == "" {
+		c.logger.Debugf("No MAC address found via SNMP for device %s", device.IP)
+	}
+	c.logger.Debugf("Device %s reports %d interfaces and %d addresses", device.IP, len(device.Interfaces), len(addresses))
+}
+
+// getAddresses reads the addresses assigned to the device from ipAddressTable (IPv4
+// and IPv6), falling back to the IPv4-only ipAddrTable of older agents. Loopback,
+// unspecified and IPv6 link-local addresses are left out since they do not identify
+// a device.
+func (c *Client) getAddresses(client *gosnmp.GoSNMP) []models.DeviceAddress {
+	byIP := make(map[string]*models.DeviceAddress)
+	add := func(ip net.IP, ifIndex int) {
+		if ip == nil || ip.IsLoopback() || ip.IsUnspecified() || (ip.To4() == nil && ip.IsLinkLocalUnicast()) {
+			return
+		}
+		if _, ok := byIP[ip.String()]; !ok {
+			byIP[ip.String()] = &models.DeviceAddress{IP: ip.String(), IfIndex: ifIndex, Source: "SNMP"}
+		}
+	}
+
+	c.walk(client, OIDIpAddressIfIndex, func(pdu gosnmp.SnmpPDU) {
+		if index, ok := tableIndex(pdu.Name, OIDIpAddressIfIndex); ok {
+			add(inetAddressFromIndex(index), int(gosnmp.ToBigInt(pdu.Value).Int64()))
+		}
+	})
+	if len(byIP) > 0 {
+		c.walk(client, OIDIpAddressPrefix, func(pdu gosnmp.SnmpPDU) {
+			index, ok := tableIndex(pdu.Name, OIDIpAddressPrefix)
+			if !ok {
+				return
+			}
+			if addr := byIP[inetAddressFromIndex(index).String()]; addr != nil {
+				addr.PrefixLength = prefixLengthFromPointer(pdu.Value)
+			}
+		})
+	}
+
+	hasIPv4 := false
+	for _, addr := range byIP {
+		if strings.Contains(addr.IP, ".") {
+			hasIPv4 = true
+			break
+		}
+	}
+	if !hasIPv4 {
+		c.walk(client, OIDIpAdEntIfIndex, func(pdu gosnmp.SnmpPDU) {
+			if index, ok := tableIndex(pdu.Name, OIDIpAdEntIfIndex); ok && len(index) == 4 {
+				add(ipv4FromIndex(index), int(gosnmp.ToBigInt(pdu.Value).Int64()))
+			}
+		})
+		c.walk(client, OIDIpAdEntNetMask, func(pdu gosnmp.SnmpPDU) {
+			index, ok := tableIndex(pdu.Name, OIDIpAdEntNetMask)
+			if !ok || len(index) != 4 {
+				return
+			}
+			mask, _ := pdu.Value.(string)
+			if addr := byIP[ipv4FromIndex(index).String()]; addr != nil && net.ParseIP(mask) != nil {
+				addr.PrefixLength, _ = net.IPMask(net.ParseIP(mask).To4()).Size()
+			}
+		})
+	}
+
+	addresses := make([]models.DeviceAddress, 0, len(byIP))
+	for _, addr := range byIP {
+		addresses = append(addresses, *addr)
+	}
+	sort.Slice(addresses, func(a, b int) bool {
+		return lessAddr(addresses[a].IP, addresses[b].IP)
+	})
+	return addresses
+}
+
+// walk visits every row of a table, using GETBULK except on SNMPv1. Errors only end the
+// walk: agents commonly lack some of the tables.
+func (c *Client) walk(client *gosnmp.GoSNMP, oid string, visit func(pdu gosnmp.SnmpPDU)) {
+	walkFn := client.BulkWalk
+	if client.Version == gosnmp.Version1 {
+		walkFn = client.Walk
+	}
+	err := walkFn(oid, func(pdu gosnmp.SnmpPDU) error {
+		if pdu.Type != gosnmp.NoSuchObject && pdu.Type != gosnmp.NoSuchInstance && pdu.Type != gosnmp.EndOfMibView {
+			visit(pdu)
+		}
+		return nil
+	})
+	if err != nil {
+		c.logger.Debugf("Failed to walk %s on %s: %v", oid, client.Target, err)
+	}
+}
+
+// tableIndex returns the sub-identifiers following the column OID in name
+func tableIndex(name, column string) ([]int, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(name, "."), column+".")
+	if !ok {
+		return nil, false
+	}
+	parts := strings.Split(rest, ".")
+	index := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		index[i] = n
+	}
+	return index, true
+}
+
+// inetAddressFromIndex decodes an InetAddressType.InetAddress index (length-prefixed
+// octets); zoned addresses drop their 4-byte zone index
+func inetAddressFromIndex(index []int) net.IP {
+	if len(index) < 2 || len(index) < 2+index[1] {
+		return nil
+	}
+	octets := index[2 : 2+index[1]]
+	switch {
+	case (index[0] == inetAddressIPv4 && len(octets) == 4) || (index[0] == inetAddressIPv4z && len(octets) == 8):
+		return ipv4FromIndex(octets[:4])
+	case (index[0] == inetAddressIPv6 && len(octets) == 16) || (index[0] == inetAddressIPv6z && len(octets) == 20):
+		ip := make(net.IP, net.IPv6len)
+		for i := range ip {
+			ip[i] = byte(octets[i])
+		}
+		return ip
+	}
+	return nil
+}
+
+func ipv4FromIndex(index []int) net.IP {
+	return net.IPv4(byte(index[0]), byte(index[1]), byte(index[2]), byte(index[3]))
+}
+
+// prefixLengthFromPointer reads the prefix length, the last sub-identifier of an
+// ipAddressPrefix row pointer; the null pointer 0.0 yields 0
+func prefixLengthFromPointer(value interface{}) int {
+	oid, _ := value.(string)
+	if i := strings.LastIndex(oid, "."); i >= 0 {
+		if n, err := strconv.Atoi(oid[i+1:]); err == nil && n <= 128 && strings.Count(oid, ".") > 2 {
+			return n
+		}
+	}
+	return 0
+}
+
+// formatMAC renders a 6-byte ifPhysAddress; empty and all-zero addresses yield ""
+func formatMAC(value interface{}) string {
+	b, ok := value.([]byte)
+	if !ok || len(b) != 6 {
+		return ""
+	}
+	mac := fmt.Sprintf("%02X:%02X:%02X:%02X:%02X:%02X", b[0], b[1], b[2], b[3], b[4], b[5])
+	if mac == "00:00:00:00:00:00" {
+		return ""
+	}
+	return mac
+}
+
+// lessAddr orders IPv4 before IPv6 and numerically within a family
+func lessAddr(a, b string) bool {
+	ipA, errA := netip.ParseAddr(a)
+	ipB, errB := netip.ParseAddr(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return ipA.Less(ipB)
+}
@@ -0,0 +1,355 @@
+package snmp
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"network-discovery/internal/models"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// LLDP-MIB (IEEE 802.1AB) local port and remote system tables
+const (
+	OIDLldpLocPortId           = "1.0.8802.1.1.2.1.3.7.1.3"  // lldpLocPortTable: local port -> port ID
+	OIDLldpLocPortDesc         = "1.0.8802.1.1.2.1.3.7.1.4"  // lldpLocPortTable: local port -> port description
+	OIDLldpRemChassisIdSubtype = "1.0.8802.1.1.2.1.4.1.1.4"  // lldpRemTable: chassis ID encoding
+	OIDLldpRemChassisId        = "1.0.8802.1.1.2.1.4.1.1.5"  // lldpRemTable: chassis ID
+	OIDLldpRemPortIdSubtype    = "1.0.8802.1.1.2.1.4.1.1.6"  // lldpRemTable: port ID encoding
+	OIDLldpRemPortId           = "1.0.8802.1.1.2.1.4.1.1.7"  // lldpRemTable: port ID
+	OIDLldpRemPortDesc         = "1.0.8802.1.1.2.1.4.1.1.8"  // lldpRemTable: port description
+	OIDLldpRemSysName          = "1.0.8802.1.1.2.1.4.1.1.9"  // lldpRemTable: system name
+	OIDLldpRemSysDesc          = "1.0.8802.1.1.2.1.4.1.1.10" // lldpRemTable: system description
+	OIDLldpRemSysCapEnabled    = "1.0.8802.1.1.2.1.4.1.1.12" // lldpRemTable: enabled capabilities (BITS)
+	OIDLldpRemManAddrIfSubtype = "1.0.8802.1.1.2.1.4.2.1.3"  // lldpRemManAddrTable: indexed by the management address
+)
+
+// CISCO-CDP-MIB neighbour cache
+const (
+	OIDCdpCacheAddressType  = "1.3.6.1.4.1.9.9.23.1.2.1.1.3" // cdpCacheTable: address protocol
+	OIDCdpCacheAddress      = "1.3.6.1.4.1.9.9.23.1.2.1.1.4" // cdpCacheTable: neighbour address
+	OIDCdpCacheVersion      = "1.3.6.1.4.1.9.9.23.1.2.1.1.5" // cdpCacheTable: software version
+	OIDCdpCacheDeviceId     = "1.3.6.1.4.1.9.9.23.1.2.1.1.6" // cdpCacheTable: device ID (usually the hostname)
+	OIDCdpCacheDevicePort   = "1.3.6.1.4.1.9.9.23.1.2.1.1.7" // cdpCacheTable: neighbour port
+	OIDCdpCachePlatform     = "1.3.6.1.4.1.9.9.23.1.2.1.1.8" // cdpCacheTable: hardware platform
+	OIDCdpCacheCapabilities = "1.3.6.1.4.1.9.9.23.1.2.1.1.9" // cdpCacheTable: capability bitmask
+)
+
+// LLDP chassis and port ID subtypes that are not plain text
+const (
+	lldpChassisMAC     = 4
+	lldpChassisNetAddr = 5
+	lldpPortMAC        = 3
+	lldpPortNetAddr    = 4
+)
+
+// lldpCapabilities are the LLDP system capability bits, most significant bit first
+var lldpCapabilities = []string{"other", "repeater", "bridge", "wlanAccessPoint", "router", "telephone", "docsisCableDevice", "stationOnly", "cVLAN", "sVLAN", "tpmr"}
+
+// cdpCapabilities are the CDP capability flags
+var cdpCapabilities = []struct {
+	bit  uint32
+	name string
+}{
+	{0x01, "router"},
+	{0x02, "bridge"},
+	{0x04, "bridge"},
+	{0x08, "switch"},
+	{0x10, "host"},
+	{0x20, "igmp"},
+	{0x40, "repeater"},
+	{0x80, "telephone"},
+}
+
+// neighborRows collects the columns of a neighbour table by row index
+type neighborRows struct {
+	rows  map[string]*models.Neighbor
+	index map[string][]int
+}
+
+func newNeighborRows() *neighborRows {
+	return &neighborRows{rows: make(map[string]*models.Neighbor), index: make(map[string][]int)}
+}
+
+// row returns the neighbour of a table row, creating it on first use
+func (r *neighborRows) row(index []int) *models.Neighbor {
+	key := fmt.Sprint(index)
+	if _, ok := r.rows[key]; !ok {
+		r.rows[key] = &models.Neighbor{}
+		r.index[key] = index
+	}
+	return r.rows[key]
+}
+
+// sorted returns the neighbours in table order
+func (r *neighborRows) sorted() []models.Neighbor {
+	keys := make([]string, 0, len(r.rows))
+	for key := range r.rows {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		ia, ib := r.index[keys[a]], r.index[keys[b]]
+		for i := 0; i < len(ia) && i < len(ib); i++ {
+			if ia[i] != ib[i] {
+				return ia[i] < ib[i]
+			}
+		}
+		return len(ia) < len(ib)
+	})
+	neighbors := make([]models.Neighbor, 0, len(keys))
+	for _, key := range keys {
+		neighbors = append(neighbors, *r.rows[key])
+	}
+	return neighbors
+}
+
+// getNeighbors reads the LLDP and CDP neighbour tables of the device into Neighbors.
+// Interfaces must already be collected: local ports are reported by interface name.
+func (c *Client) getNeighbors(client *gosnmp.GoSNMP, device *models.Device) {
+	device.Neighbors = append(c.getLLDPNeighbors(client, device), c.getCDPNeighbors(client, device)...)
+	if len(device.Neighbors) > 0 {
+		c.logger.Debugf("Device %s reports %d LLDP/CDP neighbours", device.IP, len(device.Neighbors))
+	}
+}
+
+// getLLDPNeighbors walks lldpRemTable, indexed by timeMark.localPortNum.remIndex
+func (c *Client) getLLDPNeighbors(client *gosnmp.GoSNMP, device *models.Device) []models.Neighbor {
+	remote := newNeighborRows()
+	chassisSubtype := make(map[string]int)
+	portSubtype := make(map[string]int)
+	chassisIDs := make(map[string][]byte)
+	portIDs := make(map[string][]byte)
+
+	columns := []struct {
+		oid   string
+		apply func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU)
+	}{
+		{OIDLldpRemChassisIdSubtype, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			chassisSubtype[key] = int(gosnmp.ToBigInt(pdu.Value).Int64())
+		}},
+		{OIDLldpRemChassisId, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			chassisIDs[key], _ = pdu.Value.([]byte)
+		}},
+		{OIDLldpRemPortIdSubtype, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			portSubtype[key] = int(gosnmp.ToBigInt(pdu.Value).Int64())
+		}},
+		{OIDLldpRemPortId, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			portIDs[key], _ = pdu.Value.([]byte)
+		}},
+		{OIDLldpRemPortDesc, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			n.RemotePortDescr = c.parseString(pdu)
+		}},
+		{OIDLldpRemSysName, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			n.SysName = c.parseString(pdu)
+		}},
+		{OIDLldpRemSysDesc, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			n.SysDescription = c.parseString(pdu)
+		}},
+		{OIDLldpRemSysCapEnabled, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			bits, _ := pdu.Value.([]byte)
+			for i, name := range lldpCapabilities {
+				if i/8 < len(bits) && bits[i/8]&(0x80>>(i%8)) != 0 {
+					n.Capabilities = append(n.Capabilities, name)
+				}
+			}
+		}},
+	}
+	for _, column := range columns {
+		c.walk(client, column.oid, func(pdu gosnmp.SnmpPDU) {
+			if index, ok := tableIndex(pdu.Name, column.oid); ok && len(index) == 3 {
+				column.apply(remote.row(index), fmt.Sprint(index), pdu)
+			}
+		})
+	}
+	if len(remote.rows) == 0 {
+		return nil
+	}
+
+	// Management addresses are part of the row index; IPv4 is preferred
+	c.walk(client, OIDLldpRemManAddrIfSubtype, func(pdu gosnmp.SnmpPDU) {
+		index, ok := tableIndex(pdu.Name, OIDLldpRemManAddrIfSubtype)
+		if !ok || len(index) < 5 {
+			return
+		}
+		n, ok := remote.rows[fmt.Sprint(index[:3])]
+		if !ok {
+			return
+		}
+		ip := inetAddressFromIndex(index[3:])
+		if ip != nil && (n.ManagementAddress == "" || ip.To4() != nil) {
+			n.ManagementAddress = ip.String()
+		}
+	})
+
+	localIDs := make(map[int]string)
+	localDescs := make(map[int]string)
+	c.walk(client, OIDLldpLocPortId, func(pdu gosnmp.SnmpPDU) {
+		if index, ok := tableIndex(pdu.Name, OIDLldpLocPortId); ok && len(index) == 1 {
+			value, _ := pdu.Value.([]byte)
+			localIDs[index[0]] = printable(value)
+		}
+	})
+	c.walk(client, OIDLldpLocPortDesc, func(pdu gosnmp.SnmpPDU) {
+		if index, ok := tableIndex(pdu.Name, OIDLldpLocPortDesc); ok && len(index) == 1 {
+			localDescs[index[0]] = c.parseString(pdu)
+		}
+	})
+
+	for key, n := range remote.rows {
+		n.Protocol = "LLDP"
+		n.ChassisID = lldpID(chassisIDs[key], chassisSubtype[key] == lldpChassisMAC, chassisSubtype[key] == lldpChassisNetAddr)
+		n.RemotePort = lldpID(portIDs[key], portSubtype[key] == lldpPortMAC, portSubtype[key] == lldpPortNetAddr)
+		portNum := remote.index[key][1]
+		n.LocalPort, n.LocalIfIndex = localPort(device, portNum, localIDs[portNum], localDescs[portNum])
+	}
+	return remote.sorted()
+}
+
+// getCDPNeighbors walks cdpCacheTable, indexed by ifIndex.deviceIndex
+func (c *Client) getCDPNeighbors(client *gosnmp.GoSNMP, device *models.Device) []models.Neighbor {
+	cache := newNeighborRows()
+	addressTypes := make(map[string]int)
+	addresses := make(map[string][]byte)
+
+	columns := []struct {
+		oid   string
+		apply func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU)
+	}{
+		{OIDCdpCacheAddressType, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			addressTypes[key] = int(gosnmp.ToBigInt(pdu.Value).Int64())
+		}},
+		{OIDCdpCacheAddress, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			addresses[key], _ = pdu.Value.([]byte)
+		}},
+		{OIDCdpCacheVersion, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			n.SysDescription = c.parseString(pdu)
+		}},
+		{OIDCdpCacheDeviceId, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			n.ChassisID = c.parseString(pdu)
+			n.SysName = n.ChassisID
+		}},
+		{OIDCdpCacheDevicePort, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			n.RemotePort = c.parseString(pdu)
+		}},
+		{OIDCdpCachePlatform, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			n.Platform = c.parseString(pdu)
+		}},
+		{OIDCdpCacheCapabilities, func(n *models.Neighbor, key string, pdu gosnmp.SnmpPDU) {
+			value, _ := pdu.Value.([]byte)
+			var flags uint32
+			for _, b := range value {
+				flags = flags<<8 | uint32(b)
+			}
+			for _, capability := range cdpCapabilities {
+				if flags&capability.bit != 0 && !contains(n.Capabilities, capability.name) {
+					n.Capabilities = append(n.Capabilities, capability.name)
+				}
+			}
+		}},
+	}
+	for _, column := range columns {
+		c.walk(client, column.oid, func(pdu gosnmp.SnmpPDU) {
+			if index, ok := tableIndex(pdu.Name, column.oid); ok && len(index) == 2 {
+				column.apply(cache.row(index), fmt.Sprint(index), pdu)
+			}
+		})
+	}
+
+	for key, n := range cache.rows {
+		n.Protocol = "CDP"
+		// Address types are CiscoNetworkProtocol values: ip(1), ipv6(20)
+		switch address := addresses[key]; {
+		case addressTypes[key] == 1 && len(address) == net.IPv4len:
+			n.ManagementAddress = net.IP(address).String()
+		case addressTypes[key] == 20 && len(address) == net.IPv6len:
+			n.ManagementAddress = net.IP(address).String()
+		}
+		n.LocalPort, n.LocalIfIndex = localPort(device, cache.index[key][0], "", "")
+	}
+	return cache.sorted()
+}
+
+// localPort names the local end of a neighbour entry after the device's own interface:
+// the interface whose name, description or alias matches the reported port ID or
+// description, otherwise the interface with the port number as ifIndex when nothing
+// else is known about the port
+func localPort(device *models.Device, portNum int, id, desc string) (string, int) {
+	for _, iface := range device.Interfaces {
+		for _, label := range []string{id, desc} {
+			if label != "" && (strings.EqualFold(label, iface.Name) || strings.EqualFold(label, iface.Description) || strings.EqualFold(label, iface.Alias)) {
+				return interfaceLabel(iface), iface.Index
+			}
+		}
+	}
+	if id == "" && desc == "" {
+		for _, iface := range device.Interfaces {
+			if iface.Index == portNum {
+				return interfaceLabel(iface), iface.Index
+			}
+		}
+	}
+	switch {
+	case id != "":
+		return id, 0
+	case desc != "":
+		return desc, 0
+	}
+	return strconv.Itoa(portNum), 0
+}
+
+// interfaceLabel is the name ports are reported by: ifName, or ifDescr on agents without ifXTable
+func interfaceLabel(iface models.Interface) string {
+	if iface.Name != "" {
+		return iface.Name
+	}
+	return iface.Description
+}
+
+// lldpID decodes an LLDP chassis or port ID: a MAC address, a network address (IANA
+// address family byte followed by the address), or text
+func lldpID(value []byte, isMAC, isNetAddr bool) string {
+	switch {
+	case isMAC && len(value) == 6:
+		return fmt.Sprintf("%02X:%02X:%02X:%02X:%02X:%02X", value[0], value[1], value[2], value[3], value[4], value[5])
+	case isNetAddr && len(value) == 1+net.IPv4len && value[0] == 1:
+		return net.IP(value[1:]).String()
+	case isNetAddr && len(value) == 1+net.IPv6len && value[0] == 2:
+		return net.IP(value[1:]).String()
+	}
+	if text := printable(value); text != "" || len(value) == 0 {
+		return text
+	}
+	parts := make([]string, len(value))
+	for i, b := range value {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// printable returns value as trimmed text, or "" when it is binary
+func printable(value []byte) string {
+	text := strings.TrimRight(string(value), "\x00")
+	if !utf8.ValidString(text) {
+		return ""
+	}
+	for _, r := range text {
+		if !unicode.IsPrint(r) {
+			return ""
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
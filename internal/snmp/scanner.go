@@ -1,12 +1,16 @@
 package snmp
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"sync"
 	"time"
 
+	"network-discovery/internal/events"
+	"network-discovery/internal/limits"
 	"network-discovery/internal/models"
+	"network-discovery/internal/pkg/utils"
 
 	"github.com/sirupsen/logrus"
 )
@@ -36,7 +40,9 @@ func NewScannerWithLogger(client *Client, maxWorkers int, logger *logrus.Logger)
 	}
 }
 
-func (s *Scanner) ScanNetwork(networkRange string, communities []string) (*models.NetworkTopology, error) {
+// ScanNetwork queries every IP in the range over SNMP. When ctx is cancelled no further
+// IPs are queried and the partial topology is returned, marked as cancelled.
+func (s *Scanner) ScanNetwork(ctx context.Context, networkRange string, opts Options) (*models.NetworkTopology, error) {
 	start := time.Now()
 
 	s.logger.Infof("Starting network scan for range: %s", networkRange)
@@ -47,6 +53,17 @@ func (s *Scanner) ScanNetwork(networkRange string, communities []string) (*model
 		return nil, fmt.Errorf("failed to parse network range: %v", err)
 	}
 
+	return s.scanHosts(ctx, ips, opts, start)
+}
+
+// ScanHosts queries the given addresses over SNMP, e.g. IPv6 hosts found by neighbour
+// discovery. Cancellation behaves as in ScanNetwork.
+func (s *Scanner) ScanHosts(ctx context.Context, ips []string, opts Options) (*models.NetworkTopology, error) {
+	s.logger.Infof("Starting SNMP scan of %d hosts", len(ips))
+	return s.scanHosts(ctx, ips, opts, time.Now())
+}
+
+func (s *Scanner) scanHosts(ctx context.Context, ips []string, opts Options, start time.Time) (*models.NetworkTopology, error) {
 	s.logger.Infof("Scanning %d IP addresses", len(ips))
 
 	// Create channels for work distribution
@@ -66,11 +83,12 @@ func (s *Scanner) ScanNetwork(networkRange string, communities []string) (*model
 		workers = len(ips)
 	}
 
-	s.logger.Infof("Starting %d workers for scanning", workers)
+	s.logger.Debugf("Starting %d workers for scanning", workers)
 
+	tracker := events.NewTracker(ctx, models.PhaseSNMP, len(ips))
 	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go s.worker(ipChan, resultChan, communities, &wg)
+		go s.worker(ctx, ipChan, resultChan, opts, tracker, &wg)
 	}
 
 	// Wait for all workers to complete
@@ -103,34 +121,52 @@ func (s *Scanner) ScanNetwork(networkRange string, communities []string) (*model
 		ScanDuration:   scanDuration.Milliseconds(),
 	}
 
+	if err := ctx.Err(); err != nil {
+		topology.Cancelled = true
+		topology.CancelReason = err.Error()
+		s.logger.Warnf("SNMP scan cancelled after %v: %v. Returning %d devices found so far", scanDuration, err, len(devices))
+		return topology, err
+	}
+
 	s.logger.Infof("Scan completed in %v. Found %d devices (%d reachable)",
 		scanDuration, len(devices), reachableCount)
 
 	return topology, nil
 }
 
-func (s *Scanner) worker(ipChan <-chan string, resultChan chan<- *models.Device, communities []string, wg *sync.WaitGroup) {
+func (s *Scanner) worker(ctx context.Context, ipChan <-chan string, resultChan chan<- *models.Device, opts Options, tracker *events.Tracker, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	for ip := range ipChan {
+		if ctx.Err() != nil {
+			return
+		}
+
 		s.logger.Debugf("Scanning IP: %s", ip)
 
-		device, err := s.client.QueryDevice(ip, communities)
+		release, err := limits.Acquire(ctx)
+		if err != nil {
+			return
+		}
+		device, err := s.client.QueryDevice(ctx, ip, opts)
+		release()
+		tracker.Step(err == nil)
 		if err != nil {
 			s.logger.Debugf("Failed to query %s: %v", ip, err)
 			// Sadece reachable olanları ekleyelim
 			// resultChan <- nil // Unreachable cihazları ekleme
 		} else {
 			s.logger.Debugf("Successfully queried %s", ip)
+			events.DeviceFound(ctx, models.PhaseSNMP, device)
 			resultChan <- device
 		}
 	}
 }
 
-func (s *Scanner) ScanSingleDevice(ip string, communities []string) (*models.Device, error) {
+func (s *Scanner) ScanSingleDevice(ctx context.Context, ip string, opts Options) (*models.Device, error) {
 	s.logger.Infof("Scanning single device: %s", ip)
 
-	device, err := s.client.QueryDevice(ip, communities)
+	device, err := s.client.QueryDevice(ctx, ip, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan device %s: %v", ip, err)
 	}
@@ -138,7 +174,7 @@ func (s *Scanner) ScanSingleDevice(ip string, communities []string) (*models.Dev
 	return device, nil
 }
 
-func (s *Scanner) QuickScan(networkRange string, communities []string) ([]string, error) {
+func (s *Scanner) QuickScan(ctx context.Context, networkRange string, communities []string) ([]string, error) {
 	ips, err := s.parseNetworkRange(networkRange)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse network range: %v", err)
@@ -164,7 +200,13 @@ func (s *Scanner) QuickScan(networkRange string, communities []string) ([]string
 		go func() {
 			defer wg.Done()
 			for ip := range ipChan {
-				if s.client.IsDeviceReachable(ip, communities) {
+				release, err := limits.Acquire(ctx)
+				if err != nil {
+					return
+				}
+				reachable := s.client.IsDeviceReachable(ctx, ip, communities)
+				release()
+				if reachable {
 					mu.Lock()
 					reachableIPs = append(reachableIPs, ip)
 					mu.Unlock()
@@ -176,6 +218,11 @@ func (s *Scanner) QuickScan(networkRange string, communities []string) ([]string
 
 	wg.Wait()
 
+	if err := ctx.Err(); err != nil {
+		s.logger.Warnf("Quick scan cancelled: %v. Returning %d reachable devices found so far", err, len(reachableIPs))
+		return reachableIPs, err
+	}
+
 	s.logger.Infof("Quick scan found %d reachable devices", len(reachableIPs))
 	return reachableIPs, nil
 }
@@ -185,6 +232,9 @@ func (s *Scanner) parseNetworkRange(networkRange string) ([]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid CIDR notation: %v", err)
 	}
+	if utils.IsEnumerationTooLarge(ipNet) {
+		return nil, fmt.Errorf("IPv6 range %s is too large to enumerate; use neighbour discovery instead", networkRange)
+	}
 
 	var ips []string
 
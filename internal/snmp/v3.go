@@ -0,0 +1,120 @@
+package snmp
+
+import (
+	"fmt"
+	"strings"
+
+	"network-discovery/internal/models"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// authProtocols maps user-facing auth protocol names to gosnmp protocols
+var authProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+	"MD5":    gosnmp.MD5,
+	"SHA":    gosnmp.SHA,
+	"SHA1":   gosnmp.SHA,
+	"SHA224": gosnmp.SHA224,
+	"SHA256": gosnmp.SHA256,
+	"SHA384": gosnmp.SHA384,
+	"SHA512": gosnmp.SHA512,
+	"NONE":   gosnmp.NoAuth,
+	"NOAUTH": gosnmp.NoAuth,
+	"":       gosnmp.NoAuth,
+}
+
+// privProtocols maps user-facing privacy protocol names to gosnmp protocols.
+// AES-192/AES-256 use Blumenthal key extension, the "C" variants use the
+// Reeder extension implemented by Cisco devices.
+var privProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+	"DES":     gosnmp.DES,
+	"AES":     gosnmp.AES,
+	"AES128":  gosnmp.AES,
+	"AES192":  gosnmp.AES192,
+	"AES256":  gosnmp.AES256,
+	"AES192C": gosnmp.AES192C,
+	"AES256C": gosnmp.AES256C,
+	"NONE":    gosnmp.NoPriv,
+	"NOPRIV":  gosnmp.NoPriv,
+	"":        gosnmp.NoPriv,
+}
+
+// normalizeProtocol upper-cases a protocol name and strips separators ("sha-256" -> "SHA256")
+func normalizeProtocol(name string) string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	name = strings.ReplaceAll(name, "-", "")
+	name = strings.ReplaceAll(name, "_", "")
+	return name
+}
+
+// ValidateV3Credential checks that a credential set names known protocols and carries the passphrases they need
+func ValidateV3Credential(cred models.SNMPv3Credential) error {
+	if strings.TrimSpace(cred.Username) == "" {
+		return fmt.Errorf("SNMPv3 username is required")
+	}
+
+	auth, ok := authProtocols[normalizeProtocol(cred.AuthProtocol)]
+	if !ok {
+		return fmt.Errorf("unsupported SNMPv3 auth protocol for user %s: %s", cred.Username, cred.AuthProtocol)
+	}
+	priv, ok := privProtocols[normalizeProtocol(cred.PrivProtocol)]
+	if !ok {
+		return fmt.Errorf("unsupported SNMPv3 priv protocol for user %s: %s", cred.Username, cred.PrivProtocol)
+	}
+
+	if auth != gosnmp.NoAuth && len(cred.AuthPassphrase) < 8 {
+		return fmt.Errorf("SNMPv3 auth passphrase for user %s must be at least 8 characters", cred.Username)
+	}
+	if priv != gosnmp.NoPriv {
+		if auth == gosnmp.NoAuth {
+			return fmt.Errorf("SNMPv3 user %s: privacy requires an auth protocol", cred.Username)
+		}
+		if len(cred.PrivPassphrase) < 8 {
+			return fmt.Errorf("SNMPv3 priv passphrase for user %s must be at least 8 characters", cred.Username)
+		}
+	}
+
+	return nil
+}
+
+// ValidateV3Credentials validates every credential set in the list
+func ValidateV3Credentials(creds []models.SNMPv3Credential) error {
+	for _, cred := range creds {
+		if err := ValidateV3Credential(cred); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyV3Credential configures a gosnmp client for SNMPv3 USM using the given credential
+func applyV3Credential(client *gosnmp.GoSNMP, cred models.SNMPv3Credential) error {
+	if err := ValidateV3Credential(cred); err != nil {
+		return err
+	}
+
+	auth := authProtocols[normalizeProtocol(cred.AuthProtocol)]
+	priv := privProtocols[normalizeProtocol(cred.PrivProtocol)]
+
+	msgFlags := gosnmp.NoAuthNoPriv
+	switch {
+	case auth != gosnmp.NoAuth && priv != gosnmp.NoPriv:
+		msgFlags = gosnmp.AuthPriv
+	case auth != gosnmp.NoAuth:
+		msgFlags = gosnmp.AuthNoPriv
+	}
+
+	client.Version = gosnmp.Version3
+	client.SecurityModel = gosnmp.UserSecurityModel
+	client.MsgFlags = msgFlags
+	client.ContextName = cred.ContextName
+	client.SecurityParameters = &gosnmp.UsmSecurityParameters{
+		UserName:                 cred.Username,
+		AuthenticationProtocol:   auth,
+		AuthenticationPassphrase: cred.AuthPassphrase,
+		PrivacyProtocol:          priv,
+		PrivacyPassphrase:        cred.PrivPassphrase,
+	}
+
+	return nil
+}